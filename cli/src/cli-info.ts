@@ -2,6 +2,7 @@
 
 import args from "args";
 import path from "path";
+import { CliError, writeError } from "./lib/errors";
 import getInfo, { Extension, ThemeContribute } from "./lib/get-info";
 
 // args.option("dir", "Directory of the extension", "");
@@ -16,7 +17,7 @@ interface InfoResults {
 
 async function info() {
   if (!flags.dir) {
-    throw new Error("Extension directory not provided");
+    throw new CliError("invalid_arguments", "Extension directory not provided");
   }
 
   const dir = path.resolve(flags.dir);
@@ -31,6 +32,6 @@ async function info() {
 }
 
 info().catch((err) => {
-  console.error(err);
+  writeError(err);
   process.exit(1);
 });
@@ -0,0 +1,176 @@
+#!/usr/bin/env node
+
+import path from "path";
+import readline from "readline";
+import { CliError, ErrorCode, errorCode } from "./lib/errors";
+import { ThemeContribute } from "./lib/get-info";
+import generateImages from "./lib/generate-images";
+import { unwrapError } from "./lib/utils";
+
+// The server reads newline-delimited JSON-RPC 2.0 requests from stdin and
+// writes one response line to stdout for each, in the order they complete.
+// Grammars are loaded once and shared by every request, instead of once per
+// theme.
+
+type RequestId = string | number | null;
+
+interface Request {
+  jsonrpc: string;
+  id?: RequestId;
+  method: string;
+  params?: unknown;
+}
+
+interface Response {
+  jsonrpc: "2.0";
+  id: RequestId;
+  result?: unknown;
+  error?: { code: number; message: string; data: { code: ErrorCode } };
+}
+
+interface ImagesParams {
+  dir: string;
+  themeContribute: ThemeContribute;
+  output: string;
+}
+
+// https://www.jsonrpc.org/specification#error_object
+const PARSE_ERROR = -32700;
+const INVALID_REQUEST = -32600;
+const METHOD_NOT_FOUND = -32601;
+const INVALID_PARAMS = -32602;
+const SERVER_ERROR = -32000;
+
+// Responses are the only output on stdout, anything else goes to stderr.
+console.log = console.error;
+
+const methods: Record<string, (params: unknown) => Promise<unknown>> = {
+  // Health check.
+  ping: async () => "pong",
+
+  generateImages: async (params) => {
+    if (!isImagesParams(params)) {
+      throw new CliError(
+        "invalid_arguments",
+        "Params must have 'dir', 'themeContribute' and 'output' defined"
+      );
+    }
+
+    return generateImages(
+      path.resolve(params.dir),
+      params.themeContribute,
+      path.resolve(params.output)
+    );
+  },
+};
+
+async function handle(line: string): Promise<Response | undefined> {
+  let request: Request;
+  try {
+    request = JSON.parse(line);
+  } catch (err) {
+    const message = `Parse error: ${unwrapError(err)}`;
+    return errorResponse(null, PARSE_ERROR, "invalid_arguments", message);
+  }
+
+  if (!isRequest(request)) {
+    const message = "Invalid request";
+    return errorResponse(null, INVALID_REQUEST, "invalid_arguments", message);
+  }
+
+  // Notifications don't get a response.
+  const isNotification = request.id === undefined;
+  const id = request.id ?? null;
+
+  const method = methods[request.method];
+  if (!method) {
+    const message = `Method not found: ${request.method}`;
+    return isNotification
+      ? undefined
+      : errorResponse(id, METHOD_NOT_FOUND, "internal", message);
+  }
+
+  try {
+    const result = await method(request.params);
+    return isNotification ? undefined : { jsonrpc: "2.0", id, result };
+  } catch (err) {
+    const code = errorCode(err);
+    const rpcCode =
+      code === "invalid_arguments" ? INVALID_PARAMS : SERVER_ERROR;
+    return isNotification
+      ? undefined
+      : errorResponse(id, rpcCode, code, unwrapError(err));
+  }
+}
+
+// The code of the error is in its data, for the workers to tell apart errors
+// that are worth retrying.
+function errorResponse(
+  id: RequestId,
+  rpcCode: number,
+  code: ErrorCode,
+  message: string
+): Response {
+  return {
+    jsonrpc: "2.0",
+    id,
+    error: { code: rpcCode, message, data: { code } },
+  };
+}
+
+function isRequest(data: unknown): data is Request {
+  return (
+    !!data &&
+    typeof data === "object" &&
+    "method" in data &&
+    typeof data.method === "string"
+  );
+}
+
+function isImagesParams(data: unknown): data is ImagesParams {
+  return (
+    !!data &&
+    typeof data === "object" &&
+    "dir" in data &&
+    typeof data.dir === "string" &&
+    "output" in data &&
+    typeof data.output === "string" &&
+    "themeContribute" in data &&
+    !!data.themeContribute &&
+    typeof data.themeContribute === "object"
+  );
+}
+
+const lines = readline.createInterface({
+  input: process.stdin,
+  crlfDelay: Infinity,
+});
+
+let pending = 0;
+let closed = false;
+
+lines.on("line", (line) => {
+  if (!line.trim()) {
+    return;
+  }
+
+  pending += 1;
+  handle(line).then((response) => {
+    if (response) {
+      process.stdout.write(`${JSON.stringify(response)}\n`);
+    }
+
+    pending -= 1;
+    if (closed && pending === 0) {
+      process.exit(0);
+    }
+  });
+});
+
+// Exit once stdin is closed and the pending requests are answered.
+lines.on("close", () => {
+  closed = true;
+  if (pending === 0) {
+    process.exit(0);
+  }
+});
@@ -4,5 +4,6 @@ import args from "args";
 
 args.command("info", "Prase extension and output info");
 args.command("images", "Generate preview images");
+args.command("server", "Generate preview images for JSON-RPC requests on stdin");
 
 args.parse(process.argv);
@@ -0,0 +1,32 @@
+import { unwrapError } from "./utils";
+
+// The codes of errors reported to the workers, keep them in sync with the
+// error codes in internal/cli/errors.go.
+export type ErrorCode =
+  | "invalid_arguments"
+  | "invalid_manifest"
+  | "theme_not_found"
+  | "invalid_theme"
+  | "grammar_failure"
+  | "render_failure"
+  | "internal";
+
+export class CliError extends Error {
+  constructor(
+    public code: ErrorCode,
+    message: string
+  ) {
+    super(message);
+    this.name = "CliError";
+  }
+}
+
+export function errorCode(err: unknown): ErrorCode {
+  return err instanceof CliError ? err.code : "internal";
+}
+
+// Write the error to stderr as a JSON line that the workers can parse.
+export function writeError(err: unknown) {
+  const error = { code: errorCode(err), message: unwrapError(err) };
+  process.stderr.write(`${JSON.stringify({ error })}\n`);
+}
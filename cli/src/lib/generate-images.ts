@@ -0,0 +1,74 @@
+import path from "path";
+import fs from "fs/promises";
+import slugify from "slugify";
+import { CliError } from "./errors";
+import { ThemeContribute } from "./get-info";
+import parseTheme, { Theme, Token } from "./parse-theme";
+import { Language } from "./languages";
+import renderSvg from "./render-svg";
+import renderPng from "./render-png";
+import { unwrapError } from "./utils";
+
+export interface ImagesResult {
+  theme: Omit<Theme, "languageTokens">;
+  languages: LanguageResult[];
+}
+
+export interface LanguageResult {
+  language: Language;
+  tokens: Token[][];
+  svgPath: string;
+  pngPath: string;
+}
+
+// Render the preview images of a theme contributed by the extension in dir to
+// the output directory.
+export default async function generateImages(
+  dir: string,
+  themeContribute: ThemeContribute,
+  outputDir: string
+): Promise<ImagesResult> {
+  if (!themeContribute.path) {
+    throw new CliError("invalid_arguments", "Path value is required");
+  }
+
+  if (!themeContribute.label) {
+    throw new CliError("invalid_arguments", "Label value is required");
+  }
+
+  if (!themeContribute.uiTheme) {
+    throw new CliError("invalid_arguments", "uiTheme value is required");
+  }
+
+  const { languageTokens, ...theme } = await parseTheme(dir, themeContribute);
+
+  const languages: LanguageResult[] = [];
+  await fs.mkdir(outputDir, { recursive: true });
+
+  for (const { language, tokens } of languageTokens) {
+    const svg = renderSvg(theme.displayName, theme.colors, language, tokens);
+    let png: Buffer;
+    try {
+      png = await renderPng(svg);
+    } catch (err) {
+      throw new CliError(
+        "render_failure",
+        `Failed to render ${language.extName} image: ${unwrapError(err)}`
+      );
+    }
+
+    // Write png to output directory.
+    const themeFileName = path.basename(theme.path, ".json");
+    const themeSlug = slugify(themeFileName, { lower: true, strict: true });
+    const pngFileName = `${themeSlug}-${language.extName}.png`;
+    const pngPath = path.join(outputDir, pngFileName);
+    const svgFileName = `${themeSlug}-${language.extName}.svg`;
+    const svgPath = path.join(outputDir, svgFileName);
+
+    await Promise.all([fs.writeFile(svgPath, svg), fs.writeFile(pngPath, png)]);
+
+    languages.push({ language, tokens, svgPath, pngPath });
+  }
+
+  return { theme, languages };
+}
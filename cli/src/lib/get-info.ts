@@ -3,6 +3,7 @@ import fs from "fs/promises";
 import xml2js from "xml2js";
 import stripEmoji from "emoji-strip";
 import stripComments from "strip-json-comments";
+import { CliError } from "./errors";
 import { unwrapError } from "./utils";
 
 export interface Extension {
@@ -29,7 +30,10 @@ export default async function getInfo(dir: string): Promise<InfoResult> {
   try {
     await fs.access(manifestPath);
   } catch (err) {
-    throw new Error(`Could not find extension manifest at '${manifestPath}'`);
+    throw new CliError(
+      "invalid_manifest",
+      `Could not find extension manifest at '${manifestPath}'`
+    );
   }
 
   const manifestXml = await readXml(manifestPath);
@@ -60,7 +64,10 @@ async function readXml(filePath: string): Promise<any> {
     xml2js.parseString(text, { trim: true, normalize: true }, (err, result) => {
       if (err) {
         return reject(
-          new Error(`Invalid xml at '${filePath}': ${unwrapError(err)}`)
+          new CliError(
+            "invalid_manifest",
+            `Invalid xml at '${filePath}': ${unwrapError(err)}`
+          )
         );
       }
       resolve(result);
@@ -80,7 +87,10 @@ async function readJson(filePath: string): Promise<unknown> {
 
     return JSON.parse(text);
   } catch (err) {
-    throw new Error(`Invalid json at '${filePath}': ${unwrapError(err)}`);
+    throw new CliError(
+      "invalid_manifest",
+      `Invalid json at '${filePath}': ${unwrapError(err)}`
+    );
   }
 }
 
@@ -89,7 +99,10 @@ async function readJson(filePath: string): Promise<unknown> {
 function parseMetadata(manifest: any): any {
   const metadata = manifest.PackageManifest.Metadata;
   if (!Array.isArray(metadata) || !metadata[0]) {
-    throw new Error("Could not parse metadata from extension manifest");
+    throw new CliError(
+      "invalid_manifest",
+      "Could not parse metadata from extension manifest"
+    );
   }
 
   return metadata[0];
@@ -106,7 +119,10 @@ function parseExtensionDisplayName(manifest: any): string {
   const textContent = parseTextContent(metadata.DisplayName[0]);
   const extensionName = stripEmoji(textContent).trim();
   if (!extensionName) {
-    throw new Error("Missing extension name in manifest");
+    throw new CliError(
+      "invalid_manifest",
+      "Missing extension name in manifest"
+    );
   }
 
   return extensionName;
@@ -117,7 +133,10 @@ function parseExtensionDescription(manifest: any): string {
   const textContent = parseTextContent(metadata.Description[0]);
   const extensionDescription = stripEmoji(textContent).trim();
   if (!extensionDescription) {
-    throw new Error("Missing extension description in manifest");
+    throw new CliError(
+      "invalid_manifest",
+      "Missing extension description in manifest"
+    );
   }
 
   return extensionDescription;
@@ -131,7 +150,10 @@ function parseProperties(manifest: any): any[] {
     !properties[0] ||
     !Array.isArray(properties[0].Property)
   ) {
-    throw new Error("Could not parse properties from extension manifest");
+    throw new CliError(
+      "invalid_manifest",
+      "Could not parse properties from extension manifest"
+    );
   }
 
   return properties[0].Property;
@@ -159,7 +181,10 @@ function parseGithubLink(manifest: any): string | undefined {
 function parseAssets(manifest: any): any[] {
   const assets = manifest.PackageManifest.Assets;
   if (!Array.isArray(assets) || !assets[0] || !Array.isArray(assets[0].Asset)) {
-    throw new Error("Could not parse assets from extension manifest");
+    throw new CliError(
+      "invalid_manifest",
+      "Could not parse assets from extension manifest"
+    );
   }
 
   return assets[0].Asset;
@@ -177,7 +202,10 @@ function parsePackageJsonPath(manifest: any): any {
   }
 
   if (!packageJsonPath) {
-    throw new Error("Could not find package.json path in extension manifest");
+    throw new CliError(
+      "invalid_manifest",
+      "Could not find package.json path in extension manifest"
+    );
   }
 
   return packageJsonPath;
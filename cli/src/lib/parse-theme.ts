@@ -4,10 +4,11 @@ import stripComments from "strip-json-comments";
 import { trueCasePath } from "true-case-path";
 import { convertTheme as tmThemeToJSON } from "tmtheme-to-json";
 import * as vsctm from "vscode-textmate";
-import TokenMetadata, { Style } from "./token-metadata";
+import TokenMetadata, { ColorId, Style } from "./token-metadata";
 import registry from "./language-registry";
 import languages, { Language } from "./languages";
 import { ThemeContribute } from "./get-info";
+import { CliError, errorCode } from "./errors";
 import { unwrapError, normalizeColor, alpha } from "./utils";
 
 export interface Theme {
@@ -73,6 +74,8 @@ export interface TokenColor {
 export interface Token {
   text: string;
   style: Style;
+  // The TextMate scopes of the token, from the outermost to the innermost.
+  scopes: string[];
 }
 
 // Parse the theme and return colors and tokens for each language.
@@ -80,15 +83,26 @@ export default async function parseTheme(
   extensionPath: string,
   themeContribute: ThemeContribute
 ): Promise<Theme> {
-  const themePath = await trueCasePath(
-    path.resolve(extensionPath, "extension", themeContribute.path)
+  const contributePath = path.resolve(
+    extensionPath,
+    "extension",
+    themeContribute.path
   );
+  let themePath: string;
+  try {
+    themePath = await trueCasePath(contributePath);
+  } catch (err) {
+    throw new CliError(
+      "theme_not_found",
+      `Theme not found at '${contributePath}': ${unwrapError(err)}`
+    );
+  }
 
   const source = await readThemeSource(themePath);
 
   const displayName = themeContribute.label || source.name;
   if (!displayName) {
-    throw new Error(`Theme must have a 'name' defined`);
+    throw new CliError("invalid_theme", `Theme must have a 'name' defined`);
   }
 
   let type: ThemeType;
@@ -107,7 +121,10 @@ export default async function parseTheme(
   ) {
     type = "hcLight";
   } else {
-    throw new Error(`Theme 'type' must be one of 'dark' or 'light'`);
+    throw new CliError(
+      "invalid_theme",
+      `Theme 'type' must be one of 'dark' or 'light'`
+    );
   }
 
   const languageTokens: LanguageTokens[] = [];
@@ -161,7 +178,7 @@ function normalizeColors(
     hcLight: "#292929",
   });
   if (!foreground) {
-    throw new Error(`Missing color value for 'foreground'`);
+    throw new CliError("invalid_theme", `Missing color value for 'foreground'`);
   }
 
   const contrastBorder = getColorValue("contrastBorder", {
@@ -178,7 +195,10 @@ function normalizeColors(
     hcLight: "#FFFFFF",
   });
   if (!editorBackground) {
-    throw new Error(`Missing color value for 'editor.background'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'editor.background'`
+    );
   }
 
   const editorForeground = getColorValue("editor.foreground", {
@@ -188,7 +208,10 @@ function normalizeColors(
     hcLight: foreground,
   });
   if (!editorForeground) {
-    throw new Error(`Missing color value for 'editor.foreground'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'editor.foreground'`
+    );
   }
 
   const activityBarBackground = getColorValue("activityBar.background", {
@@ -198,7 +221,10 @@ function normalizeColors(
     hcLight: "#FFFFFF",
   });
   if (!activityBarBackground) {
-    throw new Error(`Missing color value for 'activityBar.background'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'activityBar.background'`
+    );
   }
 
   const activityBarForeground = getColorValue(
@@ -212,7 +238,10 @@ function normalizeColors(
     activityBarBackground
   );
   if (!activityBarForeground) {
-    throw new Error(`Missing color value for 'activityBar.foreground'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'activityBar.foreground'`
+    );
   }
 
   const activityBarInActiveForeground = getColorValue(
@@ -226,7 +255,10 @@ function normalizeColors(
     activityBarBackground
   );
   if (!activityBarInActiveForeground) {
-    throw new Error(`Missing color value for 'activityBar.inactiveForeground'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'activityBar.inactiveForeground'`
+    );
   }
 
   const activityBarBorder = getColorValue(
@@ -251,7 +283,10 @@ function normalizeColors(
     editorBackground
   );
   if (!activityBarActiveBorder) {
-    throw new Error(`Missing color value for 'activityBar.activeBorder'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'activityBar.activeBorder'`
+    );
   }
 
   const activityBarActiveBackground = getColorValue(
@@ -270,7 +305,10 @@ function normalizeColors(
     }
   );
   if (!activityBarBadgeBackground) {
-    throw new Error(`Missing color value for 'activityBarBadge.background'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'activityBarBadge.background'`
+    );
   }
 
   const activityBarBadgeForeground = getColorValue(
@@ -278,7 +316,10 @@ function normalizeColors(
     "#FFFFFF"
   );
   if (!activityBarBadgeForeground) {
-    throw new Error(`Missing color value for 'activityBarBadge.foreground'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'activityBarBadge.foreground'`
+    );
   }
 
   const tabsContainerBackground = getColorValue(
@@ -312,7 +353,10 @@ function normalizeColors(
     hcLight: editorForeground,
   });
   if (!statusBarForeground) {
-    throw new Error(`Missing color value for 'statusBar.foreground'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'statusBar.foreground'`
+    );
   }
 
   const statusBarBorder = getColorValue(
@@ -345,7 +389,10 @@ function normalizeColors(
     hcLight: "#292929",
   });
   if (!tabActiveForeground) {
-    throw new Error(`Missing color value for 'tab.activeForeground'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'tab.activeForeground'`
+    );
   }
 
   const tabBorder = getColorValue(
@@ -359,7 +406,7 @@ function normalizeColors(
     tabsContainerBackground
   );
   if (!tabBorder) {
-    throw new Error(`Missing color value for 'tab.border'`);
+    throw new CliError("invalid_theme", `Missing color value for 'tab.border'`);
   }
 
   const tabActiveBorder = getColorValue(
@@ -386,7 +433,10 @@ function normalizeColors(
     hcLight: "#FFFFFF",
   });
   if (!titleBarActiveBackground) {
-    throw new Error(`Missing color value for 'titleBar.activeBackground'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'titleBar.activeBackground'`
+    );
   }
 
   const titleBarActiveForeground = getColorValue("titleBar.activeForeground", {
@@ -396,7 +446,10 @@ function normalizeColors(
     hcLight: "#292929",
   });
   if (!titleBarActiveForeground) {
-    throw new Error(`Missing color value for 'titleBar.activeForeground'`);
+    throw new CliError(
+      "invalid_theme",
+      `Missing color value for 'titleBar.activeForeground'`
+    );
   }
 
   const titleBarBorder = getColorValue(
@@ -450,8 +503,21 @@ async function tokenizeTheme(
   theme: ThemeSource,
   language: (typeof languages)[number]
 ): Promise<Token[][]> {
-  const grammar = await registry.loadGrammar(language.scopeName);
-  if (!grammar) throw new Error("grammar file not found.");
+  let grammar: vsctm.IGrammar | null;
+  try {
+    grammar = await registry.loadGrammar(language.scopeName);
+  } catch (err) {
+    throw new CliError(
+      "grammar_failure",
+      `Failed to load grammar for ${language.scopeName}: ${unwrapError(err)}`
+    );
+  }
+  if (!grammar) {
+    throw new CliError(
+      "grammar_failure",
+      `Grammar file not found for ${language.scopeName}`
+    );
+  }
 
   registry.setTheme({
     name: theme.name,
@@ -476,22 +542,41 @@ async function tokenizeTheme(
     if (line === undefined) continue;
 
     const tokenizationResult = grammar.tokenizeLine2(line, state);
+    // The line is tokenized again for the scopes of each token. Styled tokens
+    // can span several scopes that happen to have the same style, so tokens
+    // are split by scope and take the style of the styled token they're in.
+    const scopedResult = grammar.tokenizeLine(line, state);
+    const styled = tokenizationResult.tokens;
     const tokens: Token[] = [];
 
-    for (
-      let j = 0, lenJ = tokenizationResult.tokens.length >>> 1;
-      j < lenJ;
-      j++
-    ) {
-      let startOffset = tokenizationResult.tokens[j << 1] ?? 0;
-      let metadata = tokenizationResult.tokens[(j << 1) + 1] ?? 0;
-      let endOffset =
-        j + 1 < lenJ ? tokenizationResult.tokens[(j + 1) << 1] : line.length;
-      let tokenText = line.substring(startOffset, endOffset);
+    let j = 0;
+    for (const scopedToken of scopedResult.tokens) {
+      const startOffset = scopedToken.startIndex;
+      const endOffset = Math.min(scopedToken.endIndex, line.length);
+      if (endOffset <= startOffset) continue;
+
+      while (
+        j + 1 < styled.length >>> 1 &&
+        (styled[(j + 1) << 1] ?? 0) <= startOffset
+      ) {
+        j++;
+      }
+      const metadata = styled[(j << 1) + 1] ?? 0;
+
+      // Tokens no rule colors have the default foreground, which is black when
+      // the theme's editor.foreground isn't valid. They're left without a color
+      // so they're drawn in the editor foreground.
+      const style = TokenMetadata.getStyleObject(metadata, colorMap);
+      if (
+        TokenMetadata.getForeground(metadata) === ColorId.DefaultForeground
+      ) {
+        delete style.color;
+      }
 
       tokens.push({
-        text: tokenText,
-        style: TokenMetadata.getStyleObject(metadata, colorMap),
+        text: line.substring(startOffset, endOffset),
+        style,
+        scopes: scopedToken.scopes,
       });
     }
 
@@ -506,8 +591,8 @@ async function tokenizeTheme(
 // File reading functions.
 
 async function readJson(filePath: string): Promise<unknown> {
+  const buffer = await readThemeFile(filePath);
   try {
-    const buffer = await fs.readFile(filePath);
     let text = stripComments(buffer.toString());
 
     // Strip trailing commas.
@@ -515,7 +600,27 @@ async function readJson(filePath: string): Promise<unknown> {
 
     return JSON.parse(text);
   } catch (err) {
-    throw new Error(`Invalid json at '${filePath}': ${unwrapError(err)}`);
+    throw new CliError(
+      "invalid_theme",
+      `Invalid json at '${filePath}': ${unwrapError(err)}`
+    );
+  }
+}
+
+// Read a file of the theme. A missing file is reported as such, rather than as
+// an invalid theme.
+async function readThemeFile(filePath: string): Promise<Buffer> {
+  try {
+    return await fs.readFile(filePath);
+  } catch (err) {
+    const code = (err as NodeJS.ErrnoException).code;
+    if (code === "ENOENT" || code === "ENOTDIR" || code === "EISDIR") {
+      throw new CliError(
+        "theme_not_found",
+        `Theme file not found at '${filePath}'`
+      );
+    }
+    throw err;
   }
 }
 
@@ -524,7 +629,7 @@ async function readThemeSource(themePath: string): Promise<ThemeSource> {
     const themeSource = await readThemeJSON(themePath);
 
     if (!isPartialTheme(themeSource)) {
-      throw new Error(`Path '${themePath}' is invalid`);
+      throw new CliError("invalid_theme", `Path '${themePath}' is invalid`);
     }
 
     const includes = [themeSource];
@@ -538,7 +643,10 @@ async function readThemeSource(themePath: string): Promise<ThemeSource> {
       const includesData = await readThemeJSON(includesPath);
 
       if (!isPartialTheme(includesData)) {
-        throw new Error(`Path '${includesPath}' is invalid`);
+        throw new CliError(
+          "invalid_theme",
+          `Path '${includesPath}' is invalid`
+        );
       }
 
       includes.push(includesData);
@@ -571,14 +679,20 @@ async function readThemeSource(themePath: string): Promise<ThemeSource> {
     }
 
     if (!isTheme(theme)) {
-      throw new Error(
+      throw new CliError(
+        "invalid_theme",
         `Theme must have 'type', 'colors' and 'tokenColors' defined`
       );
     }
 
     return theme;
   } catch (err) {
-    throw new Error(`Invalid theme at ${themePath}: ${unwrapError(err)}`);
+    // Keep the code of the error, such as a missing include.
+    const code = err instanceof CliError ? errorCode(err) : "invalid_theme";
+    throw new CliError(
+      code,
+      `Invalid theme at ${themePath}: ${unwrapError(err)}`
+    );
   }
 }
 
@@ -592,15 +706,21 @@ async function readThemeJSON(filePath: string): Promise<unknown> {
   //   return readTMTheme(filePath);
   // }
 
-  throw new Error(`Invalid theme extension at '${filePath}''`);
+  throw new CliError(
+    "invalid_theme",
+    `Invalid theme extension at '${filePath}''`
+  );
 }
 
 async function readTMTheme(filePath: string): Promise<unknown> {
+  const buffer = await readThemeFile(filePath);
   try {
-    const buffer = await fs.readFile(filePath);
     return tmThemeToJSON(buffer.toString());
   } catch (err) {
-    throw new Error(`Invalid json at '${filePath}'': ${unwrapError(err)}`);
+    throw new CliError(
+      "invalid_theme",
+      `Invalid tmTheme at '${filePath}'': ${unwrapError(err)}`
+    );
   }
 }
 
@@ -141,7 +141,7 @@ export default function renderSvg(
       const token = lineTokens[j];
       if (!token) continue;
       const text = encode(token.text).replace(/\s/g, "&#160;");
-      const style = renderSvgTokenStyle(token.style, colors.editorForeground);
+      const style = renderSvgTokenStyle(token.style);
       svg += `<tspan ${style}>${text}</tspan>`;
     }
     svg += "</tspan>";
@@ -159,20 +159,11 @@ interface LanguageTokenStyle {
   textDecoration?: string;
 }
 
-function renderSvgTokenStyle(
-  style: LanguageTokenStyle,
-  editorForeground: string
-) {
+function renderSvgTokenStyle(style: LanguageTokenStyle) {
   let styles: string[] = [];
 
   if (style.color) {
-    // TODO: This is a hack to fix certain tokens being #000000 when they should probably
-    // be the editorForeground. Need to figure out a proper fix in the tokenizer.
-    let textColor = style.color;
-    if (textColor === "#000000") {
-      textColor = editorForeground;
-    }
-    styles.push(`fill="${textColor}"`);
+    styles.push(`fill="${style.color}"`);
   }
 
   if (style.fontWeight) {
@@ -25,6 +25,7 @@ type Options struct {
 	DatabaseURL   string `help:"Database URL" required:"true"`
 	PublicKeyPath string `help:"Path to the public key file" default:"key.rsa.pub"`
 	Issuer        string `help:"JWT issuer" default:"localhost:8080"`
+	StaticDir     string `help:"Directory to serve under /static, the directory of the workers' local object store"`
 }
 
 func main() {
@@ -49,7 +50,7 @@ func main() {
 		}
 
 		// Create a new API server.
-		server := api.NewServer(logger, options.PublicKeyPath, options.Issuer, handlers.Handler{
+		server := api.NewServer(logger, options.PublicKeyPath, options.Issuer, options.StaticDir, handlers.Handler{
 			DBPool:      dbPool,
 			RiverClient: riverClient,
 			Logger:      logger,
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"log/slog"
+	"math"
 	"os"
 	"os/signal"
+	"path"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -19,26 +24,56 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/riverqueue/river"
 	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/vscodethemes/backend/internal/cli"
+	"github.com/vscodethemes/backend/internal/downloader"
+	"github.com/vscodethemes/backend/internal/objectstore"
+	"github.com/vscodethemes/backend/internal/packagestore"
+	"github.com/vscodethemes/backend/internal/signature"
 	"github.com/vscodethemes/backend/internal/workers"
 )
 
 func main() {
 	dbUrl := flag.String("database-url", "", "Database URL")
 	dir := flag.String("dir", "/tmp", "Directory")
+	objectStore := flag.String("object-store", "s3", "Where to upload images: s3 or local")
+	objectStoreDir := flag.String("object-store-dir", "", "Directory to upload images to with the local object store, defaults to objects in dir")
 	objectStoreEndpoint := flag.String("object-store-endpoint", "http://s3.localhost.localstack.cloud:4566", "Object store endpoint")
 	objectStoreBucket := flag.String("object-store-bucket", "images", "Object store bucket to upload images to")
 	objectStoreRegion := flag.String("object-store-region", "us-east-1", "Object store region")
 	objectStoreAccessKeyID := flag.String("object-store-access-key-id", "test", "Object store access key ID")
 	objectStoreAccessKeySecret := flag.String("object-store-access-key-secret", "test", "Object store access key secret")
-	cdnBaseUrl := flag.String("cdn-base-url", "http://s3.localhost.localstack.cloud:4566/images", "CDN base URL")
+	cdnBaseUrl := flag.String("cdn-base-url", "", "CDN base URL, defaults to the LocalStack bucket with the s3 object store and to the API's /static with the local object store")
 	disableCleanup := flag.Bool("disable-cleanup", false, "Disable cleanup")
 	maxExtensions := flag.Int("max-extensions", 0, "Maximum number of extensions to scan, 0 for all")
+	marketplaceRateLimit := flag.Float64("marketplace-rate-limit", 1, "Marketplace requests per second, shared by all workers processes")
+	marketplaceRateBurst := flag.Int("marketplace-rate-burst", 1, "Maximum burst of marketplace requests, shared by all workers processes")
+	maxPackageSize := flag.Int64("max-package-size", downloader.DefaultMaxSize, "Maximum size of an extension package in bytes")
+	packageBufferDir := flag.String("package-buffer-dir", "", "Directory to buffer downloaded packages in, empty to buffer them in memory")
+	indexPreReleases := flag.Bool("index-pre-releases", false, "Index pre-release theme previews separately from stable versions")
+	packageStore := flag.String("package-store", "none", "Where to keep downloaded packages: none, local or object-store")
+	packageStoreDir := flag.String("package-store-dir", "", "Directory to keep packages in with the local package store, defaults to packages in dir")
+	packageStorePrefix := flag.String("package-store-prefix", "packages", "Object store key prefix for packages with the object-store package store")
+	archivePackages := flag.Bool("archive-packages", false, "Keep the package of every synced version instead of only the current versions")
+	signatureTrustRoots := flag.String("signature-trust-roots", "", "PEM file of root certificates to verify package signatures with, empty to skip verification")
+	refuseInvalidSignatures := flag.Bool("refuse-invalid-signatures", false, "Don't index packages with an invalid signature, instead of flagging them")
+	persistentRenderer := flag.Bool("persistent-renderer", true, "Generate images in a long-lived renderer process instead of running the CLI for each theme")
+	rendererTimeout := flag.Duration("renderer-timeout", cli.DefaultRequestTimeout, "Maximum time to generate the images of a theme with the persistent renderer")
+	colorSlots := flag.String("color-slots", strings.Join(workers.DefaultColorSlots, ","), "Comma separated colors to save for searching themes by color")
+	imageWidths := flag.String("image-widths", joinInts(workers.DefaultImageWidths), "Comma separated widths of preview thumbnails, in pixels")
 	flag.Parse()
 
 	if *dbUrl == "" {
 		log.Fatal("Database URL is required")
 	}
 
+	if !(*marketplaceRateLimit > 0) || math.IsInf(*marketplaceRateLimit, 1) {
+		log.Fatal("Marketplace rate limit must be a positive number")
+	}
+
+	if *marketplaceRateBurst < 1 {
+		log.Fatal("Marketplace rate burst must be at least 1")
+	}
+
 	dbPool, err := pgxpool.New(context.Background(), *dbUrl)
 	if err != nil {
 		log.Fatal(fmt.Errorf("failed to create db pool: %w", err))
@@ -59,16 +94,70 @@ func main() {
 		o.BaseEndpoint = aws.String(*objectStoreEndpoint)
 	})
 
+	var images objectstore.Store
+	switch *objectStore {
+	case "s3":
+		images = objectstore.NewS3Store(objectStoreClient, *objectStoreBucket, cmp.Or(*cdnBaseUrl, "http://s3.localhost.localstack.cloud:4566/images"))
+	case "local":
+		images = objectstore.NewLocalStore(cmp.Or(*objectStoreDir, path.Join(*dir, "objects")), cmp.Or(*cdnBaseUrl, "http://localhost:8080/static"))
+	default:
+		log.Fatalf("Unknown object store: %s", *objectStore)
+	}
+
+	// Packages kept locally aren't served, so their store has no base URL.
+	var store *packagestore.Store
+	switch *packageStore {
+	case "none":
+	case "local":
+		store = packagestore.New(objectstore.NewLocalStore(cmp.Or(*packageStoreDir, path.Join(*dir, "packages")), ""), "")
+	case "object-store":
+		store = packagestore.New(images, *packageStorePrefix)
+	default:
+		log.Fatalf("Unknown package store: %s", *packageStore)
+	}
+
+	var signatureVerifier *signature.Verifier
+	if *signatureTrustRoots != "" {
+		roots, err := signature.LoadTrustRoots(*signatureTrustRoots)
+		if err != nil {
+			log.Fatal(err)
+		}
+		signatureVerifier = signature.NewVerifier(roots)
+	}
+
+	thumbnailWidths, err := parseInts(*imageWidths)
+	if err != nil {
+		log.Fatal(fmt.Errorf("invalid image widths: %w", err))
+	}
+
+	var renderer *cli.Renderer
+	if *persistentRenderer {
+		renderer = cli.NewRenderer(cli.WithRequestTimeout(*rendererTimeout))
+		if err := renderer.Start(); err != nil {
+			log.Fatal(fmt.Errorf("failed to start renderer: %w", err))
+		}
+		defer renderer.Close()
+	}
+
 	// Register Workers.
 	workersRegistry := river.NewWorkers()
 	err = workers.RegisterWorkers(workers.RegisterWorkersConfig{
-		Registry:          workersRegistry,
-		Directory:         *dir,
-		DisableCleanup:    *disableCleanup,
-		ObjectStoreClient: objectStoreClient,
-		ObjectStoreBucket: *objectStoreBucket,
-		CDNBaseUrl:        *cdnBaseUrl,
-		DBPool:            dbPool,
+		Registry:                workersRegistry,
+		Directory:               *dir,
+		DisableCleanup:          *disableCleanup,
+		ObjectStore:             images,
+		DBPool:                  dbPool,
+		MarketplaceRateLimit:    *marketplaceRateLimit,
+		MarketplaceRateBurst:    *marketplaceRateBurst,
+		MaxPackageSize:          *maxPackageSize,
+		PackageBufferDir:        *packageBufferDir,
+		PackageStore:            store,
+		ArchivePackages:         *archivePackages,
+		SignatureVerifier:       signatureVerifier,
+		RefuseInvalidSignatures: *refuseInvalidSignatures,
+		Renderer:                renderer,
+		ColorSlots:              strings.Split(*colorSlots, ","),
+		ImageWidths:             thumbnailWidths,
 	})
 	if err != nil {
 		log.Fatal(fmt.Errorf("failed to register workers: %w", err))
@@ -78,7 +167,7 @@ func main() {
 
 	riverClient, err := river.NewClient(riverpgxv5.New(dbPool), &river.Config{
 		Queues:       workers.QueueConfig(),
-		PeriodicJobs: workers.PeriodicJobs(*maxExtensions),
+		PeriodicJobs: workers.PeriodicJobs(*maxExtensions, *indexPreReleases),
 		Workers:      workersRegistry,
 		Logger: slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 			Level: slog.LevelWarn,
@@ -145,3 +234,27 @@ func main() {
 
 	<-riverClient.Stopped()
 }
+
+func joinInts(values []int) string {
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = strconv.Itoa(value)
+	}
+	return strings.Join(strs, ",")
+}
+
+// parseInts parses a comma separated list of integers, an empty string is an empty list.
+func parseInts(s string) ([]int, error) {
+	values := []int{}
+	for _, str := range strings.Split(s, ",") {
+		if str = strings.TrimSpace(str); str == "" {
+			continue
+		}
+		value, err := strconv.Atoi(str)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
@@ -1,8 +1,9 @@
 module github.com/vscodethemes/backend
 
-go 1.22.5
+go 1.23.0
 
 require (
+	github.com/HugoSmits86/nativewebp v0.9.3
 	github.com/amacneil/dbmate v1.16.2
 	github.com/aws/aws-sdk-go-v2 v1.30.3
 	github.com/aws/aws-sdk-go-v2/config v1.27.27
@@ -20,7 +21,9 @@ require (
 	github.com/riverqueue/river/riverdriver/riverpgxv5 v0.13.0
 	github.com/riverqueue/river/rivertype v0.13.0
 	github.com/sqlc-dev/sqlc v1.27.0
+	golang.org/x/image v0.21.0
 	golang.org/x/sync v0.8.0
+	golang.org/x/time v0.5.0
 )
 
 require (
@@ -269,7 +272,6 @@ require (
 	golang.org/x/net v0.26.0 // indirect
 	golang.org/x/sys v0.26.0 // indirect
 	golang.org/x/text v0.19.0 // indirect
-	golang.org/x/time v0.5.0 // indirect
 	golang.org/x/tools v0.22.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20240528184218-531527333157 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240528184218-531527333157 // indirect
@@ -11,12 +11,18 @@ import (
 	"github.com/vscodethemes/backend/internal/api/middleware"
 )
 
-func NewServer(logger *slog.Logger, publicKeyPath string, issuer string, h handlers.Handler) *echo.Echo {
+// NewServer returns the API server. Files in staticDir are served under /static, for images
+// uploaded to the local object store. An empty staticDir serves nothing.
+func NewServer(logger *slog.Logger, publicKeyPath string, issuer string, staticDir string, h handlers.Handler) *echo.Echo {
 	// Echo-specific setup.
 	e := echo.New()
 	e.Use(echomiddleware.RequestID())
 	e.Use(middleware.Logger(logger))
 
+	if staticDir != "" {
+		e.Static("/static", staticDir)
+	}
+
 	// Huma-specific setup.
 	config := huma.DefaultConfig("VS Code Themes API", "1.0.0")
 	config.Components.SecuritySchemes = map[string]*huma.SecurityScheme{}
@@ -34,6 +40,9 @@ func NewServer(logger *slog.Logger, publicKeyPath string, issuer string, h handl
 	huma.Register(api, handlers.SearchExtensionsOperation, h.SearchExtensions)
 	huma.Register(api, handlers.ScanExtensionsOperation, h.ScanExtensions)
 	huma.Register(api, handlers.SyncExtensionOperation, h.SyncExtension)
+	huma.Register(api, handlers.RestoreExtensionOperation, h.RestoreExtension)
+	huma.Register(api, handlers.ListExtensionVersionsOperation, h.ListExtensionVersions)
+	huma.Register(api, handlers.ListExtensionVersionThemesOperation, h.ListExtensionVersionThemes)
 	huma.Register(api, handlers.GetJobOperation, h.GetJob)
 	huma.Register(api, handlers.PauseJobsOperation, h.PauseJobs)
 	huma.Register(api, handlers.ResumeJobsOperation, h.ResumeJobs)
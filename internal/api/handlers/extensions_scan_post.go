@@ -35,6 +35,8 @@ type ScanExtensionsInput struct {
 	MaxExtensions            int                  `query:"maxExtensions" example:"200" doc:"Maximum number of extensions to scan. If not provided, all extensions will be scanned."`
 	StopAtEqualPublishedDate bool                 `query:"stopAtEqualPublishedDate" default:"false" example:"true" doc:"Stop scanning when the published date is equal to the last scanned extension."`
 	ForceUpdate              bool                 `query:"forceUpdate" default:"false" example:"true" doc:"Force the extension to update even if publisehd date is equal."`
+	Registry                 string               `query:"registry" enum:"marketplace,open-vsx" example:"marketplace" doc:"Registry to scan, set to 'marketplace' or 'open-vsx'. If not provided, all registries will be scanned."`
+	IncludePreReleases       bool                 `query:"includePreReleases" default:"false" example:"true" doc:"Also sync the pre-release version of each extension."`
 }
 
 type ScanExtensions struct {
@@ -79,6 +81,8 @@ func (h Handler) ScanExtensions(ctx context.Context, input *ScanExtensionsInput)
 			MaxExtensions:            maxExtensions,
 			StopAtEqualPublishedDate: input.StopAtEqualPublishedDate,
 			Force:                    input.ForceUpdate,
+			Registry:                 input.Registry,
+			IncludePreReleases:       input.IncludePreReleases,
 		}, nil)
 		if err != nil {
 			return fmt.Errorf("failed to insert job: %w", err)
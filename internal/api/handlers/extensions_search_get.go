@@ -27,13 +27,26 @@ var SearchExtensionsOperation = huma.Operation{
 
 type SearchExtensionsInput struct {
 	Text                 string `query:"text" example:"monokai" doc:"The text to search for"`
-	EditorBackground     string `query:"editorBackground" example:"#000000" doc:"The editor background color to search for"`
+	Color                string `query:"color" example:"#000000" doc:"The color to search for"`
+	ColorSlot            string `query:"colorSlot" default:"editor.background" example:"editor.background" doc:"The theme color to compare the color to"`
+	EditorBackground     string `query:"editorBackground" example:"#000000" deprecated:"true" doc:"The editor background color to search for, use color instead"`
+	KeywordColor         string `query:"keywordColor" example:"#ff79c6" doc:"The keyword color to search for"`
+	StringColor          string `query:"stringColor" example:"#f1fa8c" doc:"The string color to search for"`
+	CommentColor         string `query:"commentColor" example:"#6272a4" doc:"The comment color to search for"`
+	FunctionColor        string `query:"functionColor" example:"#50fa7b" doc:"The function name color to search for"`
+	TypeColor            string `query:"typeColor" example:"#8be9fd" doc:"The type name color to search for"`
+	NumberColor          string `query:"numberColor" example:"#bd93f9" doc:"The number color to search for"`
+	ConstantColor        string `query:"constantColor" example:"#bd93f9" doc:"The language constant color to search for"`
+	VariableColor        string `query:"variableColor" example:"#f8f8f2" doc:"The variable color to search for"`
+	OperatorColor        string `query:"operatorColor" example:"#ff79c6" doc:"The operator color to search for"`
+	TagColor             string `query:"tagColor" example:"#ff79c6" doc:"The tag name color to search for"`
 	Language             string `query:"language" default:"js" example:"js" doc:"The language to return themes for"`
 	SortBy               string `query:"sortBy" default:"relevance" example:"relevance" doc:"The sort order for results. Set to 'relevance', 'installs', 'trendingDaily', 'trendingWeekly', 'trendingMonthly', 'rating', or 'updatedAt'."`
-	ColorDistance        int    `query:"colorDistance" default:"10" example:"100" doc:"The maximum color distance to search for"`
+	ColorDistance        int    `query:"colorDistance" default:"10" example:"100" doc:"The maximum distance to each color searched for"`
 	PublisherName        string `query:"publisherName" example:"sdras" doc:"The publisher name to filter by"`
 	ExtensionName        string `query:"extensionName" example:"night-owl" doc:"The extension name to filter by"`
 	ThemeName            string `query:"themeName" example:"night-owl" doc:"The theme name to filter by"`
+	PreRelease           bool   `query:"preRelease" default:"false" example:"false" doc:"Search themes from pre-release versions instead of stable versions"`
 	ExtensionsPageNumber int    `query:"extensionsPageNumber" default:"1" example:"1" doc:"The page number for extensions"`
 	ExtensionsPageSize   int    `query:"extensionsPageSize" default:"10" example:"10" doc:"The page size for extensions"`
 	ThemesPageNumber     int    `query:"themesPageNumber" default:"1" example:"1" doc:"The page number for themes"`
@@ -59,11 +72,12 @@ type Extension struct {
 }
 
 type ThemePartial struct {
-	Name                       string `json:"name"`
-	DisplayName                string `json:"displayName"`
-	EditorBackground           string `json:"editorBackground"`
-	ActivityBarBadgeBackground string `json:"activityBarBadgeBackground"`
-	URL                        string `json:"url"`
+	Name                       string  `json:"name"`
+	DisplayName                string  `json:"displayName"`
+	EditorBackground           string  `json:"editorBackground"`
+	ActivityBarBadgeBackground string  `json:"activityBarBadgeBackground"`
+	URL                        string  `json:"url"`
+	Srcset                     []Image `json:"srcset"`
 }
 
 type Theme struct {
@@ -94,29 +108,75 @@ type Theme struct {
 	TitleBarActiveBackground      string  `json:"titleBarActiveBackground"`
 	TitleBarActiveForeground      string  `json:"titleBarActiveForeground"`
 	TitleBarBorder                *string `json:"titleBarBorder"`
+	// Colors are all the colors of the theme, keyed by color ID.
+	Colors map[string]string `json:"colors"`
+	// TokenColors are the styles of the tokens of each category, such as keyword or string.
+	TokenColors map[string]TokenColor `json:"tokenColors"`
+	Srcset      []Image               `json:"srcset"`
+}
+
+// Image is a raster preview, for clients that can't display the svg preview at url.
+type Image struct {
+	URL    string `json:"url"`
+	Format string `json:"format" example:"webp" doc:"Either png or webp"`
+	Width  int    `json:"width" example:"400"`
+}
+
+type TokenColor struct {
+	Color     string `json:"color"`
+	FontStyle string `json:"fontStyle" doc:"Space separated italic, bold, underline and strikethrough"`
 }
 
 func (h Handler) SearchExtensions(ctx context.Context, input *SearchExtensionsInput) (*SearchExtensionsOutput, error) {
 	queries := db.New(h.DBPool)
 
-	var editorBackground string
+	color, colorSlot := input.Color, input.ColorSlot
+	if color == "" && input.EditorBackground != "" {
+		color, colorSlot = input.EditorBackground, "editor.background"
+	}
+
 	var err error
-	if input.EditorBackground != "" {
-		editorBackground, err = colors.HexToLabString(input.EditorBackground)
+	if color != "" {
+		color, err = colors.HexToLabString(color)
 	}
 	if err != nil {
-		return nil, huma.Error400BadRequest("Invalid editor_background")
+		return nil, huma.Error400BadRequest("Invalid color")
+	}
+
+	tokenColors := map[string]string{}
+	for category, tokenColor := range map[string]string{
+		"keyword":  input.KeywordColor,
+		"string":   input.StringColor,
+		"comment":  input.CommentColor,
+		"function": input.FunctionColor,
+		"type":     input.TypeColor,
+		"number":   input.NumberColor,
+		"constant": input.ConstantColor,
+		"variable": input.VariableColor,
+		"operator": input.OperatorColor,
+		"tag":      input.TagColor,
+	} {
+		if tokenColor == "" {
+			continue
+		}
+		tokenColors[category], err = colors.HexToLabString(tokenColor)
+		if err != nil {
+			return nil, huma.Error400BadRequest(fmt.Sprintf("Invalid %s color", category))
+		}
 	}
 
 	result, err := queries.SearchExtensions(ctx, db.SearchExtensionsParams{
 		Text:                 input.Text,
 		Language:             input.Language,
-		EditorBackground:     editorBackground,
+		Color:                color,
+		ColorSlot:            colorSlot,
+		TokenColors:          tokenColors,
 		SortBy:               input.SortBy,
 		ColorDistance:        input.ColorDistance,
 		PublisherName:        input.PublisherName,
 		ExtensionName:        input.ExtensionName,
 		ThemeName:            input.ThemeName,
+		PreRelease:           input.PreRelease,
 		ExtensionsPageNumber: input.ExtensionsPageNumber,
 		ExtensionsPageSize:   input.ExtensionsPageSize,
 		ThemesPageNumber:     input.ThemesPageNumber,
@@ -337,6 +397,16 @@ func (h Handler) SearchExtensions(ctx context.Context, input *SearchExtensionsIn
 				titleBarBorder = &v
 			}
 
+			tokenColors := map[string]TokenColor{}
+			for category, tokenColor := range row.Theme.TokenColors {
+				color, err := colors.LabStringToHex(tokenColor.Color)
+				if err != nil {
+					h.logThemeError(ctx, fmt.Sprintf("failed to convert %s token color to hex", category), row, row.Theme.Name, err)
+					continue
+				}
+				tokenColors[category] = TokenColor{Color: color, FontStyle: tokenColor.FontStyle}
+			}
+
 			extension.Theme = &Theme{
 				URL:                           row.Theme.URL,
 				Name:                          row.Theme.Name,
@@ -365,6 +435,9 @@ func (h Handler) SearchExtensions(ctx context.Context, input *SearchExtensionsIn
 				TitleBarActiveBackground:      titleBarActiveBackground,
 				TitleBarActiveForeground:      titleBarActiveForeground,
 				TitleBarBorder:                titleBarBorder,
+				Colors:                        row.Theme.Colors,
+				TokenColors:                   tokenColors,
+				Srcset:                        convertSrcset(row.Theme.Srcset),
 			}
 		}
 
@@ -387,6 +460,7 @@ func (h Handler) SearchExtensions(ctx context.Context, input *SearchExtensionsIn
 				EditorBackground:           editorBackground,
 				ActivityBarBadgeBackground: activityBarBadgeBackground,
 				URL:                        theme.URL,
+				Srcset:                     convertSrcset(theme.Srcset),
 			})
 		}
 
@@ -396,6 +470,14 @@ func (h Handler) SearchExtensions(ctx context.Context, input *SearchExtensionsIn
 	return resp, nil
 }
 
+func convertSrcset(images []db.SearchExtensionsImage) []Image {
+	srcset := make([]Image, len(images))
+	for i, image := range images {
+		srcset[i] = Image{URL: image.URL, Format: image.Format, Width: image.Width}
+	}
+	return srcset
+}
+
 func (h Handler) logThemeError(ctx context.Context, msg string, extension db.SearchExtensionsRow, themeName string, err error) {
 	h.Logger.LogAttrs(ctx, slog.LevelError, msg,
 		slog.String("theme_name", themeName),
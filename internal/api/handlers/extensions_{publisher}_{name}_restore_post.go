@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/vscodethemes/backend/internal/api/middleware"
+	"github.com/vscodethemes/backend/internal/db"
+)
+
+var RestoreExtensionOperation = huma.Operation{
+	OperationID: "post-extensions-restore",
+	Method:      http.MethodPost,
+	Path:        "/extensions/{publisher}/{name}/restore",
+	Summary:     "Restore Extension",
+	Description: "Restore an extension that was marked as removed from its registry, making it searchable again.",
+	Tags:        []string{"Extensions"},
+	Errors:      []int{http.StatusNotFound},
+	Security: []map[string][]string{
+		middleware.BearerAuthSecurity("extension:write"),
+	},
+}
+
+type RestoreExtensionInput struct {
+	PublisherName string `path:"publisher" example:"sdras" doc:"The publisher name"`
+	ExtensionName string `path:"name" example:"night-owl" doc:"The extension name"`
+	Registry      string `query:"registry" enum:"marketplace,open-vsx" default:"marketplace" example:"marketplace" doc:"The registry the extension was removed from"`
+}
+
+type RestoreExtensionOutput struct {
+	Body struct {
+		Restored bool `json:"restored"`
+	}
+}
+
+func (h Handler) RestoreExtension(ctx context.Context, input *RestoreExtensionInput) (*RestoreExtensionOutput, error) {
+	queries := db.New(h.DBPool)
+	restored, err := queries.RestoreExtension(ctx, db.RestoreExtensionParams{
+		ExtensionName: input.ExtensionName,
+		PublisherName: input.PublisherName,
+		Registry:      input.Registry,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore extension: %w", err)
+	}
+
+	if restored == 0 {
+		return nil, huma.NewError(http.StatusNotFound, "Removed extension not found")
+	}
+
+	resp := &RestoreExtensionOutput{}
+	resp.Body.Restored = true
+
+	return resp, nil
+}
@@ -28,7 +28,9 @@ var SyncExtensionOperation = huma.Operation{
 type SyncExtensionInput struct {
 	PublisherName string `path:"publisher" example:"sdras" doc:"The publisher name"`
 	ExtensionName string `path:"name" example:"night-owl" doc:"The extension name"`
+	Registry      string `query:"registry" enum:"marketplace,open-vsx" default:"marketplace" example:"marketplace" doc:"The registry to sync from"`
 	Force         bool   `query:"force" example:"true" doc:"Force the sync" default:"false"`
+	PreRelease    bool   `query:"preRelease" example:"true" doc:"Sync the latest pre-release version instead of the stable version" default:"false"`
 }
 
 type SyncExtensionOutput struct {
@@ -43,7 +45,9 @@ func (h Handler) SyncExtension(ctx context.Context, input *SyncExtensionInput) (
 		result, err := h.RiverClient.InsertTx(ctx, tx, workers.SyncExtensionArgs{
 			PublisherName: input.PublisherName,
 			ExtensionName: input.ExtensionName,
+			Registry:      input.Registry,
 			Force:         input.Force,
+			PreRelease:    input.PreRelease,
 		}, nil)
 		if err != nil {
 			return fmt.Errorf("failed to insert job: %w", err)
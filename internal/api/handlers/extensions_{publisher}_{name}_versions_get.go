@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/vscodethemes/backend/internal/api/middleware"
+	"github.com/vscodethemes/backend/internal/db"
+)
+
+var ListExtensionVersionsOperation = huma.Operation{
+	OperationID: "list-extension-versions",
+	Method:      http.MethodGet,
+	Path:        "/extensions/{publisher}/{name}/versions",
+	Summary:     "List Extension Versions",
+	Description: "List the versions of an extension that were synced, most recent first.",
+	Tags:        []string{"Extensions"},
+	Errors:      []int{http.StatusNotFound},
+	Security: []map[string][]string{
+		middleware.BearerAuthSecurity("extension:read"),
+	},
+}
+
+type ListExtensionVersionsInput struct {
+	PublisherName string `path:"publisher" example:"sdras" doc:"The publisher name"`
+	ExtensionName string `path:"name" example:"night-owl" doc:"The extension name"`
+}
+
+type ListExtensionVersionsOutput struct {
+	Body struct {
+		Versions []ExtensionVersion `json:"versions"`
+	}
+}
+
+type ExtensionVersion struct {
+	Version         string    `json:"version"`
+	PreRelease      bool      `json:"preRelease"`
+	TargetPlatform  *string   `json:"targetPlatform"`
+	AssetURI        *string   `json:"assetUri"`
+	PackageHash     *string   `json:"packageHash"`
+	SignatureStatus *string   `json:"signatureStatus" enum:"verified,unsigned,invalid" doc:"The result of verifying the package signature, null if it wasn't verified"`
+	SyncedAt        time.Time `json:"syncedAt"`
+	TotalThemes     int64     `json:"totalThemes"`
+}
+
+func (h Handler) ListExtensionVersions(ctx context.Context, input *ListExtensionVersionsInput) (*ListExtensionVersionsOutput, error) {
+	queries := db.New(h.DBPool)
+	rows, err := queries.ListExtensionVersions(ctx, db.ListExtensionVersionsParams{
+		ExtensionName: input.ExtensionName,
+		PublisherName: input.PublisherName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extension versions: %w", err)
+	}
+
+	if len(rows) == 0 {
+		return nil, huma.NewError(http.StatusNotFound, "Extension not found")
+	}
+
+	resp := &ListExtensionVersionsOutput{}
+	resp.Body.Versions = make([]ExtensionVersion, len(rows))
+	for i, row := range rows {
+		version := ExtensionVersion{
+			Version:     row.Version,
+			PreRelease:  row.PreRelease,
+			SyncedAt:    row.SyncedAt.Time,
+			TotalThemes: row.TotalThemes,
+		}
+		if row.TargetPlatform.Valid {
+			version.TargetPlatform = &row.TargetPlatform.String
+		}
+		if row.AssetUri.Valid {
+			version.AssetURI = &row.AssetUri.String
+		}
+		if row.PackageHash.Valid {
+			version.PackageHash = &row.PackageHash.String
+		}
+		if row.SignatureStatus.Valid {
+			version.SignatureStatus = &row.SignatureStatus.String
+		}
+		resp.Body.Versions[i] = version
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/vscodethemes/backend/internal/api/middleware"
+	"github.com/vscodethemes/backend/internal/db"
+)
+
+var ListExtensionVersionThemesOperation = huma.Operation{
+	OperationID: "list-extension-version-themes",
+	Method:      http.MethodGet,
+	Path:        "/extensions/{publisher}/{name}/versions/{version}/themes",
+	Summary:     "List Extension Version Themes",
+	Description: "List the themes of a synced version with their previews, including versions that are no longer current.",
+	Tags:        []string{"Extensions"},
+	Errors:      []int{http.StatusNotFound},
+	Security: []map[string][]string{
+		middleware.BearerAuthSecurity("extension:read"),
+	},
+}
+
+type ListExtensionVersionThemesInput struct {
+	PublisherName string `path:"publisher" example:"sdras" doc:"The publisher name"`
+	ExtensionName string `path:"name" example:"night-owl" doc:"The extension name"`
+	Version       string `path:"version" example:"2.0.1" doc:"The extension version"`
+	Language      string `query:"language" default:"js" example:"js" doc:"The language to return previews for"`
+}
+
+type ListExtensionVersionThemesOutput struct {
+	Body struct {
+		Themes []VersionTheme `json:"themes"`
+	}
+}
+
+type VersionTheme struct {
+	Name        string  `json:"name"`
+	DisplayName string  `json:"displayName"`
+	URL         string  `json:"url"`
+	Srcset      []Image `json:"srcset"`
+}
+
+func (h Handler) ListExtensionVersionThemes(ctx context.Context, input *ListExtensionVersionThemesInput) (*ListExtensionVersionThemesOutput, error) {
+	queries := db.New(h.DBPool)
+	rows, err := queries.ListExtensionVersionThemes(ctx, db.ListExtensionVersionThemesParams{
+		ExtensionName: input.ExtensionName,
+		PublisherName: input.PublisherName,
+		Version:       input.Version,
+		Language:      input.Language,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extension version themes: %w", err)
+	}
+
+	if len(rows) == 0 {
+		return nil, huma.NewError(http.StatusNotFound, "Extension version not found")
+	}
+
+	resp := &ListExtensionVersionThemesOutput{}
+	resp.Body.Themes = make([]VersionTheme, len(rows))
+	for i, row := range rows {
+		srcset := []Image{}
+		if err := json.Unmarshal(row.Srcset, &srcset); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal srcset: %w", err)
+		}
+
+		resp.Body.Themes[i] = VersionTheme{
+			Name:        row.Name,
+			DisplayName: row.DisplayName,
+			URL:         row.Url,
+			Srcset:      srcset,
+		}
+	}
+
+	return resp, nil
+}
@@ -48,6 +48,7 @@ func (h Handler) ForceSyncAllExtensions(ctx context.Context, input *ForceSyncAll
 			Args: workers.SyncExtensionArgs{
 				PublisherName: extension.PublisherName,
 				ExtensionName: extension.Name,
+				Registry:      extension.Registry,
 				Force:         true,
 			},
 			InsertOpts: &river.InsertOpts{
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -44,6 +45,7 @@ type Job struct {
 	FinalizedAt *time.Time        `json:"finalizedAt"`
 	MaxAttempts int               `json:"maxAttempts"`
 	State       string            `json:"state"`
+	Output      json.RawMessage   `json:"output,omitempty" doc:"The result recorded by the job when it completed"`
 }
 
 type JobAttemptError struct {
@@ -83,6 +85,13 @@ func mapRiverJobToJob(riverJob rivertype.JobRow) Job {
 		State:       string(riverJob.State),
 	}
 
+	var metadata struct {
+		Output json.RawMessage `json:"output"`
+	}
+	if err := json.Unmarshal(riverJob.Metadata, &metadata); err == nil {
+		job.Output = metadata.Output
+	}
+
 	for _, riverError := range riverJob.Errors {
 		job.Errors = append(job.Errors, JobAttemptError{
 			At:      riverError.At,
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrorCode is the code of an error reported by the CLI, see cli/src/lib/errors.ts.
+type ErrorCode string
+
+const (
+	ErrorCodeInvalidArguments ErrorCode = "invalid_arguments"
+	ErrorCodeInvalidManifest  ErrorCode = "invalid_manifest"
+	ErrorCodeThemeNotFound    ErrorCode = "theme_not_found"
+	ErrorCodeInvalidTheme     ErrorCode = "invalid_theme"
+	ErrorCodeGrammarFailure   ErrorCode = "grammar_failure"
+	ErrorCodeRenderFailure    ErrorCode = "render_failure"
+	ErrorCodeInternal         ErrorCode = "internal"
+)
+
+// Error is an error reported by the CLI.
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Permanent returns true if the error is caused by the extension, running the CLI again on the
+// same extension fails the same way. Grammar and render failures are caused by the CLI and
+// internal errors are unknown, they're worth retrying.
+func (e *Error) Permanent() bool {
+	switch e.Code {
+	case ErrorCodeInvalidArguments, ErrorCodeInvalidManifest, ErrorCodeThemeNotFound, ErrorCodeInvalidTheme:
+		return true
+	}
+	return false
+}
+
+// IsPermanent returns true if err is a CLI error that retrying won't fix.
+func IsPermanent(err error) bool {
+	var cliErr *Error
+	return errors.As(err, &cliErr) && cliErr.Permanent()
+}
+
+// parseStderr returns the error the CLI wrote to stderr as its last JSON line. Output that isn't
+// a JSON error, such as a crash, is an internal error.
+func parseStderr(stderr []byte) *Error {
+	lines := bytes.Split(bytes.TrimSpace(stderr), []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		var output struct {
+			Error *Error `json:"error"`
+		}
+		if err := json.Unmarshal(lines[i], &output); err == nil && output.Error != nil && output.Error.Code != "" {
+			return output.Error
+		}
+	}
+
+	message := strings.TrimSpace(string(stderr))
+	if message == "" {
+		message = "cli exited without an error"
+	}
+
+	return &Error{Code: ErrorCodeInternal, Message: message}
+}
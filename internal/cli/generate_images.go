@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -66,6 +67,8 @@ type Language struct {
 type Token struct {
 	Text  string `json:"text"`
 	Style Style  `json:"style"`
+	// Scopes are the TextMate scopes of the token, from the outermost to the innermost.
+	Scopes []string `json:"scopes"`
 }
 
 type Style struct {
@@ -95,15 +98,12 @@ func GenerateImages(ctx context.Context, extensionPath string, theme ThemeContri
 
 	output, err := cmd.Output()
 	if err != nil {
-		var message string
-		exitError, ok := err.(*exec.ExitError)
-		if ok {
-			message = string(exitError.Stderr)
-		} else {
-			message = err.Error()
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) {
+			return nil, fmt.Errorf("failed to generate images: %w", parseStderr(exitError.Stderr))
 		}
 
-		return nil, fmt.Errorf("failed to generate images: %s", message)
+		return nil, fmt.Errorf("failed to run cli: %w", err)
 	}
 
 	var result GenerateImagesResult
@@ -0,0 +1,424 @@
+package cli
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	DefaultRequestTimeout      = 2 * time.Minute
+	DefaultHealthCheckInterval = 30 * time.Second
+
+	healthCheckTimeout = 10 * time.Second
+	// closeTimeout is how long the renderer has to answer pending requests once it's closed.
+	closeTimeout = 30 * time.Second
+	// maxResponseSize is far larger than any response, which are usually well under 1MB.
+	maxResponseSize = 64 << 20
+)
+
+var (
+	ErrRendererClosed  = errors.New("renderer closed")
+	ErrRendererExited  = errors.New("renderer exited")
+	ErrRendererTimeout = errors.New("renderer request timed out")
+)
+
+// Renderer generates images with a long-lived renderer process, which loads grammars once instead
+// of once per theme. It reads newline-delimited JSON-RPC 2.0 requests on stdin and writes a
+// response line for each on stdout. The process is started again if it exits or stops answering
+// health checks.
+type Renderer struct {
+	// Dir is the directory of the Node CLI.
+	Dir                 string
+	RequestTimeout      time.Duration
+	HealthCheckInterval time.Duration
+	command             []string
+
+	nextID  atomic.Int64
+	mu      sync.Mutex
+	process *rendererProcess
+	stop    chan struct{}
+	closed  bool
+}
+
+type RendererOption func(*Renderer)
+
+// WithRequestTimeout sets how long to wait for the images of a theme.
+func WithRequestTimeout(timeout time.Duration) RendererOption {
+	return func(r *Renderer) {
+		r.RequestTimeout = timeout
+	}
+}
+
+// WithHealthCheckInterval sets how often the renderer process is checked.
+func WithHealthCheckInterval(interval time.Duration) RendererOption {
+	return func(r *Renderer) {
+		r.HealthCheckInterval = interval
+	}
+}
+
+// WithCommand sets the command that runs the renderer process, in the renderer's directory.
+func WithCommand(name string, args ...string) RendererOption {
+	return func(r *Renderer) {
+		r.command = append([]string{name}, args...)
+	}
+}
+
+func NewRenderer(opts ...RendererOption) *Renderer {
+	r := &Renderer{
+		Dir:                 "cli",
+		RequestTimeout:      DefaultRequestTimeout,
+		HealthCheckInterval: DefaultHealthCheckInterval,
+		// Run the server directly rather than through npx, so killing the process doesn't leave
+		// the server running.
+		command: []string{"node", "build/cli-server.js"},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Start starts the renderer process and its health checks.
+func (r *Renderer) Start() error {
+	if _, err := r.ensureProcess(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stop == nil {
+		r.stop = make(chan struct{})
+		go r.healthCheck(r.stop)
+	}
+
+	return nil
+}
+
+// Close stops the renderer process once it has answered pending requests.
+func (r *Renderer) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	if r.stop != nil {
+		close(r.stop)
+	}
+	p := r.process
+	r.mu.Unlock()
+
+	if p == nil {
+		return nil
+	}
+
+	// The renderer exits once stdin is closed and it has answered pending requests.
+	p.stdin.Close()
+
+	timer := time.NewTimer(closeTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-p.done:
+	case <-timer.C:
+		p.kill()
+		<-p.done
+	}
+
+	return nil
+}
+
+// Ping checks that the renderer process answers requests.
+func (r *Renderer) Ping(ctx context.Context) error {
+	var result string
+	return r.call(ctx, "ping", nil, &result, healthCheckTimeout)
+}
+
+// GenerateImages generates the images of a theme like the GenerateImages function, in the
+// renderer process.
+func (r *Renderer) GenerateImages(ctx context.Context, extensionPath string, theme ThemeContribute, outputDir string) (*GenerateImagesResult, error) {
+	// Ensure the extension directory exists.
+	if _, err := os.Stat(extensionPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("extension directory does not exist: %w", err)
+	}
+
+	params := struct {
+		Dir             string          `json:"dir"`
+		ThemeContribute ThemeContribute `json:"themeContribute"`
+		Output          string          `json:"output"`
+	}{
+		Dir:             extensionPath,
+		ThemeContribute: theme,
+		Output:          outputDir,
+	}
+
+	var result GenerateImagesResult
+	if err := r.call(ctx, "generateImages", params, &result, r.RequestTimeout); err != nil {
+		return nil, fmt.Errorf("failed to generate images: %w", err)
+	}
+
+	return &result, nil
+}
+
+// rpcError is an error response from the renderer. The code of the CLI error is in its data.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Code ErrorCode `json:"code"`
+	} `json:"data"`
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int64  `json:"id"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	ID     *int64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+func (r *Renderer) call(ctx context.Context, method string, params any, result any, timeout time.Duration) error {
+	p, err := r.ensureProcess()
+	if err != nil {
+		return err
+	}
+
+	id := r.nextID.Add(1)
+	request, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	responses := p.register(id)
+	defer p.unregister(id)
+
+	if err := p.write(append(request, '\n')); err != nil {
+		return fmt.Errorf("failed to write request: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var response rpcResponse
+	select {
+	case response = <-responses:
+	case <-p.done:
+		// The response may have been read right before the process exited.
+		select {
+		case response = <-responses:
+		default:
+			return p.err
+		}
+	case <-timer.C:
+		return fmt.Errorf("%w: %s after %s", ErrRendererTimeout, method, timeout)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if response.Error != nil {
+		return &Error{Code: cmp.Or(response.Error.Data.Code, ErrorCodeInternal), Message: response.Error.Message}
+	}
+
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s result: %w", method, err)
+	}
+
+	return nil
+}
+
+// ensureProcess returns the renderer process, starting it if it isn't running.
+func (r *Renderer) ensureProcess() (*rendererProcess, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, ErrRendererClosed
+	}
+
+	if r.process != nil {
+		select {
+		case <-r.process.done:
+			log.Warnf("Restarting renderer: %s", r.process.err)
+		default:
+			return r.process, nil
+		}
+	}
+
+	p, err := startRendererProcess(r.Dir, r.command)
+	if err != nil {
+		return nil, err
+	}
+	r.process = p
+
+	return p, nil
+}
+
+// healthCheck pings the renderer process until stopped. A process that doesn't answer is killed
+// and started again.
+func (r *Renderer) healthCheck(stop <-chan struct{}) {
+	ticker := time.NewTicker(r.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := r.Ping(context.Background()); err != nil && !errors.Is(err, ErrRendererClosed) {
+			log.Warnf("Renderer health check failed: %s", err)
+
+			r.mu.Lock()
+			p := r.process
+			r.mu.Unlock()
+			if p != nil {
+				p.kill()
+			}
+		}
+	}
+}
+
+type rendererProcess struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int64]chan rpcResponse
+
+	// done is closed once the process exits, err is why.
+	done chan struct{}
+	err  error
+}
+
+func startRendererProcess(dir string, command []string) (*rendererProcess, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = dir
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create renderer stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create renderer stdout: %w", err)
+	}
+	stderrReader, stderrWriter := io.Pipe()
+	cmd.Stderr = stderrWriter
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start renderer: %w", err)
+	}
+
+	p := &rendererProcess{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: map[int64]chan rpcResponse{},
+		done:    make(chan struct{}),
+	}
+
+	go logStderr(stderrReader)
+	go func() {
+		readErr := p.read(stdout)
+		waitErr := cmd.Wait()
+		stderrWriter.Close()
+
+		p.err = fmt.Errorf("%w: %w", ErrRendererExited, cmp.Or(waitErr, readErr))
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// read reads responses until stdout is closed, handing them to the pending requests.
+func (p *rendererProcess) read(stdout io.Reader) error {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxResponseSize)
+
+	for scanner.Scan() {
+		var response rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil || response.ID == nil {
+			log.Warnf("Ignoring invalid renderer response: %s", scanner.Text())
+			continue
+		}
+
+		p.mu.Lock()
+		responses, ok := p.pending[*response.ID]
+		p.mu.Unlock()
+
+		// Requests that timed out are no longer pending.
+		if ok {
+			responses <- response
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		// Stop the process, a response can't be skipped without losing track of the rest.
+		p.kill()
+		return err
+	}
+
+	return io.EOF
+}
+
+func (p *rendererProcess) register(id int64) chan rpcResponse {
+	responses := make(chan rpcResponse, 1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending[id] = responses
+	return responses
+}
+
+func (p *rendererProcess) unregister(id int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.pending, id)
+}
+
+func (p *rendererProcess) write(request []byte) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	_, err := p.stdin.Write(request)
+	return err
+}
+
+func (p *rendererProcess) kill() {
+	if err := p.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		log.Warnf("Failed to kill renderer: %s", err)
+	}
+}
+
+// logStderr logs what the renderer process writes to stderr, line by line.
+func logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		log.Warnf("Renderer: %s", scanner.Text())
+	}
+	// Keep draining if a line is too long, so the process doesn't block on a full pipe.
+	io.Copy(io.Discard, stderr)
+}
@@ -0,0 +1,261 @@
+package cli_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vscodethemes/backend/internal/cli"
+)
+
+// fakeRendererEnv makes the test binary run as a renderer process. Its value is a file each
+// process appends a line to when it starts.
+const fakeRendererEnv = "CLI_TEST_FAKE_RENDERER"
+
+func TestMain(m *testing.M) {
+	if startsFile := os.Getenv(fakeRendererEnv); startsFile != "" {
+		if err := runFakeRenderer(startsFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// runFakeRenderer answers requests concurrently, so responses are written in the order they're
+// ready. The theme path tells it how to answer:
+//
+//	sleep/<duration>  answers after the duration
+//	crash             exits without answering
+//	error/<code>      answers with a CLI error
+func runFakeRenderer(startsFile string) error {
+	starts, err := os.OpenFile(startsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(starts, os.Getpid())
+	starts.Close()
+
+	var writeMu sync.Mutex
+	var pending sync.WaitGroup
+	respond := func(response map[string]any) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		json.NewEncoder(os.Stdout).Encode(response)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var request struct {
+			ID     int64  `json:"id"`
+			Method string `json:"method"`
+			Params struct {
+				ThemeContribute cli.ThemeContribute `json:"themeContribute"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			return err
+		}
+
+		if request.Method == "ping" {
+			respond(map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": "pong"})
+			continue
+		}
+
+		themePath := request.Params.ThemeContribute.Path
+		if themePath == "crash" {
+			os.Exit(1)
+		}
+
+		pending.Add(1)
+		go func() {
+			defer pending.Done()
+
+			if delay, ok := strings.CutPrefix(themePath, "sleep/"); ok {
+				d, _ := time.ParseDuration(delay)
+				time.Sleep(d)
+			}
+
+			if code, ok := strings.CutPrefix(themePath, "error/"); ok {
+				respond(map[string]any{"jsonrpc": "2.0", "id": request.ID, "error": map[string]any{
+					"code":    -32000,
+					"message": "failed",
+					"data":    map[string]any{"code": code},
+				}})
+				return
+			}
+
+			respond(map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": cli.GenerateImagesResult{
+				Theme: cli.Theme{Path: themePath},
+			}})
+		}()
+	}
+
+	// Answer pending requests before exiting, like the renderer does once stdin is closed.
+	pending.Wait()
+	return scanner.Err()
+}
+
+// newRenderer starts a renderer running the fake renderer process. It returns a function that
+// returns the number of processes started.
+func newRenderer(t *testing.T, opts ...cli.RendererOption) (*cli.Renderer, func() int) {
+	t.Helper()
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to get test executable: %v", err)
+	}
+
+	startsFile := filepath.Join(t.TempDir(), "starts")
+	t.Setenv(fakeRendererEnv, startsFile)
+
+	renderer := cli.NewRenderer(append([]cli.RendererOption{cli.WithCommand(executable)}, opts...)...)
+	renderer.Dir = t.TempDir()
+	if err := renderer.Start(); err != nil {
+		t.Fatalf("failed to start renderer: %v", err)
+	}
+	t.Cleanup(func() { renderer.Close() })
+
+	return renderer, func() int {
+		data, _ := os.ReadFile(startsFile)
+		return strings.Count(string(data), "\n")
+	}
+}
+
+func generateImages(t *testing.T, renderer *cli.Renderer, themePath string) (*cli.GenerateImagesResult, error) {
+	t.Helper()
+	return renderer.GenerateImages(context.Background(), t.TempDir(), cli.ThemeContribute{Path: themePath}, t.TempDir())
+}
+
+func TestRendererMatchesResponses(t *testing.T) {
+	renderer, _ := newRenderer(t)
+
+	// The first request is answered last.
+	paths := []string{"sleep/300ms", "sleep/150ms", "sleep/0s", "sleep/50ms"}
+
+	var wg sync.WaitGroup
+	results := make([]string, len(paths))
+	errs := make([]error, len(paths))
+	for i, path := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := generateImages(t, renderer, path)
+			if err == nil {
+				results[i] = result.Theme.Path
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+
+	for i, path := range paths {
+		if errs[i] != nil {
+			t.Errorf("request for %s failed: %v", path, errs[i])
+		} else if results[i] != path {
+			t.Errorf("expected the response for %s, got the response for %s", path, results[i])
+		}
+	}
+}
+
+func TestRendererTimeout(t *testing.T) {
+	renderer, starts := newRenderer(t, cli.WithRequestTimeout(100*time.Millisecond))
+
+	_, err := generateImages(t, renderer, "sleep/300ms")
+	if !errors.Is(err, cli.ErrRendererTimeout) {
+		t.Fatalf("expected ErrRendererTimeout, got %v", err)
+	}
+
+	// The late response is dropped and doesn't answer the next request.
+	time.Sleep(300 * time.Millisecond)
+	result, err := generateImages(t, renderer, "next")
+	if err != nil {
+		t.Fatalf("expected the next request to succeed, got %v", err)
+	}
+	if result.Theme.Path != "next" {
+		t.Errorf("expected the response for next, got the response for %s", result.Theme.Path)
+	}
+
+	if n := starts(); n != 1 {
+		t.Errorf("expected a timeout not to restart the renderer, started %d times", n)
+	}
+}
+
+func TestRendererRestartsAfterCrash(t *testing.T) {
+	renderer, starts := newRenderer(t)
+
+	_, err := generateImages(t, renderer, "crash")
+	if !errors.Is(err, cli.ErrRendererExited) {
+		t.Fatalf("expected ErrRendererExited, got %v", err)
+	}
+	if cli.IsPermanent(err) {
+		t.Errorf("expected a crash to be retryable")
+	}
+
+	result, err := generateImages(t, renderer, "after-crash")
+	if err != nil {
+		t.Fatalf("expected the renderer to be restarted, got %v", err)
+	}
+	if result.Theme.Path != "after-crash" {
+		t.Errorf("expected the response for after-crash, got the response for %s", result.Theme.Path)
+	}
+
+	if n := starts(); n != 2 {
+		t.Errorf("expected the renderer to be started twice, started %d times", n)
+	}
+}
+
+func TestRendererErrors(t *testing.T) {
+	renderer, _ := newRenderer(t)
+
+	_, err := generateImages(t, renderer, "error/invalid_theme")
+	var cliErr *cli.Error
+	if !errors.As(err, &cliErr) || cliErr.Code != cli.ErrorCodeInvalidTheme {
+		t.Fatalf("expected an invalid theme error, got %v", err)
+	}
+	if !cli.IsPermanent(err) {
+		t.Errorf("expected an invalid theme to be permanent")
+	}
+
+	_, err = generateImages(t, renderer, "error/render_failure")
+	if !errors.As(err, &cliErr) || cliErr.Code != cli.ErrorCodeRenderFailure {
+		t.Fatalf("expected a render failure, got %v", err)
+	}
+}
+
+func TestRendererCloseAnswersPendingRequests(t *testing.T) {
+	renderer, _ := newRenderer(t)
+	if err := renderer.Ping(context.Background()); err != nil {
+		t.Fatalf("failed to ping renderer: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := generateImages(t, renderer, "sleep/200ms")
+		done <- err
+	}()
+
+	// Give the request time to be written before closing.
+	time.Sleep(50 * time.Millisecond)
+	if err := renderer.Close(); err != nil {
+		t.Fatalf("failed to close renderer: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("expected the pending request to be answered, got %v", err)
+	}
+
+	if _, err := generateImages(t, renderer, "after-close"); !errors.Is(err, cli.ErrRendererClosed) {
+		t.Errorf("expected ErrRendererClosed, got %v", err)
+	}
+}
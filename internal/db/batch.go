@@ -0,0 +1,82 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: batch.go
+
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrBatchAlreadyClosed = errors.New("batch already closed")
+)
+
+const updateExtensionStats = `-- name: UpdateExtensionStats :batchexec
+update "extensions" set
+  "installs" = $1,
+  "trending_daily" = $2,
+  "trending_weekly" = $3,
+  "trending_monthly" = $4,
+  "weighted_rating" = $5,
+  "updated_at" = now()
+where "vsc_extension_id" = $6
+`
+
+type UpdateExtensionStatsBatchResults struct {
+	br     pgx.BatchResults
+	tot    int
+	closed bool
+}
+
+type UpdateExtensionStatsParams struct {
+	Installs        int32
+	TrendingDaily   pgtype.Numeric
+	TrendingWeekly  pgtype.Numeric
+	TrendingMonthly pgtype.Numeric
+	WeightedRating  pgtype.Numeric
+	VscExtensionID  string
+}
+
+func (q *Queries) UpdateExtensionStats(ctx context.Context, arg []UpdateExtensionStatsParams) *UpdateExtensionStatsBatchResults {
+	batch := &pgx.Batch{}
+	for _, a := range arg {
+		vals := []interface{}{
+			a.Installs,
+			a.TrendingDaily,
+			a.TrendingWeekly,
+			a.TrendingMonthly,
+			a.WeightedRating,
+			a.VscExtensionID,
+		}
+		batch.Queue(updateExtensionStats, vals...)
+	}
+	br := q.db.SendBatch(ctx, batch)
+	return &UpdateExtensionStatsBatchResults{br, len(arg), false}
+}
+
+func (b *UpdateExtensionStatsBatchResults) Exec(f func(int, error)) {
+	defer b.br.Close()
+	for t := 0; t < b.tot; t++ {
+		if b.closed {
+			if f != nil {
+				f(t, ErrBatchAlreadyClosed)
+			}
+			continue
+		}
+		_, err := b.br.Exec()
+		if f != nil {
+			f(t, err)
+		}
+	}
+}
+
+func (b *UpdateExtensionStatsBatchResults) Close() error {
+	b.closed = true
+	return b.br.Close()
+}
@@ -11,6 +11,92 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const markExtensionRemoved = `-- name: MarkExtensionRemoved :execrows
+update "extensions" set
+  "removed_at" = now(),
+  "updated_at" = now()
+where "name" = $1
+  and "publisher_name" = $2
+  and "registry" = $3
+  and "removed_at" is null
+`
+
+type MarkExtensionRemovedParams struct {
+	ExtensionName string
+	PublisherName string
+	Registry      string
+}
+
+func (q *Queries) MarkExtensionRemoved(ctx context.Context, arg MarkExtensionRemovedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, markExtensionRemoved, arg.ExtensionName, arg.PublisherName, arg.Registry)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const restoreExtension = `-- name: RestoreExtension :execrows
+update "extensions" set
+  "removed_at" = null,
+  "updated_at" = now()
+where "name" = $1
+  and "publisher_name" = $2
+  and "registry" = $3
+  and "removed_at" is not null
+`
+
+type RestoreExtensionParams struct {
+	ExtensionName string
+	PublisherName string
+	Registry      string
+}
+
+func (q *Queries) RestoreExtension(ctx context.Context, arg RestoreExtensionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, restoreExtension, arg.ExtensionName, arg.PublisherName, arg.Registry)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const setExtensionPreReleaseVersion = `-- name: SetExtensionPreReleaseVersion :exec
+update "extensions" set
+  "pre_release_version" = $1,
+  "pre_release_version_id" = $2,
+  "updated_at" = now()
+where "id" = $3
+`
+
+type SetExtensionPreReleaseVersionParams struct {
+	PreReleaseVersion   pgtype.Text
+	PreReleaseVersionID pgtype.Int8
+	ID                  int64
+}
+
+func (q *Queries) SetExtensionPreReleaseVersion(ctx context.Context, arg SetExtensionPreReleaseVersionParams) error {
+	_, err := q.db.Exec(ctx, setExtensionPreReleaseVersion, arg.PreReleaseVersion, arg.PreReleaseVersionID, arg.ID)
+	return err
+}
+
+const setExtensionVersion = `-- name: SetExtensionVersion :exec
+update "extensions" set
+  "version" = $1,
+  "version_id" = $2,
+  "updated_at" = now()
+where "id" = $3
+`
+
+type SetExtensionVersionParams struct {
+	Version   string
+	VersionID pgtype.Int8
+	ID        int64
+}
+
+func (q *Queries) SetExtensionVersion(ctx context.Context, arg SetExtensionVersionParams) error {
+	_, err := q.db.Exec(ctx, setExtensionVersion, arg.Version, arg.VersionID, arg.ID)
+	return err
+}
+
 const upsertExtension = `-- name: UpsertExtension :one
 insert into "extensions" (
   "vsc_extension_id", 
@@ -26,7 +112,8 @@ insert into "extensions" (
   "trending_monthly",
   "weighted_rating",
   "published_at",
-  "released_at"
+  "released_at",
+  "registry"
 )
 values (
   $1, 
@@ -42,7 +129,8 @@ values (
   $11,
   $12,
   $13,
-  $14
+  $14,
+  $15
 )
 on conflict("vsc_extension_id") do update set
   "name" = excluded."name",
@@ -58,8 +146,10 @@ on conflict("vsc_extension_id") do update set
   "weighted_rating" = excluded."weighted_rating",
   "published_at" = excluded."published_at",
   "released_at" = excluded."released_at",
+  "registry" = excluded."registry",
+  "removed_at" = null,
   "updated_at" = now()
-returning id, vsc_extension_id, name, display_name, short_description, publisher_id, publisher_name, publisher_display_name, installs, trending_daily, trending_weekly, trending_monthly, weighted_rating, published_at, released_at, created_at, updated_at
+returning id, vsc_extension_id, name, display_name, short_description, publisher_id, publisher_name, publisher_display_name, installs, trending_daily, trending_weekly, trending_monthly, weighted_rating, published_at, released_at, created_at, updated_at, registry, removed_at, version, pre_release_version, version_id, pre_release_version_id
 `
 
 type UpsertExtensionParams struct {
@@ -77,6 +167,7 @@ type UpsertExtensionParams struct {
 	WeightedRating       pgtype.Numeric
 	PublishedAt          pgtype.Timestamp
 	ReleasedAt           pgtype.Timestamp
+	Registry             string
 }
 
 func (q *Queries) UpsertExtension(ctx context.Context, arg UpsertExtensionParams) (Extension, error) {
@@ -95,6 +186,7 @@ func (q *Queries) UpsertExtension(ctx context.Context, arg UpsertExtensionParams
 		arg.WeightedRating,
 		arg.PublishedAt,
 		arg.ReleasedAt,
+		arg.Registry,
 	)
 	var i Extension
 	err := row.Scan(
@@ -115,6 +207,12 @@ func (q *Queries) UpsertExtension(ctx context.Context, arg UpsertExtensionParams
 		&i.ReleasedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Registry,
+		&i.RemovedAt,
+		&i.Version,
+		&i.PreReleaseVersion,
+		&i.VersionID,
+		&i.PreReleaseVersionID,
 	)
 	return i, err
 }
@@ -12,13 +12,15 @@ import (
 )
 
 const getAllExtensionsForUpdate = `-- name: GetAllExtensionsForUpdate :many
-SELECT e.name, e.publisher_name
+SELECT e.name, e.publisher_name, e.registry
 FROM extensions e
+WHERE e.removed_at IS NULL
 `
 
 type GetAllExtensionsForUpdateRow struct {
 	Name          string
 	PublisherName string
+	Registry      string
 }
 
 func (q *Queries) GetAllExtensionsForUpdate(ctx context.Context) ([]GetAllExtensionsForUpdateRow, error) {
@@ -30,7 +32,7 @@ func (q *Queries) GetAllExtensionsForUpdate(ctx context.Context) ([]GetAllExtens
 	var items []GetAllExtensionsForUpdateRow
 	for rows.Next() {
 		var i GetAllExtensionsForUpdateRow
-		if err := rows.Scan(&i.Name, &i.PublisherName); err != nil {
+		if err := rows.Scan(&i.Name, &i.PublisherName, &i.Registry); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -49,14 +51,17 @@ SELECT
 	e.publisher_display_name,
 	e.short_description,
 	e.published_at,
+	e.removed_at,
+	e.version,
+	e.pre_release_version,
 	jsonb_agg(json_build_object(
 		'name', t.name,
 		'display_name', t.display_name,
 		'url', i.url
 	)) AS themes
 FROM extensions e
-LEFT JOIN themes t ON t.extension_id = e.id
-LEFT JOIN images i ON i.theme_id = t.id
+LEFT JOIN themes t ON t.extension_version_id = e.version_id
+LEFT JOIN images i ON i.theme_id = t.id AND i.type = 'preview' AND i.format = 'svg'
 WHERE 
 	e.name = $1
 	AND e.publisher_name = $2
@@ -77,6 +82,9 @@ type GetExtensionRow struct {
 	PublisherDisplayName string
 	ShortDescription     pgtype.Text
 	PublishedAt          pgtype.Timestamp
+	RemovedAt            pgtype.Timestamp
+	Version              string
+	PreReleaseVersion    pgtype.Text
 	Themes               []byte
 }
 
@@ -90,11 +98,34 @@ func (q *Queries) GetExtension(ctx context.Context, arg GetExtensionParams) (Get
 		&i.PublisherDisplayName,
 		&i.ShortDescription,
 		&i.PublishedAt,
+		&i.RemovedAt,
+		&i.Version,
+		&i.PreReleaseVersion,
 		&i.Themes,
 	)
 	return i, err
 }
 
+const getExtensionRegistry = `-- name: GetExtensionRegistry :one
+SELECT e.registry
+FROM extensions e
+WHERE 
+	e.name = $1
+	AND e.publisher_name = $2
+`
+
+type GetExtensionRegistryParams struct {
+	ExtensionName string
+	PublisherName string
+}
+
+func (q *Queries) GetExtensionRegistry(ctx context.Context, arg GetExtensionRegistryParams) (string, error) {
+	row := q.db.QueryRow(ctx, getExtensionRegistry, arg.ExtensionName, arg.PublisherName)
+	var registry string
+	err := row.Scan(&registry)
+	return registry, err
+}
+
 const listExtensions = `-- name: ListExtensions :many
 SELECT 
 	e.name,
@@ -109,8 +140,8 @@ SELECT
 		'url', i.url
 	)) AS themes
 FROM extensions e
-LEFT JOIN themes t ON t.extension_id = e.id
-LEFT JOIN images i ON i.theme_id = t.id
+LEFT JOIN themes t ON t.extension_version_id = e.version_id
+LEFT JOIN images i ON i.theme_id = t.id AND i.type = 'preview' AND i.format = 'svg'
 WHERE i.language = $1
 GROUP BY e.id
 `
@@ -3,20 +3,26 @@ package db
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type SearchExtensionsParams struct {
-	Text                 string
-	EditorBackground     string
+	Text      string
+	Color     string
+	ColorSlot string
+	// TokenColors are the Lab colors to search for by token category.
+	TokenColors          map[string]string
 	Language             string
 	SortBy               string
 	ColorDistance        int
 	PublisherName        string
 	ExtensionName        string
 	ThemeName            string
+	PreRelease           bool
 	ExtensionsPageNumber int
 	ExtensionsPageSize   int
 	ThemesPageNumber     int
@@ -36,41 +42,57 @@ type SearchExtensionsRow struct {
 }
 
 type SearchExtensionsThemePartial struct {
-	Name                       string `json:"name"`
-	URL                        string `json:"url"`
-	DisplayName                string `json:"display_name"`
-	EditorBackground           string `json:"editor_background"`
-	ActivityBarBadgeBackground string `json:"activity_bar_badge_background"`
+	Name                       string                  `json:"name"`
+	URL                        string                  `json:"url"`
+	DisplayName                string                  `json:"display_name"`
+	EditorBackground           string                  `json:"editor_background"`
+	ActivityBarBadgeBackground string                  `json:"activity_bar_badge_background"`
+	Srcset                     []SearchExtensionsImage `json:"srcset"`
 }
 
 type SearchExtensionsTheme struct {
-	Name                          string  `json:"name"`
-	URL                           string  `json:"url"`
-	DisplayName                   string  `json:"display_name"`
-	EditorBackground              string  `json:"editor_background"`
-	EditorForeground              string  `json:"editor_foreground"`
-	ActivityBarBackground         string  `json:"activity_bar_background"`
-	ActivityBarForeground         string  `json:"activity_bar_foreground"`
-	ActivityBarInActiveForeground string  `json:"activity_bar_in_active_foreground"`
-	ActivityBarBorder             *string `json:"activity_bar_border"`
-	ActivityBarActiveBorder       string  `json:"activity_bar_active_border"`
-	ActivityBarActiveBackground   *string `json:"activity_bar_active_background"`
-	ActivityBarBadgeBackground    string  `json:"activity_bar_badge_background"`
-	ActivityBarBadgeForeground    string  `json:"activity_bar_badge_foreground"`
-	TabsContainerBackground       *string `json:"tabs_container_background"`
-	TabsContainerBorder           *string `json:"tabs_container_border"`
-	StatusBarBackground           *string `json:"status_bar_background"`
-	StatusBarForeground           string  `json:"status_bar_foreground"`
-	StatusBarBorder               *string `json:"status_bar_border"`
-	TabActiveBackground           *string `json:"tab_active_background"`
-	TabInactiveBackground         *string `json:"tab_inactive_background"`
-	TabActiveForeground           string  `json:"tab_active_foreground"`
-	TabBorder                     string  `json:"tab_border"`
-	TabActiveBorder               *string `json:"tab_active_border"`
-	TabActiveBorderTop            *string `json:"tab_active_border_top"`
-	TitleBarActiveBackground      string  `json:"title_bar_active_background"`
-	TitleBarActiveForeground      string  `json:"title_bar_active_foreground"`
-	TitleBarBorder                *string `json:"title_bar_border"`
+	Name                          string                                `json:"name"`
+	URL                           string                                `json:"url"`
+	DisplayName                   string                                `json:"display_name"`
+	EditorBackground              string                                `json:"editor_background"`
+	EditorForeground              string                                `json:"editor_foreground"`
+	ActivityBarBackground         string                                `json:"activity_bar_background"`
+	ActivityBarForeground         string                                `json:"activity_bar_foreground"`
+	ActivityBarInActiveForeground string                                `json:"activity_bar_in_active_foreground"`
+	ActivityBarBorder             *string                               `json:"activity_bar_border"`
+	ActivityBarActiveBorder       string                                `json:"activity_bar_active_border"`
+	ActivityBarActiveBackground   *string                               `json:"activity_bar_active_background"`
+	ActivityBarBadgeBackground    string                                `json:"activity_bar_badge_background"`
+	ActivityBarBadgeForeground    string                                `json:"activity_bar_badge_foreground"`
+	TabsContainerBackground       *string                               `json:"tabs_container_background"`
+	TabsContainerBorder           *string                               `json:"tabs_container_border"`
+	StatusBarBackground           *string                               `json:"status_bar_background"`
+	StatusBarForeground           string                                `json:"status_bar_foreground"`
+	StatusBarBorder               *string                               `json:"status_bar_border"`
+	TabActiveBackground           *string                               `json:"tab_active_background"`
+	TabInactiveBackground         *string                               `json:"tab_inactive_background"`
+	TabActiveForeground           string                                `json:"tab_active_foreground"`
+	TabBorder                     string                                `json:"tab_border"`
+	TabActiveBorder               *string                               `json:"tab_active_border"`
+	TabActiveBorderTop            *string                               `json:"tab_active_border_top"`
+	TitleBarActiveBackground      string                                `json:"title_bar_active_background"`
+	TitleBarActiveForeground      string                                `json:"title_bar_active_foreground"`
+	TitleBarBorder                *string                               `json:"title_bar_border"`
+	Colors                        map[string]string                     `json:"colors"`
+	TokenColors                   map[string]SearchExtensionsTokenColor `json:"token_colors"`
+	Srcset                        []SearchExtensionsImage               `json:"srcset"`
+}
+
+// SearchExtensionsImage is a raster preview of a theme.
+type SearchExtensionsImage struct {
+	URL    string `json:"url"`
+	Format string `json:"format"`
+	Width  int    `json:"width"`
+}
+
+type SearchExtensionsTokenColor struct {
+	Color     string `json:"color"`
+	FontStyle string `json:"font_style"`
 }
 
 func (q *Queries) SearchExtensions(ctx context.Context, arg SearchExtensionsParams) ([]SearchExtensionsRow, error) {
@@ -89,6 +111,20 @@ func (q *Queries) SearchExtensions(ctx context.Context, arg SearchExtensionsPara
 		orderBy = "updated_at DESC"
 	}
 
+	namedArgs := pgx.NamedArgs{}
+
+	// Themes are as far from the token colors as the sum of the distances of each category, and
+	// must be within the color distance of each.
+	tokenDistance := ""
+	tokenFilter := ""
+	for i, category := range slices.Sorted(maps.Keys(arg.TokenColors)) {
+		distance := fmt.Sprintf("(SELECT @token_color_%[1]d::cube <-> tc.color FROM theme_token_colors tc WHERE tc.theme_id = t.id AND tc.category = @token_category_%[1]d)", i)
+		tokenDistance += " + " + distance
+		tokenFilter += fmt.Sprintf("\n\t\t\tAND\n\t\t\t\t%s <= @color_distance", distance)
+		namedArgs[fmt.Sprintf("token_category_%d", i)] = category
+		namedArgs[fmt.Sprintf("token_color_%d", i)] = arg.TokenColors[category]
+	}
+
 	var searchExtensions = fmt.Sprintf(`
 	SELECT
 		r.total, 
@@ -110,8 +146,8 @@ func (q *Queries) SearchExtensions(ctx context.Context, arg SearchExtensionsPara
 					WHEN @text = '' THEN 0 
 					ELSE TS_RANK_CD(t.tsv, query, 32) END AS text_rank,
 				CASE 
-					WHEN @editor_background = '' THEN 0 
-					ELSE (@editor_background::cube <-> t.editor_background) END AS color_distance,
+					WHEN @color = '' THEN 0 
+					ELSE (@color::cube <-> s.color) END%[2]s AS color_distance,
 				ROW_NUMBER() OVER(
 					PARTITION BY t.extension_id 
 					ORDER BY
@@ -119,8 +155,8 @@ func (q *Queries) SearchExtensions(ctx context.Context, arg SearchExtensionsPara
 							WHEN @text = '' THEN 0 
 							ELSE TS_RANK_CD(t.tsv, query, 32) END DESC,
 						CASE
-							WHEN @editor_background = '' THEN 0
-							ELSE (@editor_background::cube <-> t.editor_background) END ASC,
+							WHEN @color = '' THEN 0
+							ELSE (@color::cube <-> s.color) END%[2]s ASC,
 						t.name ASC
 				) AS row_number,
 				t.id,
@@ -132,8 +168,13 @@ func (q *Queries) SearchExtensions(ctx context.Context, arg SearchExtensionsPara
 				e.weighted_rating,
 				e.updated_at
 			FROM themes t
-			LEFT JOIN extensions e ON e.id = t.extension_id, WEBSEARCH_TO_TSQUERY(@text) query
+			LEFT JOIN extensions e ON e.id = t.extension_id
+			LEFT JOIN theme_color_slots s ON s.theme_id = t.id AND s.slot = @color_slot, WEBSEARCH_TO_TSQUERY(@text) query
 			WHERE
+				e.removed_at IS NULL
+			AND
+				t.extension_version_id = CASE WHEN @pre_release THEN e.pre_release_version_id ELSE e.version_id END
+			AND
 				CASE WHEN @publisher_name = '' then true
 				ELSE e.publisher_name = @publisher_name END
 			AND 
@@ -145,12 +186,12 @@ func (q *Queries) SearchExtensions(ctx context.Context, arg SearchExtensionsPara
 					ELSE query @@ t.tsv END
 			AND 
 				CASE 
-					WHEN @editor_background = '' THEN true 
-					ELSE @editor_background::cube <-> t.editor_background <= @color_distance END
+					WHEN @color = '' THEN true 
+					ELSE @color::cube <-> s.color <= @color_distance END%[3]s
 		)
 		SELECT 
 			COUNT(*) OVER() total, 
-			ROW_NUMBER() OVER(ORDER BY %s) AS row_number, 
+			ROW_NUMBER() OVER(ORDER BY %[1]s) AS row_number, 
 			extension_id, 
 			color_distance
 		FROM results
@@ -167,17 +208,24 @@ func (q *Queries) SearchExtensions(ctx context.Context, arg SearchExtensionsPara
 			t.display_name,
 			t.editor_background,
 			t.activity_bar_badge_background,
+			(
+				SELECT COALESCE(jsonb_agg(jsonb_build_object('url', ri.url, 'format', ri.format, 'width', ri.width) ORDER BY ri.format, ri.width), '[]')
+				FROM images ri
+				WHERE ri.theme_id = t.id AND ri.language = @language AND ri.type = 'preview' AND ri.format != 'svg'
+			) AS srcset,
 			i.url
 		FROM themes t
 		JOIN images i ON i.theme_id = t.id AND i.language = @language AND i.type = 'preview' AND i.format = 'svg'
+		LEFT JOIN theme_color_slots s ON s.theme_id = t.id AND s.slot = @color_slot
 		WHERE e.id = t.extension_id
+		AND t.extension_version_id = CASE WHEN @pre_release THEN e.pre_release_version_id ELSE e.version_id END
 		AND
 			CASE WHEN @theme_name = '' then true
 			ELSE t.name != @theme_name END
 		ORDER BY
 			CASE
-				WHEN @editor_background = '' THEN 0
-				ELSE (@editor_background::cube <-> t.editor_background) END ASC,
+				WHEN @color = '' THEN 0
+				ELSE (@color::cube <-> s.color) END%[2]s ASC,
 			t.name ASC
 		OFFSET @themes_offset
 		LIMIT @themes_limit
@@ -211,35 +259,49 @@ func (q *Queries) SearchExtensions(ctx context.Context, arg SearchExtensionsPara
 			t.title_bar_active_background,
 			t.title_bar_active_foreground,
 			t.title_bar_border,
+			t.colors,
+			(
+				SELECT COALESCE(jsonb_object_agg(tc.category, jsonb_build_object('color', tc.color, 'font_style', tc.font_style)), '{}')
+				FROM theme_token_colors tc
+				WHERE tc.theme_id = t.id
+			) AS token_colors,
+			(
+				SELECT COALESCE(jsonb_agg(jsonb_build_object('url', ri.url, 'format', ri.format, 'width', ri.width) ORDER BY ri.format, ri.width), '[]')
+				FROM images ri
+				WHERE ri.theme_id = t.id AND ri.language = @language AND ri.type = 'preview' AND ri.format != 'svg'
+			) AS srcset,
 			i.url
 		FROM themes t
 		JOIN images i ON i.theme_id = t.id AND i.language = @language AND i.type = 'preview' AND i.format = 'svg'
 		WHERE e.id = t.extension_id
+		AND t.extension_version_id = CASE WHEN @pre_release THEN e.pre_release_version_id ELSE e.version_id END
 		AND t.name = @theme_name
 		OFFSET 0
 		LIMIT 1
 	) t3 ON t3.extension_id = e.id AND @theme_name != ''
 	GROUP BY r.total, r.row_number, e.id
 	ORDER BY r.row_number ASC
-	`, orderBy)
+	`, orderBy, tokenDistance, tokenFilter)
 
 	// Page numbers start at 1.
 	extensionsOffset := (arg.ExtensionsPageNumber - 1) * arg.ExtensionsPageSize
 	themesOffset := (arg.ThemesPageNumber - 1) * arg.ThemesPageSize
 
-	rows, err := q.db.Query(ctx, searchExtensions, pgx.NamedArgs{
-		"text":              arg.Text,
-		"editor_background": arg.EditorBackground,
-		"language":          arg.Language,
-		"color_distance":    arg.ColorDistance,
-		"publisher_name":    arg.PublisherName,
-		"extension_name":    arg.ExtensionName,
-		"theme_name":        arg.ThemeName,
-		"extensions_offset": extensionsOffset,
-		"extensions_limit":  arg.ExtensionsPageSize,
-		"themes_offset":     themesOffset,
-		"themes_limit":      arg.ThemesPageSize,
-	})
+	namedArgs["text"] = arg.Text
+	namedArgs["color"] = arg.Color
+	namedArgs["color_slot"] = arg.ColorSlot
+	namedArgs["language"] = arg.Language
+	namedArgs["color_distance"] = arg.ColorDistance
+	namedArgs["publisher_name"] = arg.PublisherName
+	namedArgs["extension_name"] = arg.ExtensionName
+	namedArgs["theme_name"] = arg.ThemeName
+	namedArgs["pre_release"] = arg.PreRelease
+	namedArgs["extensions_offset"] = extensionsOffset
+	namedArgs["extensions_limit"] = arg.ExtensionsPageSize
+	namedArgs["themes_offset"] = themesOffset
+	namedArgs["themes_limit"] = arg.ThemesPageSize
+
+	rows, err := q.db.Query(ctx, searchExtensions, namedArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,79 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: extension_version_mutations.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const upsertExtensionVersion = `-- name: UpsertExtensionVersion :one
+insert into "extension_versions" (
+  "extension_id",
+  "version",
+  "pre_release",
+  "target_platform",
+  "asset_uri",
+  "package_hash",
+  "signature_status"
+)
+values (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7
+)
+on conflict("extension_id", "version") do update set
+  "pre_release" = excluded."pre_release",
+  "target_platform" = excluded."target_platform",
+  "asset_uri" = excluded."asset_uri",
+  "package_hash" = excluded."package_hash",
+  "signature_status" = excluded."signature_status",
+  "synced_at" = now(),
+  "updated_at" = now()
+returning id, extension_id, version, pre_release, target_platform, asset_uri, package_hash, synced_at, created_at, updated_at, signature_status
+`
+
+type UpsertExtensionVersionParams struct {
+	ExtensionID     int64
+	Version         string
+	PreRelease      bool
+	TargetPlatform  pgtype.Text
+	AssetUri        pgtype.Text
+	PackageHash     pgtype.Text
+	SignatureStatus pgtype.Text
+}
+
+func (q *Queries) UpsertExtensionVersion(ctx context.Context, arg UpsertExtensionVersionParams) (ExtensionVersion, error) {
+	row := q.db.QueryRow(ctx, upsertExtensionVersion,
+		arg.ExtensionID,
+		arg.Version,
+		arg.PreRelease,
+		arg.TargetPlatform,
+		arg.AssetUri,
+		arg.PackageHash,
+		arg.SignatureStatus,
+	)
+	var i ExtensionVersion
+	err := row.Scan(
+		&i.ID,
+		&i.ExtensionID,
+		&i.Version,
+		&i.PreRelease,
+		&i.TargetPlatform,
+		&i.AssetUri,
+		&i.PackageHash,
+		&i.SyncedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.SignatureStatus,
+	)
+	return i, err
+}
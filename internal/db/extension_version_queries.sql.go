@@ -0,0 +1,171 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: extension_version_queries.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const getExtensionSyncedVersion = `-- name: GetExtensionSyncedVersion :one
+SELECT
+	v.version,
+	e.removed_at
+FROM extensions e
+JOIN extension_versions v ON v.id = CASE WHEN $1::boolean THEN e.pre_release_version_id ELSE e.version_id END
+WHERE 
+	e.name = $2
+	AND e.publisher_name = $3
+`
+
+type GetExtensionSyncedVersionParams struct {
+	PreRelease    bool
+	ExtensionName string
+	PublisherName string
+}
+
+type GetExtensionSyncedVersionRow struct {
+	Version   string
+	RemovedAt pgtype.Timestamp
+}
+
+func (q *Queries) GetExtensionSyncedVersion(ctx context.Context, arg GetExtensionSyncedVersionParams) (GetExtensionSyncedVersionRow, error) {
+	row := q.db.QueryRow(ctx, getExtensionSyncedVersion, arg.PreRelease, arg.ExtensionName, arg.PublisherName)
+	var i GetExtensionSyncedVersionRow
+	err := row.Scan(&i.Version, &i.RemovedAt)
+	return i, err
+}
+
+const listExtensionVersionThemes = `-- name: ListExtensionVersionThemes :many
+SELECT
+	t.name,
+	t.display_name,
+	i.url,
+	(
+		SELECT COALESCE(jsonb_agg(jsonb_build_object('url', ri.url, 'format', ri.format, 'width', ri.width) ORDER BY ri.format, ri.width), '[]')
+		FROM images ri
+		WHERE ri.theme_id = t.id AND ri.language = $1 AND ri.type = 'preview' AND ri.format != 'svg'
+	)::jsonb AS srcset
+FROM extension_versions v
+JOIN extensions e ON e.id = v.extension_id
+JOIN themes t ON t.extension_version_id = v.id
+JOIN images i ON i.theme_id = t.id AND i.language = $1 AND i.type = 'preview' AND i.format = 'svg'
+WHERE 
+	e.name = $2
+	AND e.publisher_name = $3
+	AND v.version = $4
+	AND e.removed_at IS NULL
+ORDER BY t.name ASC
+`
+
+type ListExtensionVersionThemesParams struct {
+	Language      string
+	ExtensionName string
+	PublisherName string
+	Version       string
+}
+
+type ListExtensionVersionThemesRow struct {
+	Name        string
+	DisplayName string
+	Url         string
+	Srcset      []byte
+}
+
+func (q *Queries) ListExtensionVersionThemes(ctx context.Context, arg ListExtensionVersionThemesParams) ([]ListExtensionVersionThemesRow, error) {
+	rows, err := q.db.Query(ctx, listExtensionVersionThemes,
+		arg.Language,
+		arg.ExtensionName,
+		arg.PublisherName,
+		arg.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListExtensionVersionThemesRow
+	for rows.Next() {
+		var i ListExtensionVersionThemesRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.DisplayName,
+			&i.Url,
+			&i.Srcset,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listExtensionVersions = `-- name: ListExtensionVersions :many
+SELECT
+	v.version,
+	v.pre_release,
+	v.target_platform,
+	v.asset_uri,
+	v.package_hash,
+	v.signature_status,
+	v.synced_at,
+	(SELECT COUNT(*) FROM themes t WHERE t.extension_version_id = v.id) AS total_themes
+FROM extension_versions v
+JOIN extensions e ON e.id = v.extension_id
+WHERE 
+	e.name = $1
+	AND e.publisher_name = $2
+	AND e.removed_at IS NULL
+ORDER BY v.synced_at DESC
+`
+
+type ListExtensionVersionsParams struct {
+	ExtensionName string
+	PublisherName string
+}
+
+type ListExtensionVersionsRow struct {
+	Version         string
+	PreRelease      bool
+	TargetPlatform  pgtype.Text
+	AssetUri        pgtype.Text
+	PackageHash     pgtype.Text
+	SignatureStatus pgtype.Text
+	SyncedAt        pgtype.Timestamp
+	TotalThemes     int64
+}
+
+func (q *Queries) ListExtensionVersions(ctx context.Context, arg ListExtensionVersionsParams) ([]ListExtensionVersionsRow, error) {
+	rows, err := q.db.Query(ctx, listExtensionVersions, arg.ExtensionName, arg.PublisherName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListExtensionVersionsRow
+	for rows.Next() {
+		var i ListExtensionVersionsRow
+		if err := rows.Scan(
+			&i.Version,
+			&i.PreRelease,
+			&i.TargetPlatform,
+			&i.AssetUri,
+			&i.PackageHash,
+			&i.SignatureStatus,
+			&i.SyncedAt,
+			&i.TotalThemes,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
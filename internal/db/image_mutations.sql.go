@@ -7,44 +7,75 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const deleteThemeImagesNotIn = `-- name: DeleteThemeImagesNotIn :exec
+delete from "images"
+where "theme_id" = $1
+and "id" != all($2::bigint[])
+`
+
+type DeleteThemeImagesNotInParams struct {
+	ThemeID  int64
+	ImageIds []int64
+}
+
+func (q *Queries) DeleteThemeImagesNotIn(ctx context.Context, arg DeleteThemeImagesNotInParams) error {
+	_, err := q.db.Exec(ctx, deleteThemeImagesNotIn, arg.ThemeID, arg.ImageIds)
+	return err
+}
+
 const upsertImage = `-- name: UpsertImage :one
 insert into "images" (
   "theme_id",
+  "extension_version_id",
   "language", 
   "type",
   "format",
-  "url"
+  "width",
+  "url",
+  "sha256"
 )
 values (
   $1, 
-  $2, 
+  $2,
   $3, 
-  $4,
-  $5
+  $4, 
+  $5,
+  $6,
+  $7,
+  $8
 )
-on conflict("theme_id", "language", "type",  "format") do update set
+on conflict("theme_id", "language", "type", "format", "width") do update set
   "url" = excluded."url",
+  "sha256" = excluded."sha256",
   "updated_at" = now()
-returning id, theme_id, language, type, format, url, created_at, updated_at
+returning id, theme_id, language, type, format, url, created_at, updated_at, extension_version_id, width, sha256
 `
 
 type UpsertImageParams struct {
-	ThemeID  int64
-	Language string
-	Type     string
-	Format   string
-	Url      string
+	ThemeID            int64
+	ExtensionVersionID int64
+	Language           string
+	Type               string
+	Format             string
+	Width              int32
+	Url                string
+	Sha256             pgtype.Text
 }
 
 func (q *Queries) UpsertImage(ctx context.Context, arg UpsertImageParams) (Image, error) {
 	row := q.db.QueryRow(ctx, upsertImage,
 		arg.ThemeID,
+		arg.ExtensionVersionID,
 		arg.Language,
 		arg.Type,
 		arg.Format,
+		arg.Width,
 		arg.Url,
+		arg.Sha256,
 	)
 	var i Image
 	err := row.Scan(
@@ -56,6 +87,9 @@ func (q *Queries) UpsertImage(ctx context.Context, arg UpsertImageParams) (Image
 		&i.Url,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ExtensionVersionID,
+		&i.Width,
+		&i.Sha256,
 	)
 	return i, err
 }
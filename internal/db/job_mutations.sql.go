@@ -0,0 +1,26 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: job_mutations.sql
+
+package db
+
+import (
+	"context"
+)
+
+const setJobOutput = `-- name: SetJobOutput :exec
+update "river_job" set
+  "metadata" = "metadata" || jsonb_build_object('output', $1::jsonb)
+where "id" = $2
+`
+
+type SetJobOutputParams struct {
+	Output []byte
+	ID     int64
+}
+
+func (q *Queries) SetJobOutput(ctx context.Context, arg SetJobOutputParams) error {
+	_, err := q.db.Exec(ctx, setJobOutput, arg.Output, arg.ID)
+	return err
+}
@@ -0,0 +1,7 @@
+-- migrate:up
+
+ALTER TABLE extensions ADD COLUMN "registry" text NOT NULL DEFAULT 'marketplace';
+
+-- migrate:down
+
+ALTER TABLE extensions DROP COLUMN "registry";
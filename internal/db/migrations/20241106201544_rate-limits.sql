@@ -0,0 +1,11 @@
+-- migrate:up
+
+CREATE TABLE rate_limits (
+  "name" text PRIMARY KEY,
+  "tokens" double precision NOT NULL,
+  "updated_at" timestamp NOT NULL DEFAULT NOW()
+);
+
+-- migrate:down
+
+DROP TABLE rate_limits;
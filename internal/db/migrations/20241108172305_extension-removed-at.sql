@@ -0,0 +1,7 @@
+-- migrate:up
+
+ALTER TABLE extensions ADD COLUMN "removed_at" timestamp;
+
+-- migrate:down
+
+ALTER TABLE extensions DROP COLUMN "removed_at";
@@ -0,0 +1,18 @@
+-- migrate:up
+
+ALTER TABLE extensions ADD COLUMN "version" text NOT NULL DEFAULT '';
+ALTER TABLE extensions ADD COLUMN "pre_release_version" text;
+
+ALTER TABLE themes ADD COLUMN "pre_release" boolean NOT NULL DEFAULT false;
+ALTER TABLE themes DROP CONSTRAINT themes_extension_id_path_key;
+ALTER TABLE themes ADD CONSTRAINT themes_extension_id_path_pre_release_key UNIQUE (extension_id, path, pre_release);
+
+-- migrate:down
+
+DELETE FROM themes WHERE "pre_release" = true;
+ALTER TABLE themes DROP CONSTRAINT themes_extension_id_path_pre_release_key;
+ALTER TABLE themes ADD CONSTRAINT themes_extension_id_path_key UNIQUE (extension_id, path);
+ALTER TABLE themes DROP COLUMN "pre_release";
+
+ALTER TABLE extensions DROP COLUMN "pre_release_version";
+ALTER TABLE extensions DROP COLUMN "version";
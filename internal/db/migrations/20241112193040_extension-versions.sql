@@ -0,0 +1,77 @@
+-- migrate:up
+
+CREATE TABLE extension_versions (
+  "id" bigserial PRIMARY KEY,
+  "extension_id" bigint NOT NULL REFERENCES extensions("id") ON DELETE CASCADE,
+  "version" text NOT NULL,
+  "pre_release" boolean NOT NULL DEFAULT false,
+  "target_platform" text,
+  "asset_uri" text,
+  "package_hash" text,
+  "synced_at" timestamp NOT NULL DEFAULT NOW(),
+  "created_at" timestamp NOT NULL DEFAULT NOW(),
+  "updated_at" timestamp NOT NULL DEFAULT NOW(),
+  UNIQUE ("extension_id", "version")
+);
+
+-- Record the versions that are already synced. Versions weren't stored before the
+-- extension's version column was added, those are recorded with an empty version.
+INSERT INTO extension_versions ("extension_id", "version", "pre_release", "synced_at")
+SELECT e.id, e.version, false, e.updated_at
+FROM extensions e;
+
+INSERT INTO extension_versions ("extension_id", "version", "pre_release", "synced_at")
+SELECT e.id, e.pre_release_version, true, e.updated_at
+FROM extensions e
+WHERE e.pre_release_version IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE extensions ADD COLUMN "version_id" bigint REFERENCES extension_versions("id") ON DELETE SET NULL;
+ALTER TABLE extensions ADD COLUMN "pre_release_version_id" bigint REFERENCES extension_versions("id") ON DELETE SET NULL;
+
+UPDATE extensions e SET "version_id" = v.id
+FROM extension_versions v
+WHERE v.extension_id = e.id AND v.version = e.version AND NOT v.pre_release;
+
+UPDATE extensions e SET "pre_release_version_id" = v.id
+FROM extension_versions v
+WHERE v.extension_id = e.id AND v.version = e.pre_release_version AND v.pre_release;
+
+-- Themes and images belong to the version they were rendered from.
+ALTER TABLE themes ADD COLUMN "extension_version_id" bigint REFERENCES extension_versions("id") ON DELETE CASCADE;
+
+UPDATE themes t SET "extension_version_id" = CASE WHEN t.pre_release THEN e.pre_release_version_id ELSE e.version_id END
+FROM extensions e
+WHERE e.id = t.extension_id;
+
+DELETE FROM themes WHERE "extension_version_id" IS NULL;
+ALTER TABLE themes ALTER COLUMN "extension_version_id" SET NOT NULL;
+ALTER TABLE themes DROP CONSTRAINT themes_extension_id_path_pre_release_key;
+ALTER TABLE themes ADD CONSTRAINT themes_extension_version_id_path_key UNIQUE (extension_version_id, path);
+
+ALTER TABLE images ADD COLUMN "extension_version_id" bigint REFERENCES extension_versions("id") ON DELETE CASCADE;
+
+UPDATE images i SET "extension_version_id" = t.extension_version_id
+FROM themes t
+WHERE t.id = i.theme_id;
+
+ALTER TABLE images ALTER COLUMN "extension_version_id" SET NOT NULL;
+
+-- migrate:down
+
+-- Only the current versions are kept.
+DELETE FROM themes t
+USING extensions e
+WHERE e.id = t.extension_id
+AND t.extension_version_id IS DISTINCT FROM CASE WHEN t.pre_release THEN e.pre_release_version_id ELSE e.version_id END;
+
+ALTER TABLE images DROP COLUMN "extension_version_id";
+
+ALTER TABLE themes DROP CONSTRAINT themes_extension_version_id_path_key;
+ALTER TABLE themes ADD CONSTRAINT themes_extension_id_path_pre_release_key UNIQUE (extension_id, path, pre_release);
+ALTER TABLE themes DROP COLUMN "extension_version_id";
+
+ALTER TABLE extensions DROP COLUMN "pre_release_version_id";
+ALTER TABLE extensions DROP COLUMN "version_id";
+
+DROP TABLE extension_versions;
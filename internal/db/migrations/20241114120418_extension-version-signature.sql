@@ -0,0 +1,7 @@
+-- migrate:up
+
+ALTER TABLE extension_versions ADD COLUMN "signature_status" text;
+
+-- migrate:down
+
+ALTER TABLE extension_versions DROP COLUMN "signature_status";
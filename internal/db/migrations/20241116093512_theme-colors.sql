@@ -0,0 +1,51 @@
+-- migrate:up
+
+ALTER TABLE themes ADD COLUMN "colors" jsonb DEFAULT '{}'::jsonb NOT NULL;
+
+CREATE TABLE theme_color_slots (
+  "theme_id" bigint NOT NULL REFERENCES themes(id) ON DELETE CASCADE,
+  "slot" text NOT NULL,
+  "color" cube NOT NULL,
+  PRIMARY KEY ("theme_id", "slot")
+);
+
+CREATE INDEX theme_color_slots_color_idx ON theme_color_slots USING gist ("color");
+
+-- Themes synced before colors were stored keep their colors in the theme columns until they're
+-- synced again.
+INSERT INTO theme_color_slots ("theme_id", "slot", "color")
+SELECT t.id, s.slot, s.color
+FROM themes t
+CROSS JOIN LATERAL (VALUES
+  ('editor.background', t.editor_background),
+  ('editor.foreground', t.editor_foreground),
+  ('activityBar.background', t.activity_bar_background),
+  ('activityBar.foreground', t.activity_bar_foreground),
+  ('activityBar.inactiveForeground', t.activity_bar_in_active_foreground),
+  ('activityBar.border', t.activity_bar_border),
+  ('activityBar.activeBorder', t.activity_bar_active_border),
+  ('activityBar.activeBackground', t.activity_bar_active_background),
+  ('activityBarBadge.background', t.activity_bar_badge_background),
+  ('activityBarBadge.foreground', t.activity_bar_badge_foreground),
+  ('editorGroupHeader.tabsBackground', t.tabs_container_background),
+  ('editorGroupHeader.tabsBorder', t.tabs_container_border),
+  ('statusBar.background', t.status_bar_background),
+  ('statusBar.foreground', t.status_bar_foreground),
+  ('statusBar.border', t.status_bar_border),
+  ('tab.activeBackground', t.tab_active_background),
+  ('tab.inactiveBackground', t.tab_inactive_background),
+  ('tab.activeForeground', t.tab_active_foreground),
+  ('tab.border', t.tab_border),
+  ('tab.activeBorder', t.tab_active_border),
+  ('tab.activeBorderTop', t.tab_active_border_top),
+  ('titleBar.activeBackground', t.title_bar_active_background),
+  ('titleBar.activeForeground', t.title_bar_active_foreground),
+  ('titleBar.border', t.title_bar_border)
+) s(slot, color)
+WHERE s.color IS NOT NULL;
+
+-- migrate:down
+
+DROP TABLE theme_color_slots;
+
+ALTER TABLE themes DROP COLUMN "colors";
@@ -0,0 +1,21 @@
+-- migrate:up
+
+ALTER TABLE themes ADD COLUMN "token_colors" jsonb DEFAULT '[]'::jsonb NOT NULL;
+ALTER TABLE themes ADD COLUMN "semantic_token_colors" jsonb DEFAULT '{}'::jsonb NOT NULL;
+
+CREATE TABLE theme_token_colors (
+  "theme_id" bigint NOT NULL REFERENCES themes(id) ON DELETE CASCADE,
+  "category" text NOT NULL,
+  "color" cube NOT NULL,
+  "font_style" text DEFAULT '' NOT NULL,
+  PRIMARY KEY ("theme_id", "category")
+);
+
+CREATE INDEX theme_token_colors_color_idx ON theme_token_colors USING gist ("color");
+
+-- migrate:down
+
+DROP TABLE theme_token_colors;
+
+ALTER TABLE themes DROP COLUMN "semantic_token_colors";
+ALTER TABLE themes DROP COLUMN "token_colors";
@@ -0,0 +1,16 @@
+-- migrate:up
+
+-- The width of raster images, 0 for svg images which scale to any width.
+ALTER TABLE images ADD COLUMN "width" integer DEFAULT 0 NOT NULL;
+
+ALTER TABLE images DROP CONSTRAINT images_theme_id_language_type_format_key;
+ALTER TABLE images ADD CONSTRAINT images_theme_id_language_type_format_width_key UNIQUE (theme_id, language, type, format, width);
+
+-- migrate:down
+
+DELETE FROM images WHERE format != 'svg';
+
+ALTER TABLE images DROP CONSTRAINT images_theme_id_language_type_format_width_key;
+ALTER TABLE images ADD CONSTRAINT images_theme_id_language_type_format_key UNIQUE (theme_id, language, type, format);
+
+ALTER TABLE images DROP COLUMN "width";
@@ -0,0 +1,8 @@
+-- migrate:up
+
+-- Images uploaded before hashes were recorded have none until their theme is synced again.
+ALTER TABLE images ADD COLUMN "sha256" text;
+
+-- migrate:down
+
+ALTER TABLE images DROP COLUMN "sha256";
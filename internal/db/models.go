@@ -77,16 +77,45 @@ type Extension struct {
 	ReleasedAt           pgtype.Timestamp
 	CreatedAt            pgtype.Timestamp
 	UpdatedAt            pgtype.Timestamp
+	Registry             string
+	RemovedAt            pgtype.Timestamp
+	Version              string
+	PreReleaseVersion    pgtype.Text
+	VersionID            pgtype.Int8
+	PreReleaseVersionID  pgtype.Int8
+}
+
+type ExtensionVersion struct {
+	ID              int64
+	ExtensionID     int64
+	Version         string
+	PreRelease      bool
+	TargetPlatform  pgtype.Text
+	AssetUri        pgtype.Text
+	PackageHash     pgtype.Text
+	SyncedAt        pgtype.Timestamp
+	CreatedAt       pgtype.Timestamp
+	UpdatedAt       pgtype.Timestamp
+	SignatureStatus pgtype.Text
 }
 
 type Image struct {
-	ID        int64
-	ThemeID   int64
-	Language  string
-	Type      string
-	Format    string
-	Url       string
-	CreatedAt pgtype.Timestamp
+	ID                 int64
+	ThemeID            int64
+	Language           string
+	Type               string
+	Format             string
+	Url                string
+	CreatedAt          pgtype.Timestamp
+	UpdatedAt          pgtype.Timestamp
+	ExtensionVersionID int64
+	Width              int32
+	Sha256             pgtype.Text
+}
+
+type RateLimit struct {
+	Name      string
+	Tokens    float64
 	UpdatedAt pgtype.Timestamp
 }
 
@@ -182,4 +211,22 @@ type Theme struct {
 	CreatedAt                     pgtype.Timestamp
 	UpdatedAt                     pgtype.Timestamp
 	Tsv                           string
+	PreRelease                    bool
+	ExtensionVersionID            int64
+	Colors                        []byte
+	TokenColors                   []byte
+	SemanticTokenColors           []byte
+}
+
+type ThemeColorSlot struct {
+	ThemeID int64
+	Slot    string
+	Color   string
+}
+
+type ThemeTokenColor struct {
+	ThemeID   int64
+	Category  string
+	Color     string
+	FontStyle string
 }
@@ -13,7 +13,8 @@ insert into "extensions" (
   "trending_monthly",
   "weighted_rating",
   "published_at",
-  "released_at"
+  "released_at",
+  "registry"
 )
 values (
   @vsc_extension_id, 
@@ -29,7 +30,8 @@ values (
   @trending_monthly,
   @weighted_rating,
   @published_at,
-  @released_at
+  @released_at,
+  @registry
 )
 on conflict("vsc_extension_id") do update set
   "name" = excluded."name",
@@ -45,5 +47,49 @@ on conflict("vsc_extension_id") do update set
   "weighted_rating" = excluded."weighted_rating",
   "published_at" = excluded."published_at",
   "released_at" = excluded."released_at",
+  "registry" = excluded."registry",
+  "removed_at" = null,
   "updated_at" = now()
-returning *;
\ No newline at end of file
+returning *;
+
+-- name: UpdateExtensionStats :batchexec
+update "extensions" set
+  "installs" = @installs,
+  "trending_daily" = @trending_daily,
+  "trending_weekly" = @trending_weekly,
+  "trending_monthly" = @trending_monthly,
+  "weighted_rating" = @weighted_rating,
+  "updated_at" = now()
+where "vsc_extension_id" = @vsc_extension_id;
+
+-- name: MarkExtensionRemoved :execrows
+update "extensions" set
+  "removed_at" = now(),
+  "updated_at" = now()
+where "name" = @extension_name
+  and "publisher_name" = @publisher_name
+  and "registry" = @registry
+  and "removed_at" is null;
+
+-- name: RestoreExtension :execrows
+update "extensions" set
+  "removed_at" = null,
+  "updated_at" = now()
+where "name" = @extension_name
+  and "publisher_name" = @publisher_name
+  and "registry" = @registry
+  and "removed_at" is not null;
+
+-- name: SetExtensionVersion :exec
+update "extensions" set
+  "version" = @version,
+  "version_id" = @version_id,
+  "updated_at" = now()
+where "id" = @id;
+
+-- name: SetExtensionPreReleaseVersion :exec
+update "extensions" set
+  "pre_release_version" = @pre_release_version,
+  "pre_release_version_id" = @pre_release_version_id,
+  "updated_at" = now()
+where "id" = @id;
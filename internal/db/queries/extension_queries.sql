@@ -6,14 +6,17 @@ SELECT
 	e.publisher_display_name,
 	e.short_description,
 	e.published_at,
+	e.removed_at,
+	e.version,
+	e.pre_release_version,
 	jsonb_agg(json_build_object(
 		'name', t.name,
 		'display_name', t.display_name,
 		'url', i.url
 	)) AS themes
 FROM extensions e
-LEFT JOIN themes t ON t.extension_id = e.id
-LEFT JOIN images i ON i.theme_id = t.id
+LEFT JOIN themes t ON t.extension_version_id = e.version_id
+LEFT JOIN images i ON i.theme_id = t.id AND i.type = 'preview' AND i.format = 'svg'
 WHERE 
 	e.name = @extension_name
 	AND e.publisher_name = @publisher_name
@@ -35,11 +38,19 @@ SELECT
 		'url', i.url
 	)) AS themes
 FROM extensions e
-LEFT JOIN themes t ON t.extension_id = e.id
-LEFT JOIN images i ON i.theme_id = t.id
+LEFT JOIN themes t ON t.extension_version_id = e.version_id
+LEFT JOIN images i ON i.theme_id = t.id AND i.type = 'preview' AND i.format = 'svg'
 WHERE i.language = @language
 GROUP BY e.id;
 
 -- name: GetAllExtensionsForUpdate :many
-SELECT e.name, e.publisher_name
-FROM extensions e;
\ No newline at end of file
+SELECT e.name, e.publisher_name, e.registry
+FROM extensions e
+WHERE e.removed_at IS NULL;
+
+-- name: GetExtensionRegistry :one
+SELECT e.registry
+FROM extensions e
+WHERE 
+	e.name = @extension_name
+	AND e.publisher_name = @publisher_name;
\ No newline at end of file
@@ -0,0 +1,28 @@
+-- name: UpsertExtensionVersion :one
+insert into "extension_versions" (
+  "extension_id",
+  "version",
+  "pre_release",
+  "target_platform",
+  "asset_uri",
+  "package_hash",
+  "signature_status"
+)
+values (
+  @extension_id,
+  @version,
+  @pre_release,
+  @target_platform,
+  @asset_uri,
+  @package_hash,
+  @signature_status
+)
+on conflict("extension_id", "version") do update set
+  "pre_release" = excluded."pre_release",
+  "target_platform" = excluded."target_platform",
+  "asset_uri" = excluded."asset_uri",
+  "package_hash" = excluded."package_hash",
+  "signature_status" = excluded."signature_status",
+  "synced_at" = now(),
+  "updated_at" = now()
+returning *;
@@ -0,0 +1,48 @@
+-- name: GetExtensionSyncedVersion :one
+SELECT
+	v.version,
+	e.removed_at
+FROM extensions e
+JOIN extension_versions v ON v.id = CASE WHEN sqlc.arg(pre_release)::boolean THEN e.pre_release_version_id ELSE e.version_id END
+WHERE 
+	e.name = sqlc.arg(extension_name)
+	AND e.publisher_name = sqlc.arg(publisher_name);
+
+-- name: ListExtensionVersions :many
+SELECT
+	v.version,
+	v.pre_release,
+	v.target_platform,
+	v.asset_uri,
+	v.package_hash,
+	v.signature_status,
+	v.synced_at,
+	(SELECT COUNT(*) FROM themes t WHERE t.extension_version_id = v.id) AS total_themes
+FROM extension_versions v
+JOIN extensions e ON e.id = v.extension_id
+WHERE 
+	e.name = @extension_name
+	AND e.publisher_name = @publisher_name
+	AND e.removed_at IS NULL
+ORDER BY v.synced_at DESC;
+
+-- name: ListExtensionVersionThemes :many
+SELECT
+	t.name,
+	t.display_name,
+	i.url,
+	(
+		SELECT COALESCE(jsonb_agg(jsonb_build_object('url', ri.url, 'format', ri.format, 'width', ri.width) ORDER BY ri.format, ri.width), '[]')
+		FROM images ri
+		WHERE ri.theme_id = t.id AND ri.language = @language AND ri.type = 'preview' AND ri.format != 'svg'
+	)::jsonb AS srcset
+FROM extension_versions v
+JOIN extensions e ON e.id = v.extension_id
+JOIN themes t ON t.extension_version_id = v.id
+JOIN images i ON i.theme_id = t.id AND i.language = @language AND i.type = 'preview' AND i.format = 'svg'
+WHERE 
+	e.name = @extension_name
+	AND e.publisher_name = @publisher_name
+	AND v.version = @version
+	AND e.removed_at IS NULL
+ORDER BY t.name ASC;
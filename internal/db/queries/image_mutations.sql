@@ -1,19 +1,31 @@
 -- name: UpsertImage :one
 insert into "images" (
   "theme_id",
+  "extension_version_id",
   "language", 
   "type",
   "format",
-  "url"
+  "width",
+  "url",
+  "sha256"
 )
 values (
   @theme_id, 
+  @extension_version_id,
   @language, 
   @type, 
   @format,
-  @url
+  @width,
+  @url,
+  @sha256
 )
-on conflict("theme_id", "language", "type",  "format") do update set
+on conflict("theme_id", "language", "type", "format", "width") do update set
   "url" = excluded."url",
+  "sha256" = excluded."sha256",
   "updated_at" = now()
-returning *;
\ No newline at end of file
+returning *;
+
+-- name: DeleteThemeImagesNotIn :exec
+delete from "images"
+where "theme_id" = @theme_id
+and "id" != all(@image_ids::bigint[]);
@@ -0,0 +1,4 @@
+-- name: SetJobOutput :exec
+update "river_job" set
+  "metadata" = "metadata" || jsonb_build_object('output', sqlc.arg(output)::jsonb)
+where "id" = sqlc.arg(id);
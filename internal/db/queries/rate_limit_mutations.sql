@@ -0,0 +1,27 @@
+-- name: ReserveRateLimitToken :one
+insert into "rate_limits" (
+  "name",
+  "tokens",
+  "updated_at"
+)
+values (
+  sqlc.arg(name),
+  sqlc.arg(burst)::float8 - 1,
+  now()
+)
+on conflict("name") do update set
+  "tokens" = least(
+    sqlc.arg(burst)::float8,
+    "rate_limits"."tokens" + extract(epoch from now() - "rate_limits"."updated_at")::float8 * sqlc.arg(rate)::float8
+  ) - 1,
+  "updated_at" = now()
+returning "tokens";
+
+-- name: DrainRateLimitTokens :exec
+update "rate_limits" set
+  "tokens" = least(
+    "tokens" + extract(epoch from now() - "updated_at")::float8 * sqlc.arg(rate)::float8,
+    -(sqlc.arg(pause_seconds)::float8 * sqlc.arg(rate)::float8)
+  ),
+  "updated_at" = now()
+where "name" = sqlc.arg(name);
@@ -1,6 +1,7 @@
 -- name: UpsertTheme :one
 insert into "themes" (
   "extension_id",
+  "extension_version_id",
   "path",
   "name", 
   "display_name",
@@ -27,10 +28,15 @@ insert into "themes" (
   "tab_active_border_top",
   "title_bar_active_background",
   "title_bar_active_foreground",
-  "title_bar_border"
+  "title_bar_border",
+  "colors",
+  "token_colors",
+  "semantic_token_colors",
+  "pre_release"
 )
 values (
   @extension_id, 
+  @extension_version_id,
   @path, 
   @name, 
   @display_name,
@@ -57,9 +63,13 @@ values (
   @tab_active_border_top,
   @title_bar_active_background,
   @title_bar_active_foreground,
-  @title_bar_border
+  @title_bar_border,
+  @colors,
+  @token_colors,
+  @semantic_token_colors,
+  @pre_release
 )
-on conflict("extension_id", "path") do update set
+on conflict("extension_version_id", "path") do update set
   "name" = excluded."name",
   "display_name" = excluded."display_name",
   "editor_background" = excluded."editor_background",
@@ -86,5 +96,49 @@ on conflict("extension_id", "path") do update set
   "title_bar_active_background" = excluded."title_bar_active_background",
   "title_bar_active_foreground" = excluded."title_bar_active_foreground",
   "title_bar_border" = excluded."title_bar_border",
+  "colors" = excluded."colors",
+  "token_colors" = excluded."token_colors",
+  "semantic_token_colors" = excluded."semantic_token_colors",
   "updated_at" = now()
-returning *;
\ No newline at end of file
+returning *;
+
+-- name: UpsertThemeColorSlot :exec
+insert into "theme_color_slots" (
+  "theme_id",
+  "slot",
+  "color"
+)
+values (
+  @theme_id,
+  @slot,
+  @color
+)
+on conflict("theme_id", "slot") do update set
+  "color" = excluded."color";
+
+-- name: DeleteThemeColorSlotsNotIn :exec
+delete from "theme_color_slots"
+where "theme_id" = @theme_id
+and "slot" != all(@slots::text[]);
+
+-- name: UpsertThemeTokenColor :exec
+insert into "theme_token_colors" (
+  "theme_id",
+  "category",
+  "color",
+  "font_style"
+)
+values (
+  @theme_id,
+  @category,
+  @color,
+  @font_style
+)
+on conflict("theme_id", "category") do update set
+  "color" = excluded."color",
+  "font_style" = excluded."font_style";
+
+-- name: DeleteThemeTokenColorsNotIn :exec
+delete from "theme_token_colors"
+where "theme_id" = @theme_id
+and "category" != all(@categories::text[]);
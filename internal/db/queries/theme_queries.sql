@@ -4,12 +4,13 @@ SELECT
 	t.editor_background as color,
 	count(*) as count
 FROM themes t
+JOIN extensions e ON e.version_id = t.extension_version_id
 GROUP BY color
 ORDER BY count DESC;
 
 -- name: DeleteExtensionThemesNotIn :exec
 
 DELETE FROM themes t
-WHERE t.extension_id = @extension_id 
+WHERE t.extension_version_id = @extension_version_id 
 AND t.id != ALL(@theme_ids::bigint[]);
 
@@ -0,0 +1,64 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: rate_limit_mutations.sql
+
+package db
+
+import (
+	"context"
+)
+
+const drainRateLimitTokens = `-- name: DrainRateLimitTokens :exec
+update "rate_limits" set
+  "tokens" = least(
+    "tokens" + extract(epoch from now() - "updated_at")::float8 * $1::float8,
+    -($2::float8 * $1::float8)
+  ),
+  "updated_at" = now()
+where "name" = $3
+`
+
+type DrainRateLimitTokensParams struct {
+	Rate         float64
+	PauseSeconds float64
+	Name         string
+}
+
+func (q *Queries) DrainRateLimitTokens(ctx context.Context, arg DrainRateLimitTokensParams) error {
+	_, err := q.db.Exec(ctx, drainRateLimitTokens, arg.Rate, arg.PauseSeconds, arg.Name)
+	return err
+}
+
+const reserveRateLimitToken = `-- name: ReserveRateLimitToken :one
+insert into "rate_limits" (
+  "name",
+  "tokens",
+  "updated_at"
+)
+values (
+  $1,
+  $2::float8 - 1,
+  now()
+)
+on conflict("name") do update set
+  "tokens" = least(
+    $2::float8,
+    "rate_limits"."tokens" + extract(epoch from now() - "rate_limits"."updated_at")::float8 * $3::float8
+  ) - 1,
+  "updated_at" = now()
+returning "tokens"
+`
+
+type ReserveRateLimitTokenParams struct {
+	Name  string
+	Burst float64
+	Rate  float64
+}
+
+func (q *Queries) ReserveRateLimitToken(ctx context.Context, arg ReserveRateLimitTokenParams) (float64, error) {
+	row := q.db.QueryRow(ctx, reserveRateLimitToken, arg.Name, arg.Burst, arg.Rate)
+	var tokens float64
+	err := row.Scan(&tokens)
+	return tokens, err
+}
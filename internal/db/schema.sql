@@ -89,6 +89,44 @@ SET default_tablespace = '';
 
 SET default_table_access_method = heap;
 
+--
+-- Name: extension_versions; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.extension_versions (
+    id bigint NOT NULL,
+    extension_id bigint NOT NULL,
+    version text NOT NULL,
+    pre_release boolean DEFAULT false NOT NULL,
+    target_platform text,
+    asset_uri text,
+    package_hash text,
+    synced_at timestamp without time zone DEFAULT now() NOT NULL,
+    created_at timestamp without time zone DEFAULT now() NOT NULL,
+    updated_at timestamp without time zone DEFAULT now() NOT NULL,
+    signature_status text
+);
+
+
+--
+-- Name: extension_versions_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.extension_versions_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: extension_versions_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.extension_versions_id_seq OWNED BY public.extension_versions.id;
+
+
 --
 -- Name: extensions; Type: TABLE; Schema: public; Owner: -
 --
@@ -110,7 +148,13 @@ CREATE TABLE public.extensions (
     published_at timestamp without time zone NOT NULL,
     released_at timestamp without time zone NOT NULL,
     created_at timestamp without time zone DEFAULT now() NOT NULL,
-    updated_at timestamp without time zone DEFAULT now() NOT NULL
+    updated_at timestamp without time zone DEFAULT now() NOT NULL,
+    registry text DEFAULT 'marketplace'::text NOT NULL,
+    removed_at timestamp without time zone,
+    version text DEFAULT ''::text NOT NULL,
+    pre_release_version text,
+    version_id bigint,
+    pre_release_version_id bigint
 );
 
 
@@ -145,7 +189,10 @@ CREATE TABLE public.images (
     format text NOT NULL,
     url text NOT NULL,
     created_at timestamp without time zone DEFAULT now() NOT NULL,
-    updated_at timestamp without time zone DEFAULT now() NOT NULL
+    updated_at timestamp without time zone DEFAULT now() NOT NULL,
+    extension_version_id bigint NOT NULL,
+    width integer DEFAULT 0 NOT NULL,
+    sha256 text
 );
 
 
@@ -168,6 +215,17 @@ CREATE SEQUENCE public.images_id_seq
 ALTER SEQUENCE public.images_id_seq OWNED BY public.images.id;
 
 
+--
+-- Name: rate_limits; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.rate_limits (
+    name text NOT NULL,
+    tokens double precision NOT NULL,
+    updated_at timestamp without time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: river_client; Type: TABLE; Schema: public; Owner: -
 --
@@ -287,6 +345,29 @@ CREATE TABLE public.schema_migrations (
 );
 
 
+--
+-- Name: theme_color_slots; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.theme_color_slots (
+    theme_id bigint NOT NULL,
+    slot text NOT NULL,
+    color public.cube NOT NULL
+);
+
+
+--
+-- Name: theme_token_colors; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.theme_token_colors (
+    theme_id bigint NOT NULL,
+    category text NOT NULL,
+    color public.cube NOT NULL,
+    font_style text DEFAULT ''::text NOT NULL
+);
+
+
 --
 -- Name: themes; Type: TABLE; Schema: public; Owner: -
 --
@@ -323,7 +404,12 @@ CREATE TABLE public.themes (
     title_bar_border public.cube,
     created_at timestamp without time zone DEFAULT now() NOT NULL,
     updated_at timestamp without time zone DEFAULT now() NOT NULL,
-    tsv tsvector NOT NULL
+    tsv tsvector NOT NULL,
+    pre_release boolean DEFAULT false NOT NULL,
+    extension_version_id bigint NOT NULL,
+    colors jsonb DEFAULT '{}'::jsonb NOT NULL,
+    token_colors jsonb DEFAULT '[]'::jsonb NOT NULL,
+    semantic_token_colors jsonb DEFAULT '{}'::jsonb NOT NULL
 );
 
 
@@ -346,6 +432,13 @@ CREATE SEQUENCE public.themes_id_seq
 ALTER SEQUENCE public.themes_id_seq OWNED BY public.themes.id;
 
 
+--
+-- Name: extension_versions id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.extension_versions ALTER COLUMN id SET DEFAULT nextval('public.extension_versions_id_seq'::regclass);
+
+
 --
 -- Name: extensions id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -374,6 +467,22 @@ ALTER TABLE ONLY public.river_job ALTER COLUMN id SET DEFAULT nextval('public.ri
 ALTER TABLE ONLY public.themes ALTER COLUMN id SET DEFAULT nextval('public.themes_id_seq'::regclass);
 
 
+--
+-- Name: extension_versions extension_versions_extension_id_version_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.extension_versions
+    ADD CONSTRAINT extension_versions_extension_id_version_key UNIQUE (extension_id, version);
+
+
+--
+-- Name: extension_versions extension_versions_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.extension_versions
+    ADD CONSTRAINT extension_versions_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: extensions extensions_name_publisher_name_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -407,11 +516,19 @@ ALTER TABLE ONLY public.images
 
 
 --
--- Name: images images_theme_id_language_type_format_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: images images_theme_id_language_type_format_width_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
 ALTER TABLE ONLY public.images
-    ADD CONSTRAINT images_theme_id_language_type_format_key UNIQUE (theme_id, language, type, format);
+    ADD CONSTRAINT images_theme_id_language_type_format_width_key UNIQUE (theme_id, language, type, format, width);
+
+
+--
+-- Name: rate_limits rate_limits_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.rate_limits
+    ADD CONSTRAINT rate_limits_pkey PRIMARY KEY (name);
 
 
 --
@@ -463,11 +580,27 @@ ALTER TABLE ONLY public.schema_migrations
 
 
 --
--- Name: themes themes_extension_id_path_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: theme_color_slots theme_color_slots_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.theme_color_slots
+    ADD CONSTRAINT theme_color_slots_pkey PRIMARY KEY (theme_id, slot);
+
+
+--
+-- Name: theme_token_colors theme_token_colors_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.theme_token_colors
+    ADD CONSTRAINT theme_token_colors_pkey PRIMARY KEY (theme_id, category);
+
+
+--
+-- Name: themes themes_extension_version_id_path_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
 ALTER TABLE ONLY public.themes
-    ADD CONSTRAINT themes_extension_id_path_key UNIQUE (extension_id, path);
+    ADD CONSTRAINT themes_extension_version_id_path_key UNIQUE (extension_version_id, path);
 
 
 --
@@ -520,6 +653,20 @@ CREATE INDEX river_job_state_and_finalized_at_index ON public.river_job USING bt
 CREATE UNIQUE INDEX river_job_unique_idx ON public.river_job USING btree (unique_key) WHERE ((unique_key IS NOT NULL) AND (unique_states IS NOT NULL) AND public.river_job_state_in_bitmask(unique_states, state));
 
 
+--
+-- Name: theme_color_slots_color_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX theme_color_slots_color_idx ON public.theme_color_slots USING gist (color);
+
+
+--
+-- Name: theme_token_colors_color_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX theme_token_colors_color_idx ON public.theme_token_colors USING gist (color);
+
+
 --
 -- Name: themes_tsv_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -534,6 +681,38 @@ CREATE INDEX themes_tsv_idx ON public.themes USING gist (tsv);
 CREATE TRIGGER tsvupdate BEFORE INSERT OR UPDATE ON public.themes FOR EACH ROW EXECUTE FUNCTION public.tsv_trigger();
 
 
+--
+-- Name: extension_versions extension_versions_extension_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.extension_versions
+    ADD CONSTRAINT extension_versions_extension_id_fkey FOREIGN KEY (extension_id) REFERENCES public.extensions(id) ON DELETE CASCADE;
+
+
+--
+-- Name: extensions extensions_pre_release_version_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.extensions
+    ADD CONSTRAINT extensions_pre_release_version_id_fkey FOREIGN KEY (pre_release_version_id) REFERENCES public.extension_versions(id) ON DELETE SET NULL;
+
+
+--
+-- Name: extensions extensions_version_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.extensions
+    ADD CONSTRAINT extensions_version_id_fkey FOREIGN KEY (version_id) REFERENCES public.extension_versions(id) ON DELETE SET NULL;
+
+
+--
+-- Name: images images_extension_version_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.images
+    ADD CONSTRAINT images_extension_version_id_fkey FOREIGN KEY (extension_version_id) REFERENCES public.extension_versions(id) ON DELETE CASCADE;
+
+
 --
 -- Name: images images_theme_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -550,6 +729,22 @@ ALTER TABLE ONLY public.river_client_queue
     ADD CONSTRAINT river_client_queue_river_client_id_fkey FOREIGN KEY (river_client_id) REFERENCES public.river_client(id) ON DELETE CASCADE;
 
 
+--
+-- Name: theme_color_slots theme_color_slots_theme_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.theme_color_slots
+    ADD CONSTRAINT theme_color_slots_theme_id_fkey FOREIGN KEY (theme_id) REFERENCES public.themes(id) ON DELETE CASCADE;
+
+
+--
+-- Name: theme_token_colors theme_token_colors_theme_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.theme_token_colors
+    ADD CONSTRAINT theme_token_colors_theme_id_fkey FOREIGN KEY (theme_id) REFERENCES public.themes(id) ON DELETE CASCADE;
+
+
 --
 -- Name: themes themes_extension_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
 --
@@ -558,6 +753,14 @@ ALTER TABLE ONLY public.themes
     ADD CONSTRAINT themes_extension_id_fkey FOREIGN KEY (extension_id) REFERENCES public.extensions(id) ON DELETE CASCADE;
 
 
+--
+-- Name: themes themes_extension_version_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.themes
+    ADD CONSTRAINT themes_extension_version_id_fkey FOREIGN KEY (extension_version_id) REFERENCES public.extension_versions(id) ON DELETE CASCADE;
+
+
 --
 -- PostgreSQL database dump complete
 --
@@ -571,4 +774,14 @@ INSERT INTO public.schema_migrations (version) VALUES
     ('20240820234134'),
     ('20240922015622'),
     ('20240930011343'),
-    ('20241021160435');
+    ('20241021160435'),
+    ('20241104183012'),
+    ('20241106201544'),
+    ('20241108172305'),
+    ('20241110140527'),
+    ('20241112193040'),
+    ('20241114120418'),
+    ('20241116093512'),
+    ('20241118151027'),
+    ('20241120103341'),
+    ('20241122164208');
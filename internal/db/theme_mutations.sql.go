@@ -9,9 +9,42 @@ import (
 	"context"
 )
 
+const deleteThemeColorSlotsNotIn = `-- name: DeleteThemeColorSlotsNotIn :exec
+delete from "theme_color_slots"
+where "theme_id" = $1
+and "slot" != all($2::text[])
+`
+
+type DeleteThemeColorSlotsNotInParams struct {
+	ThemeID int64
+	Slots   []string
+}
+
+func (q *Queries) DeleteThemeColorSlotsNotIn(ctx context.Context, arg DeleteThemeColorSlotsNotInParams) error {
+	_, err := q.db.Exec(ctx, deleteThemeColorSlotsNotIn, arg.ThemeID, arg.Slots)
+	return err
+}
+
+const deleteThemeTokenColorsNotIn = `-- name: DeleteThemeTokenColorsNotIn :exec
+delete from "theme_token_colors"
+where "theme_id" = $1
+and "category" != all($2::text[])
+`
+
+type DeleteThemeTokenColorsNotInParams struct {
+	ThemeID    int64
+	Categories []string
+}
+
+func (q *Queries) DeleteThemeTokenColorsNotIn(ctx context.Context, arg DeleteThemeTokenColorsNotInParams) error {
+	_, err := q.db.Exec(ctx, deleteThemeTokenColorsNotIn, arg.ThemeID, arg.Categories)
+	return err
+}
+
 const upsertTheme = `-- name: UpsertTheme :one
 insert into "themes" (
   "extension_id",
+  "extension_version_id",
   "path",
   "name", 
   "display_name",
@@ -38,13 +71,17 @@ insert into "themes" (
   "tab_active_border_top",
   "title_bar_active_background",
   "title_bar_active_foreground",
-  "title_bar_border"
+  "title_bar_border",
+  "colors",
+  "token_colors",
+  "semantic_token_colors",
+  "pre_release"
 )
 values (
   $1, 
-  $2, 
+  $2,
   $3, 
-  $4,
+  $4, 
   $5,
   $6,
   $7,
@@ -68,9 +105,14 @@ values (
   $25,
   $26,
   $27,
-  $28
+  $28,
+  $29,
+  $30,
+  $31,
+  $32,
+  $33
 )
-on conflict("extension_id", "path") do update set
+on conflict("extension_version_id", "path") do update set
   "name" = excluded."name",
   "display_name" = excluded."display_name",
   "editor_background" = excluded."editor_background",
@@ -97,12 +139,16 @@ on conflict("extension_id", "path") do update set
   "title_bar_active_background" = excluded."title_bar_active_background",
   "title_bar_active_foreground" = excluded."title_bar_active_foreground",
   "title_bar_border" = excluded."title_bar_border",
+  "colors" = excluded."colors",
+  "token_colors" = excluded."token_colors",
+  "semantic_token_colors" = excluded."semantic_token_colors",
   "updated_at" = now()
-returning id, extension_id, path, name, display_name, editor_background, editor_foreground, activity_bar_background, activity_bar_foreground, activity_bar_in_active_foreground, activity_bar_border, activity_bar_active_border, activity_bar_active_background, activity_bar_badge_background, activity_bar_badge_foreground, tabs_container_background, tabs_container_border, status_bar_background, status_bar_foreground, status_bar_border, tab_active_background, tab_inactive_background, tab_active_foreground, tab_border, tab_active_border, tab_active_border_top, title_bar_active_background, title_bar_active_foreground, title_bar_border, created_at, updated_at, tsv
+returning id, extension_id, path, name, display_name, editor_background, editor_foreground, activity_bar_background, activity_bar_foreground, activity_bar_in_active_foreground, activity_bar_border, activity_bar_active_border, activity_bar_active_background, activity_bar_badge_background, activity_bar_badge_foreground, tabs_container_background, tabs_container_border, status_bar_background, status_bar_foreground, status_bar_border, tab_active_background, tab_inactive_background, tab_active_foreground, tab_border, tab_active_border, tab_active_border_top, title_bar_active_background, title_bar_active_foreground, title_bar_border, created_at, updated_at, tsv, pre_release, extension_version_id, colors, token_colors, semantic_token_colors
 `
 
 type UpsertThemeParams struct {
 	ExtensionID                   int64
+	ExtensionVersionID            int64
 	Path                          string
 	Name                          string
 	DisplayName                   string
@@ -130,11 +176,16 @@ type UpsertThemeParams struct {
 	TitleBarActiveBackground      string
 	TitleBarActiveForeground      string
 	TitleBarBorder                *string
+	Colors                        []byte
+	TokenColors                   []byte
+	SemanticTokenColors           []byte
+	PreRelease                    bool
 }
 
 func (q *Queries) UpsertTheme(ctx context.Context, arg UpsertThemeParams) (Theme, error) {
 	row := q.db.QueryRow(ctx, upsertTheme,
 		arg.ExtensionID,
+		arg.ExtensionVersionID,
 		arg.Path,
 		arg.Name,
 		arg.DisplayName,
@@ -162,6 +213,10 @@ func (q *Queries) UpsertTheme(ctx context.Context, arg UpsertThemeParams) (Theme
 		arg.TitleBarActiveBackground,
 		arg.TitleBarActiveForeground,
 		arg.TitleBarBorder,
+		arg.Colors,
+		arg.TokenColors,
+		arg.SemanticTokenColors,
+		arg.PreRelease,
 	)
 	var i Theme
 	err := row.Scan(
@@ -197,6 +252,72 @@ func (q *Queries) UpsertTheme(ctx context.Context, arg UpsertThemeParams) (Theme
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Tsv,
+		&i.PreRelease,
+		&i.ExtensionVersionID,
+		&i.Colors,
+		&i.TokenColors,
+		&i.SemanticTokenColors,
 	)
 	return i, err
 }
+
+const upsertThemeColorSlot = `-- name: UpsertThemeColorSlot :exec
+insert into "theme_color_slots" (
+  "theme_id",
+  "slot",
+  "color"
+)
+values (
+  $1,
+  $2,
+  $3
+)
+on conflict("theme_id", "slot") do update set
+  "color" = excluded."color"
+`
+
+type UpsertThemeColorSlotParams struct {
+	ThemeID int64
+	Slot    string
+	Color   string
+}
+
+func (q *Queries) UpsertThemeColorSlot(ctx context.Context, arg UpsertThemeColorSlotParams) error {
+	_, err := q.db.Exec(ctx, upsertThemeColorSlot, arg.ThemeID, arg.Slot, arg.Color)
+	return err
+}
+
+const upsertThemeTokenColor = `-- name: UpsertThemeTokenColor :exec
+insert into "theme_token_colors" (
+  "theme_id",
+  "category",
+  "color",
+  "font_style"
+)
+values (
+  $1,
+  $2,
+  $3,
+  $4
+)
+on conflict("theme_id", "category") do update set
+  "color" = excluded."color",
+  "font_style" = excluded."font_style"
+`
+
+type UpsertThemeTokenColorParams struct {
+	ThemeID   int64
+	Category  string
+	Color     string
+	FontStyle string
+}
+
+func (q *Queries) UpsertThemeTokenColor(ctx context.Context, arg UpsertThemeTokenColorParams) error {
+	_, err := q.db.Exec(ctx, upsertThemeTokenColor,
+		arg.ThemeID,
+		arg.Category,
+		arg.Color,
+		arg.FontStyle,
+	)
+	return err
+}
@@ -12,17 +12,17 @@ import (
 const deleteExtensionThemesNotIn = `-- name: DeleteExtensionThemesNotIn :exec
 
 DELETE FROM themes t
-WHERE t.extension_id = $1 
+WHERE t.extension_version_id = $1 
 AND t.id != ALL($2::bigint[])
 `
 
 type DeleteExtensionThemesNotInParams struct {
-	ExtensionID int64
-	ThemeIds    []int64
+	ExtensionVersionID int64
+	ThemeIds           []int64
 }
 
 func (q *Queries) DeleteExtensionThemesNotIn(ctx context.Context, arg DeleteExtensionThemesNotInParams) error {
-	_, err := q.db.Exec(ctx, deleteExtensionThemesNotIn, arg.ExtensionID, arg.ThemeIds)
+	_, err := q.db.Exec(ctx, deleteExtensionThemesNotIn, arg.ExtensionVersionID, arg.ThemeIds)
 	return err
 }
 
@@ -32,6 +32,7 @@ SELECT
 	t.editor_background as color,
 	count(*) as count
 FROM themes t
+JOIN extensions e ON e.version_id = t.extension_version_id
 GROUP BY color
 ORDER BY count DESC
 `
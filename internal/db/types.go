@@ -30,6 +30,17 @@ func Text(s *string) pgtype.Text {
 	return text
 }
 
+func Int8(n *int64) pgtype.Int8 {
+	integer := pgtype.Int8{}
+
+	if n != nil {
+		integer.Int64 = *n
+		integer.Valid = true
+	}
+
+	return integer
+}
+
 func Numeric(n *float64) (pgtype.Numeric, error) {
 	numeric := pgtype.Numeric{}
 
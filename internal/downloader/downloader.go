@@ -1,112 +1,314 @@
 package downloader
 
 import (
-	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+	"mime"
 	"net/http"
 	"os"
-	"path"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// DefaultMaxSize is far larger than any theme package, they're usually well under 10MB.
+	DefaultMaxSize     = 100 << 20
+	DefaultMaxRetries  = 3
+	DefaultBaseBackoff = 1 * time.Second
 )
 
 type Downloader struct {
-	PackagePath string
-	ExtractDir  string
+	// SHA256 is the hex encoded checksum of the package, set once it's downloaded.
+	SHA256 string
+	// Size is the size of the package in bytes, set once it's downloaded.
+	Size int64
+
+	MaxSize       int64
+	MaxRetries    int
+	BaseBackoff   time.Duration
+	ExtractLimits ExtractLimits
+	// TempDir is where Open buffers packages, they're buffered in memory if it's empty.
+	TempDir    string
+	httpClient *http.Client
 }
 
-func New(dir, extensionSlug string) *Downloader {
-	return &Downloader{
-		PackagePath: path.Join(dir, fmt.Sprintf("%s.VSIXPackage", extensionSlug)),
-		ExtractDir:  path.Join(dir, extensionSlug),
+type Option func(*Downloader)
+
+// WithMaxSize sets the maximum size of a package in bytes.
+func WithMaxSize(maxSize int64) Option {
+	return func(d *Downloader) {
+		d.MaxSize = maxSize
 	}
 }
 
-func (d *Downloader) Download(ctx context.Context, url string) error {
-	file, err := os.Create(d.PackagePath)
-	if err != nil {
-		return fmt.Errorf("failed to create package file: %w", err)
+// WithRetries sets how many times an interrupted download is resumed and the delay before
+// the first retry, which doubles on each retry.
+func WithRetries(maxRetries int, baseBackoff time.Duration) Option {
+	return func(d *Downloader) {
+		d.MaxRetries = maxRetries
+		d.BaseBackoff = baseBackoff
 	}
-	defer file.Close()
+}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("failed to download package: %w", err)
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(d *Downloader) {
+		d.httpClient = httpClient
 	}
-	defer resp.Body.Close()
+}
 
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to write package file: %w", err)
+func New(opts ...Option) *Downloader {
+	d := &Downloader{
+		MaxSize:       DefaultMaxSize,
+		MaxRetries:    DefaultMaxRetries,
+		BaseBackoff:   DefaultBaseBackoff,
+		ExtractLimits: DefaultExtractLimits,
+		httpClient:    http.DefaultClient,
 	}
 
-	return nil
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
 }
 
-func (d *Downloader) Extract() error {
-	reader, err := zip.OpenReader(d.PackagePath)
-	if err != nil {
-		return err
+// Fetch downloads the file at url into memory, with the same size limit and retries as packages.
+// It's used for small files that are downloaded with a package, such as its signature.
+func (d *Downloader) Fetch(ctx context.Context, url string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := d.download(ctx, url, bufferSink{&buf}); err != nil {
+		return nil, err
 	}
-	defer reader.Close()
 
-	err = os.MkdirAll(d.ExtractDir, 0755)
+	return buf.Bytes(), nil
+}
+
+// load writes a package read from r to dst, such as a package that was stored earlier, and
+// computes its checksum like download does.
+func (d *Downloader) load(r io.Reader, dst sink) error {
+	checksum := sha256.New()
+	n, err := io.Copy(io.MultiWriter(dst, checksum), io.LimitReader(r, d.MaxSize+1))
 	if err != nil {
-		return fmt.Errorf("failed to create extract directory: %w", err)
+		return fmt.Errorf("failed to load package: %w", err)
+	}
+	if n > d.MaxSize {
+		return ErrTooLarge
+	}
+
+	d.SHA256 = hex.EncodeToString(checksum.Sum(nil))
+	d.Size = n
+
+	return nil
+}
+
+// sink is where a download is written. Reset discards everything written so far, for when the
+// download has to start over.
+type sink interface {
+	io.Writer
+	Reset() error
+}
+
+type fileSink struct {
+	*os.File
+}
+
+func (s fileSink) Reset() error {
+	if err := s.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate package file: %w", err)
+	}
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek package file: %w", err)
 	}
+	return nil
+}
+
+type bufferSink struct {
+	*bytes.Buffer
+}
+
+func (s bufferSink) Reset() error {
+	s.Buffer.Reset()
+	return nil
+}
 
-	// Function to extract and write a file from a zip. This is called for
-	// each file in the zip and checks for ZipSlip. A local function is used
-	// instead of adding directly to the for loop below so that we can defer
-	// the closing of the file.
-	extractAndWriteFile := func(zipFile *zip.File) error {
-		readCloser, err := zipFile.Open()
-		if err != nil {
-			return err
+func (d *Downloader) download(ctx context.Context, url string, dst sink) error {
+	checksum := sha256.New()
+	var written int64
+
+	for attempt := 0; ; attempt++ {
+		n, restarted, err := d.downloadRange(ctx, url, dst, checksum, written)
+		if restarted {
+			written = 0
 		}
-		defer readCloser.Close()
+		written += n
 
-		path := filepath.Join(d.ExtractDir, zipFile.Name)
+		if err == nil {
+			break
+		}
 
-		// Check for ZipSlip (Directory traversal).
-		if !strings.HasPrefix(path, filepath.Clean(d.ExtractDir)+string(os.PathSeparator)) {
-			return fmt.Errorf("illegal file path: %s", path)
+		if ctx.Err() != nil || IsPermanent(err) || attempt >= d.MaxRetries {
+			return fmt.Errorf("failed to download package: %w", err)
 		}
 
-		if zipFile.FileInfo().IsDir() {
-			err = os.MkdirAll(path, os.ModePerm)
-			if err != nil {
-				return fmt.Errorf("failed to create directory: %w", err)
-			}
-		} else {
-			err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
-			if err != nil {
-				return fmt.Errorf("failed to create parent directory: %w", err)
-			}
+		delay := d.BaseBackoff << attempt
+		log.Infof("Download interrupted after %d bytes, retrying in %s: %s", written, delay, err)
 
-			file, err := os.Create(path)
-			if err != nil {
-				return fmt.Errorf("failed to create file: %w", err)
-			}
-			defer file.Close()
+		if err := sleep(ctx, delay); err != nil {
+			return fmt.Errorf("failed to download package: %w", err)
+		}
+	}
+
+	d.SHA256 = hex.EncodeToString(checksum.Sum(nil))
+	d.Size = written
+
+	return nil
+}
+
+// downloadRange downloads the package from the offset onwards, appending to dst. It returns the
+// number of bytes written and whether the server ignored the range, in which case dst and the
+// checksum were reset and written from the start.
+func (d *Downloader) downloadRange(ctx context.Context, url string, dst sink, checksum hash.Hash, offset int64) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
 
-			_, err = io.Copy(file, readCloser)
-			if err != nil {
-				return err
+	restarted := false
+	total := int64(-1)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		// Resume where the previous attempt stopped. Some servers answer with a range even when
+		// none was asked for, which is fine as long as it starts at the offset.
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			return 0, false, fmt.Errorf("unexpected content range %q for offset %d", resp.Header.Get("Content-Range"), offset)
+		}
+		total = size
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			// The server doesn't support ranges, start over.
+			if err := dst.Reset(); err != nil {
+				return 0, false, err
 			}
+			checksum.Reset()
+			offset = 0
+			restarted = true
 		}
+	default:
+		return 0, false, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	if err := checkContentType(resp.Header.Get("Content-Type")); err != nil {
+		return 0, restarted, err
+	}
+
+	if resp.ContentLength > 0 && offset+resp.ContentLength > d.MaxSize {
+		return 0, restarted, ErrTooLarge
+	}
+
+	// Read one byte past the limit to tell a package of exactly the max size from a larger one.
+	body := io.LimitReader(resp.Body, d.MaxSize-offset+1)
+	n, err := io.Copy(io.MultiWriter(dst, checksum), body)
+	if err != nil {
+		return n, restarted, err
+	}
+
+	if offset+n > d.MaxSize {
+		return n, restarted, ErrTooLarge
+	}
+
+	if resp.ContentLength > 0 && n < resp.ContentLength {
+		return n, restarted, io.ErrUnexpectedEOF
+	}
+
+	// A range that stops before the end of the package is resumed like an interrupted download.
+	if total >= 0 && offset+n < total {
+		return n, restarted, io.ErrUnexpectedEOF
+	}
+
+	return n, restarted, nil
+}
+
+// parseContentRange returns the start of a "bytes start-end/size" content range and the size,
+// which is -1 if it's unknown.
+func parseContentRange(contentRange string) (int64, int64, bool) {
+	rangeSpec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+
+	byteRange, size, ok := strings.Cut(rangeSpec, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	startSpec, _, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(strings.TrimSpace(startSpec), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if size == "*" {
+		return start, -1, true
+	}
+	total, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return start, total, true
+}
+
+// checkContentType rejects responses that are documents rather than packages. Registries serve
+// packages with a few different binary content types, so only known document types are rejected.
+func checkContentType(contentType string) error {
+	if contentType == "" {
 		return nil
 	}
 
-	// Extract all files.
-	for _, file := range reader.File {
-		err := extractAndWriteFile(file)
-		if err != nil {
-			return err
-		}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &ContentTypeError{ContentType: contentType}
+	}
+
+	if strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" ||
+		mediaType == "application/xml" ||
+		mediaType == "application/xhtml+xml" {
+		return &ContentTypeError{ContentType: contentType}
 	}
 
 	return nil
 }
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,251 @@
+package downloader_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/vscodethemes/backend/internal/downloader"
+)
+
+var body = bytes.Repeat([]byte("0123456789"), 100)
+
+// response answers one request. offset is the start of the requested range, 0 if there's none.
+type response func(w http.ResponseWriter, offset int)
+
+// interrupted sends the headers of the full package but only half of it.
+func interrupted(w http.ResponseWriter, offset int) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body[:len(body)/2])
+}
+
+// interruptedChunked sends half of the package without a length, so its size is unknown until
+// the download is resumed.
+func interruptedChunked(w http.ResponseWriter, offset int) {
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	half := body[:len(body)/2]
+	fmt.Fprintf(rw, "HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nTransfer-Encoding: chunked\r\n\r\n%x\r\n%s\r\n", len(half), half)
+	rw.Flush()
+}
+
+// full ignores the range and sends the whole package.
+func full(w http.ResponseWriter, offset int) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body)
+}
+
+// partial sends the package from start, which should be the requested offset.
+func partial(start int) response {
+	return func(w http.ResponseWriter, offset int) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(body)-1, len(body)))
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)-start))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(body[start:])
+	}
+}
+
+func resumed(w http.ResponseWriter, offset int) {
+	partial(offset)(w, offset)
+}
+
+func status(statusCode int) response {
+	return func(w http.ResponseWriter, offset int) {
+		w.WriteHeader(statusCode)
+	}
+}
+
+func contentType(value string) response {
+	return func(w http.ResponseWriter, offset int) {
+		w.Header().Set("Content-Type", value)
+		w.Write(body)
+	}
+}
+
+// newServer answers requests with the responses in order, and records the offset of each.
+func newServer(t *testing.T, responses ...response) (*httptest.Server, func() []int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	offsets := []int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset := 0
+		if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+			if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &offset); err != nil {
+				t.Errorf("invalid range %q", rangeHeader)
+			}
+		}
+
+		mu.Lock()
+		i := len(offsets)
+		offsets = append(offsets, offset)
+		mu.Unlock()
+
+		if i >= len(responses) {
+			t.Errorf("unexpected request %d", i+1)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		responses[i](w, offset)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int{}, offsets...)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	half := len(body) / 2
+
+	tests := []struct {
+		name      string
+		maxSize   int64
+		responses []response
+		offsets   []int
+		permanent bool
+		failed    bool
+	}{
+		{name: "complete", responses: []response{full}, offsets: []int{0}},
+		{name: "resumed", responses: []response{interrupted, resumed}, offsets: []int{0, half}},
+		{name: "range ignored", responses: []response{interrupted, full}, offsets: []int{0, half}},
+		{name: "range at wrong offset", responses: []response{interrupted, partial(0), resumed}, offsets: []int{0, half, half}},
+		{name: "range at wrong offset every time", responses: []response{interrupted, partial(0), partial(0)}, offsets: []int{0, half, half}, failed: true},
+		{name: "server error", responses: []response{status(http.StatusServiceUnavailable), full}, offsets: []int{0, 0}},
+		{name: "not found", responses: []response{status(http.StatusNotFound)}, offsets: []int{0}, failed: true, permanent: true},
+		{name: "html", responses: []response{contentType("text/html; charset=utf-8")}, offsets: []int{0}, failed: true, permanent: true},
+		{name: "json", responses: []response{contentType("application/json")}, offsets: []int{0}, failed: true, permanent: true},
+		{name: "binary", responses: []response{contentType("application/vsix")}, offsets: []int{0}},
+		{name: "too large", maxSize: int64(len(body)) - 1, responses: []response{full}, offsets: []int{0}, failed: true, permanent: true},
+		{name: "too large once resumed", maxSize: int64(len(body)) - 1, responses: []response{interruptedChunked, resumed}, offsets: []int{0, half}, failed: true, permanent: true},
+		{name: "exactly the max size", maxSize: int64(len(body)), responses: []response{full}, offsets: []int{0}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, offsets := newServer(t, test.responses...)
+
+			d := downloader.New(downloader.WithRetries(2, 0), downloader.WithMaxSize(cmp.Or(test.maxSize, downloader.DefaultMaxSize)))
+			data, err := d.Fetch(context.Background(), server.URL)
+
+			if test.failed {
+				if err == nil {
+					t.Fatalf("expected the download to fail")
+				}
+				if downloader.IsPermanent(err) != test.permanent {
+					t.Errorf("expected permanent to be %t, got %t: %v", test.permanent, downloader.IsPermanent(err), err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("failed to download: %v", err)
+				}
+				if !bytes.Equal(data, body) {
+					t.Errorf("expected the package, got %d bytes", len(data))
+				}
+				digest := sha256.Sum256(body)
+				if d.SHA256 != hex.EncodeToString(digest[:]) || d.Size != int64(len(body)) {
+					t.Errorf("unexpected checksum %s or size %d", d.SHA256, d.Size)
+				}
+			}
+
+			if got := offsets(); !slices.Equal(got, test.offsets) {
+				t.Errorf("expected requests at offsets %v, got %v", test.offsets, got)
+			}
+		})
+	}
+}
+
+func TestOpenRestartsBufferedFile(t *testing.T) {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	file, err := writer.Create("extension/package.json")
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	file.Write([]byte(`{"name":"theme"}`))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close package: %v", err)
+	}
+	vsix := buf.Bytes()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.Itoa(len(vsix)))
+		if requests == 1 {
+			w.Write(vsix[:len(vsix)/2])
+			return
+		}
+		// The range is ignored, the buffered half has to be discarded.
+		w.Write(vsix)
+	}))
+	defer server.Close()
+
+	d := downloader.New(downloader.WithRetries(1, 0), downloader.WithTempDir(t.TempDir()))
+	pkg, err := d.Open(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("failed to open package: %v", err)
+	}
+	defer pkg.Close()
+
+	packageJson, err := pkg.Open("extension/package.json")
+	if err != nil {
+		t.Fatalf("failed to open package.json: %v", err)
+	}
+	defer packageJson.Close()
+
+	data, err := io.ReadAll(packageJson)
+	if err != nil || string(data) != `{"name":"theme"}` {
+		t.Errorf("unexpected package.json %q: %v", data, err)
+	}
+	if d.Size != int64(len(vsix)) {
+		t.Errorf("expected size %d, got %d", len(vsix), d.Size)
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		err       error
+		permanent bool
+	}{
+		{err: downloader.ErrTooLarge, permanent: true},
+		{err: fmt.Errorf("wrapped: %w", downloader.ErrTooLarge), permanent: true},
+		{err: &downloader.ContentTypeError{ContentType: "text/html"}, permanent: true},
+		{err: &downloader.StatusError{StatusCode: http.StatusNotFound}, permanent: true},
+		{err: &downloader.StatusError{StatusCode: http.StatusForbidden}, permanent: true},
+		{err: &downloader.StatusError{StatusCode: http.StatusTooManyRequests}},
+		{err: &downloader.StatusError{StatusCode: http.StatusRequestTimeout}},
+		{err: &downloader.StatusError{StatusCode: http.StatusBadGateway}},
+		{err: io.ErrUnexpectedEOF},
+		{err: errors.New("connection reset")},
+	}
+
+	for _, test := range tests {
+		if got := downloader.IsPermanent(test.err); got != test.permanent {
+			t.Errorf("expected IsPermanent(%v) to be %t", test.err, test.permanent)
+		}
+	}
+}
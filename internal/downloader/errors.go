@@ -0,0 +1,56 @@
+package downloader
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrTooLarge is returned when the package is larger than the maximum package size.
+var ErrTooLarge = errors.New("package exceeds maximum size")
+
+// StatusError is returned when the package URL responds with an unexpected status code, after
+// retries for server errors have been used.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// ContentTypeError is returned when the response isn't a package, such as an HTML error page.
+type ContentTypeError struct {
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected content type: %s", e.ContentType)
+}
+
+// IsPermanent returns true if downloading or extracting the package again won't succeed.
+// Network errors and server errors are temporary, a missing, invalid or oversized package is not.
+func IsPermanent(err error) bool {
+	if errors.Is(err, ErrTooLarge) || errors.Is(err, ErrExtractLimit) || errors.Is(err, zip.ErrFormat) {
+		return true
+	}
+
+	var contentTypeErr *ContentTypeError
+	if errors.As(err, &contentTypeErr) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return !isRetryableStatus(statusErr.StatusCode)
+	}
+
+	return false
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests ||
+		statusCode >= 500
+}
@@ -0,0 +1,318 @@
+package downloader
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/vscodethemes/backend/internal/jsonc"
+)
+
+// ErrExtractLimit is returned when a package exceeds the extraction limits, such as a zip bomb.
+var ErrExtractLimit = errors.New("package exceeds extraction limits")
+
+// ExtractLimits protect the host from packages that expand to far more than their size.
+type ExtractLimits struct {
+	// MaxFiles is the maximum number of entries in the package.
+	MaxFiles int
+	// MaxSize is the maximum total uncompressed size of the extracted files.
+	MaxSize int64
+	// MaxFileSize is the maximum uncompressed size of a single file.
+	MaxFileSize int64
+	// MaxCompressionRatio is the maximum ratio of uncompressed to compressed size of a file.
+	// It's only checked for files larger than a megabyte, small files of repeated characters
+	// compress far better than any real package.
+	MaxCompressionRatio int64
+}
+
+var DefaultExtractLimits = ExtractLimits{
+	MaxFiles:            10000,
+	MaxSize:             500 << 20,
+	MaxFileSize:         100 << 20,
+	MaxCompressionRatio: 100,
+}
+
+const compressionRatioMinSize = 1 << 20
+
+const (
+	// VSIX packages have the manifest at the root and the extension in a subdirectory.
+	manifestPath      = "extension.vsixmanifest"
+	extensionDir      = "extension"
+	packageJsonPath   = "extension/package.json"
+	packageJsonAsset  = "Microsoft.VisualStudio.Code.Manifest"
+	maxThemeIncludes  = 10
+	packageNlsPattern = "package.nls*.json"
+)
+
+func WithExtractLimits(limits ExtractLimits) Option {
+	return func(d *Downloader) {
+		d.ExtractLimits = limits
+	}
+}
+
+// extract writes the included files of the package to dir.
+func extract(reader *zip.Reader, dir string, limits ExtractLimits, include func(name string) bool) error {
+	if len(reader.File) > limits.MaxFiles {
+		return fmt.Errorf("%w: %d files, the maximum is %d", ErrExtractLimit, len(reader.File), limits.MaxFiles)
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create extract directory: %w", err)
+	}
+
+	// Function to extract and write a file from a zip. This is called for
+	// each file in the zip and checks for ZipSlip. A local function is used
+	// instead of adding directly to the for loop below so that we can defer
+	// the closing of the file.
+	var totalSize int64
+	extractAndWriteFile := func(zipFile *zip.File) error {
+		path := filepath.Join(dir, zipFile.Name)
+
+		// Check for ZipSlip (Directory traversal).
+		if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal file path: %s", path)
+		}
+
+		if zipFile.FileInfo().IsDir() {
+			err = os.MkdirAll(path, os.ModePerm)
+			if err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+			return nil
+		}
+
+		// The sizes in the header can't be trusted, they're checked again while writing.
+		maxFileSize := min(limits.MaxFileSize, limits.MaxSize-totalSize)
+		if int64(zipFile.UncompressedSize64) > maxFileSize {
+			return fmt.Errorf("%w: %s is %d bytes uncompressed", ErrExtractLimit, zipFile.Name, zipFile.UncompressedSize64)
+		}
+
+		readCloser, err := zipFile.Open()
+		if err != nil {
+			return err
+		}
+		defer readCloser.Close()
+
+		err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("failed to create parent directory: %w", err)
+		}
+
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to create file: %w", err)
+		}
+		defer file.Close()
+
+		// Read one byte past the limit to tell a file of exactly the max size from a larger one.
+		n, err := io.Copy(file, io.LimitReader(readCloser, maxFileSize+1))
+		if err != nil {
+			return err
+		}
+		if n > maxFileSize {
+			return fmt.Errorf("%w: %s expands past %d bytes", ErrExtractLimit, zipFile.Name, maxFileSize)
+		}
+		if n > compressionRatioMinSize && n > int64(zipFile.CompressedSize64)*limits.MaxCompressionRatio {
+			return fmt.Errorf("%w: %s has a compression ratio over %d", ErrExtractLimit, zipFile.Name, limits.MaxCompressionRatio)
+		}
+
+		totalSize += n
+
+		return nil
+	}
+
+	// Extract the included files.
+	for _, file := range reader.File {
+		if !include(file.Name) {
+			continue
+		}
+
+		err := extractAndWriteFile(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// zipIndex finds the entries of a package by name. Theme paths are often written on
+// case-insensitive filesystems and don't match the case of their entries, so names are matched
+// case-insensitively if there's no exact match, like theme.Load and the editor do.
+type zipIndex struct {
+	files  map[string]*zip.File
+	folded map[string]*zip.File
+}
+
+func newZipIndex(reader *zip.Reader) zipIndex {
+	index := zipIndex{files: map[string]*zip.File{}, folded: map[string]*zip.File{}}
+	for _, file := range reader.File {
+		index.files[file.Name] = file
+		// The first entry wins if several only differ by case.
+		if _, ok := index.folded[strings.ToLower(file.Name)]; !ok {
+			index.folded[strings.ToLower(file.Name)] = file
+		}
+	}
+	return index
+}
+
+func (i zipIndex) lookup(name string) (*zip.File, bool) {
+	if file, ok := i.files[name]; ok {
+		return file, true
+	}
+	file, ok := i.folded[strings.ToLower(name)]
+	return file, ok
+}
+
+// selectThemeFiles returns the names of the zip entries needed to read the extension's themes.
+func selectThemeFiles(reader *zip.Reader, limits ExtractLimits) (map[string]bool, error) {
+	files := newZipIndex(reader)
+
+	selected := map[string]bool{manifestPath: true}
+
+	// The manifest points to package.json, it's nearly always at the same path.
+	packageJsonName := packageJsonPath
+	if manifest, ok := files.files[manifestPath]; ok {
+		data, err := readZipFile(manifest, limits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		if name := parsePackageJsonAsset(data); name != "" {
+			packageJsonName = name
+		}
+	}
+
+	packageJson, ok := files.lookup(packageJsonName)
+	if !ok {
+		return nil, fmt.Errorf("package.json not found at %s", packageJsonName)
+	}
+	packageJsonName = packageJson.Name
+	selected[packageJsonName] = true
+
+	// Translations live next to package.json.
+	packageDir := path.Dir(packageJsonName)
+	for name := range files.files {
+		if match, _ := path.Match(path.Join(packageDir, packageNlsPattern), name); match {
+			selected[name] = true
+		}
+	}
+
+	data, err := readZipFile(packageJson, limits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	var pkg struct {
+		Contributes struct {
+			Themes []struct {
+				Path string `json:"path"`
+			} `json:"themes"`
+		} `json:"contributes"`
+	}
+	if err := jsonc.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+
+	// Theme paths are relative to the extension directory.
+	for _, theme := range pkg.Contributes.Themes {
+		if theme.Path == "" {
+			continue
+		}
+		selectThemeFile(files, selected, path.Join(extensionDir, theme.Path), limits, 0)
+	}
+
+	return selected, nil
+}
+
+// selectThemeFile selects a theme file and follows its includes and tmTheme token colors, which
+// are relative to the theme file. Missing or invalid files are left for the theme parser to
+// report.
+func selectThemeFile(files zipIndex, selected map[string]bool, name string, limits ExtractLimits, depth int) {
+	file, ok := files.lookup(name)
+	if !ok || selected[file.Name] || depth > maxThemeIncludes {
+		return
+	}
+	name = file.Name
+	selected[name] = true
+
+	if !strings.EqualFold(path.Ext(name), ".json") {
+		return
+	}
+
+	data, err := readZipFile(file, limits)
+	if err != nil {
+		return
+	}
+
+	var theme struct {
+		Include     string          `json:"include"`
+		TokenColors json.RawMessage `json:"tokenColors"`
+	}
+	if err := jsonc.Unmarshal(data, &theme); err != nil {
+		return
+	}
+
+	dir := path.Dir(name)
+	if theme.Include != "" {
+		selectThemeFile(files, selected, path.Join(dir, theme.Include), limits, depth+1)
+	}
+
+	var tokenColorsPath string
+	if err := json.Unmarshal(theme.TokenColors, &tokenColorsPath); err == nil && tokenColorsPath != "" {
+		selectThemeFile(files, selected, path.Join(dir, tokenColorsPath), limits, depth+1)
+	}
+}
+
+// readZipFile reads a file in memory, within the max file size.
+func readZipFile(file *zip.File, limits ExtractLimits) ([]byte, error) {
+	if int64(file.UncompressedSize64) > limits.MaxFileSize {
+		return nil, fmt.Errorf("%w: %s is %d bytes uncompressed", ErrExtractLimit, file.Name, file.UncompressedSize64)
+	}
+
+	readCloser, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer readCloser.Close()
+
+	data, err := io.ReadAll(io.LimitReader(readCloser, limits.MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limits.MaxFileSize {
+		return nil, fmt.Errorf("%w: %s expands past %d bytes", ErrExtractLimit, file.Name, limits.MaxFileSize)
+	}
+
+	return data, nil
+}
+
+// parsePackageJsonAsset returns the path of package.json listed in the manifest's assets.
+func parsePackageJsonAsset(data []byte) string {
+	var manifest struct {
+		Assets struct {
+			Asset []struct {
+				Type string `xml:"Type,attr"`
+				Path string `xml:"Path,attr"`
+			} `xml:"Asset"`
+		} `xml:"Assets"`
+	}
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return ""
+	}
+
+	for _, asset := range manifest.Assets.Asset {
+		if asset.Type == packageJsonAsset {
+			return path.Clean(asset.Path)
+		}
+	}
+
+	return ""
+}
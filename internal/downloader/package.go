@@ -0,0 +1,190 @@
+package downloader
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// WithTempDir buffers packages opened with Open in a temporary file in dir instead of memory.
+func WithTempDir(dir string) Option {
+	return func(d *Downloader) {
+		d.TempDir = dir
+	}
+}
+
+// Package is a downloaded VSIX package that's read without extracting it. It implements fs.FS,
+// rooted at the root of the package, so the manifest is at "extension.vsixmanifest" and the
+// extension's files are under "extension/". Files are held to the extraction limits as they're
+// read.
+type Package struct {
+	SHA256 string
+	Size   int64
+
+	data   io.ReaderAt
+	reader *zip.Reader
+	files  map[string]*zip.File
+	limits ExtractLimits
+	close  func() error
+
+	mu        sync.Mutex
+	totalRead int64
+}
+
+// Open downloads the package at url and opens it for reading. Interrupted downloads are resumed
+// with a Range request instead of starting over. The package is buffered in memory, or in a
+// temporary file if a temp dir is set. Close must be called to release the buffer.
+func (d *Downloader) Open(ctx context.Context, url string) (*Package, error) {
+	return d.open(func(dst sink) error { return d.download(ctx, url, dst) })
+}
+
+// OpenReader opens a package read from r, such as a package that was stored earlier, with the
+// same size limit and buffering as downloaded packages.
+func (d *Downloader) OpenReader(r io.Reader) (*Package, error) {
+	return d.open(func(dst sink) error { return d.load(r, dst) })
+}
+
+func (d *Downloader) open(fill func(dst sink) error) (*Package, error) {
+	if d.TempDir == "" {
+		var buf bytes.Buffer
+		if err := fill(bufferSink{&buf}); err != nil {
+			return nil, err
+		}
+
+		return d.newPackage(bytes.NewReader(buf.Bytes()), func() error { return nil })
+	}
+
+	file, err := os.CreateTemp(d.TempDir, "*.VSIXPackage")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create package file: %w", err)
+	}
+	closeFile := func() error {
+		file.Close()
+		return os.Remove(file.Name())
+	}
+
+	if err := fill(fileSink{file}); err != nil {
+		closeFile()
+		return nil, err
+	}
+
+	pkg, err := d.newPackage(file, closeFile)
+	if err != nil {
+		closeFile()
+		return nil, err
+	}
+
+	return pkg, nil
+}
+
+func (d *Downloader) newPackage(r io.ReaderAt, close func() error) (*Package, error) {
+	reader, err := zip.NewReader(r, d.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package: %w", err)
+	}
+
+	if len(reader.File) > d.ExtractLimits.MaxFiles {
+		return nil, fmt.Errorf("%w: %d files, the maximum is %d", ErrExtractLimit, len(reader.File), d.ExtractLimits.MaxFiles)
+	}
+
+	files := map[string]*zip.File{}
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+
+	return &Package{
+		SHA256: d.SHA256,
+		Size:   d.Size,
+		data:   r,
+		reader: reader,
+		files:  files,
+		limits: d.ExtractLimits,
+		close:  close,
+	}, nil
+}
+
+func (p *Package) Close() error {
+	return p.close()
+}
+
+func (p *Package) Open(name string) (fs.File, error) {
+	file, err := p.reader.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	zipFile, ok := p.files[name]
+	if !ok {
+		// Directories don't always have an entry of their own.
+		return file, nil
+	}
+
+	if int64(zipFile.UncompressedSize64) > p.limits.MaxFileSize {
+		file.Close()
+		return nil, fmt.Errorf("%w: %s is %d bytes uncompressed", ErrExtractLimit, name, zipFile.UncompressedSize64)
+	}
+
+	return &packageFile{File: file, zipFile: zipFile, pkg: p}, nil
+}
+
+// NewReader returns a reader of the whole package, such as to store it.
+func (p *Package) NewReader() io.Reader {
+	return io.NewSectionReader(p.data, 0, p.Size)
+}
+
+// ExtractThemes writes only the files needed to read the extension's themes to dir, for tools
+// that read them from disk: the manifest, package.json, its translations, the theme files listed
+// in contributes.themes and the files they include.
+func (p *Package) ExtractThemes(dir string) error {
+	selected, err := selectThemeFiles(p.reader, p.limits)
+	if err != nil {
+		return err
+	}
+
+	return extract(p.reader, dir, p.limits, func(name string) bool { return selected[name] })
+}
+
+// read counts bytes read from the package against the total size limit.
+func (p *Package) read(n int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.totalRead += int64(n)
+	if p.totalRead > p.limits.MaxSize {
+		return fmt.Errorf("%w: more than %d bytes read", ErrExtractLimit, p.limits.MaxSize)
+	}
+
+	return nil
+}
+
+// packageFile enforces the extraction limits while a file is read, the sizes in the zip headers
+// can't be trusted.
+type packageFile struct {
+	fs.File
+	zipFile *zip.File
+	pkg     *Package
+	read    int64
+}
+
+func (f *packageFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.read += int64(n)
+
+	limits := f.pkg.limits
+	if f.read > limits.MaxFileSize {
+		return n, fmt.Errorf("%w: %s expands past %d bytes", ErrExtractLimit, f.zipFile.Name, limits.MaxFileSize)
+	}
+	if f.read > compressionRatioMinSize && f.read > int64(f.zipFile.CompressedSize64)*limits.MaxCompressionRatio {
+		return n, fmt.Errorf("%w: %s has a compression ratio over %d", ErrExtractLimit, f.zipFile.Name, limits.MaxCompressionRatio)
+	}
+	if limitErr := f.pkg.read(n); limitErr != nil {
+		return n, limitErr
+	}
+
+	return n, err
+}
@@ -0,0 +1,123 @@
+// Package imaging makes the raster renditions of preview images, for clients that can't display
+// SVG or need smaller images.
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+)
+
+type Format string
+
+const (
+	FormatPNG  Format = "png"
+	FormatWebP Format = "webp"
+)
+
+// ContentType returns the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatWebP:
+		return "image/webp"
+	default:
+		return "image/png"
+	}
+}
+
+// Rendition is an image file in one format and width.
+type Rendition struct {
+	Path   string
+	Format Format
+	Width  int
+}
+
+// Renditions writes the PNG at its own width and at each smaller width, as PNG and WebP, next to
+// it. The PNG itself is the PNG rendition at its own width.
+func Renditions(pngPath string, widths []int) ([]Rendition, error) {
+	file, err := os.Open(pngPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open png: %w", err)
+	}
+	defer file.Close()
+
+	src, err := png.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode png: %w", err)
+	}
+
+	bounds := src.Bounds()
+	base := strings.TrimSuffix(pngPath, filepath.Ext(pngPath))
+
+	renditions := []Rendition{{Path: pngPath, Format: FormatPNG, Width: bounds.Dx()}}
+	webpRendition, err := writeWebP(src, base+".webp")
+	if err != nil {
+		return nil, err
+	}
+	renditions = append(renditions, webpRendition)
+
+	widths = slices.Clone(widths)
+	slices.Sort(widths)
+	for _, width := range slices.Compact(widths) {
+		// Images are only scaled down.
+		if width <= 0 || width >= bounds.Dx() {
+			continue
+		}
+
+		height := max(1, bounds.Dy()*width/bounds.Dx())
+		dst := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+
+		name := fmt.Sprintf("%s-%d", base, width)
+
+		pngRendition, err := writePNG(dst, name+".png")
+		if err != nil {
+			return nil, err
+		}
+		webpRendition, err := writeWebP(dst, name+".webp")
+		if err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, pngRendition, webpRendition)
+	}
+
+	return renditions, nil
+}
+
+func writePNG(img image.Image, path string) (Rendition, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return Rendition{}, fmt.Errorf("failed to create png: %w", err)
+	}
+	defer file.Close()
+
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(file, img); err != nil {
+		return Rendition{}, fmt.Errorf("failed to encode png: %w", err)
+	}
+
+	return Rendition{Path: path, Format: FormatPNG, Width: img.Bounds().Dx()}, file.Close()
+}
+
+// writeWebP writes a lossless WebP, previews are mostly flat colors and text which lossy
+// compression blurs.
+func writeWebP(img image.Image, path string) (Rendition, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return Rendition{}, fmt.Errorf("failed to create webp: %w", err)
+	}
+	defer file.Close()
+
+	if err := nativewebp.Encode(file, img, nil); err != nil {
+		return Rendition{}, fmt.Errorf("failed to encode webp: %w", err)
+	}
+
+	return Rendition{Path: path, Format: FormatWebP, Width: img.Bounds().Dx()}, file.Close()
+}
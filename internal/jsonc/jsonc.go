@@ -0,0 +1,78 @@
+// Package jsonc reads the JSON with comments format used by VS Code for package.json and theme
+// files. Comments and trailing commas are allowed.
+package jsonc
+
+import (
+	"encoding/json"
+)
+
+// Unmarshal parses JSONC data into v, see encoding/json.Unmarshal.
+func Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(Standardize(data), v)
+}
+
+// Standardize converts JSONC to standard JSON by removing comments and trailing commas. Removed
+// comments are replaced with spaces, so that offsets in syntax errors still match the input.
+func Standardize(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	// Strip a byte order mark, editors on Windows often add one.
+	if len(out) >= 3 && out[0] == 0xEF && out[1] == 0xBB && out[2] == 0xBF {
+		out[0], out[1], out[2] = ' ', ' ', ' '
+	}
+
+	// Offset of the last comma outside of a string, -1 once something other than whitespace or
+	// a comment follows it.
+	lastComma := -1
+
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			lastComma = -1
+			// Skip to the end of the string, minding escaped characters.
+			for i++; i < len(out); i++ {
+				if out[i] == '\\' {
+					i++
+				} else if out[i] == '"' {
+					break
+				}
+			}
+
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			out[i], out[i+1] = ' ', ' '
+			for i += 2; i < len(out); i++ {
+				if out[i] == '*' && i+1 < len(out) && out[i+1] == '/' {
+					out[i], out[i+1] = ' ', ' '
+					i++
+					break
+				}
+				// Keep line breaks so line numbers in errors still match.
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+
+		case c == ',':
+			lastComma = i
+
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+
+		default:
+			lastComma = -1
+		}
+	}
+
+	return out
+}
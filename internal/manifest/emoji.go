@@ -0,0 +1,81 @@
+package manifest
+
+import (
+	"strings"
+	"unicode"
+)
+
+// emoji are the pictographic characters that publishers decorate names and descriptions with.
+var emoji = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x00a9, Hi: 0x00ae, Stride: 0x05},
+		{Lo: 0x203c, Hi: 0x2049, Stride: 0x0d},
+		{Lo: 0x2122, Hi: 0x2139, Stride: 0x17},
+		{Lo: 0x2194, Hi: 0x2199, Stride: 1},
+		{Lo: 0x21a9, Hi: 0x21aa, Stride: 1},
+		{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+		{Lo: 0x2328, Hi: 0x23cf, Stride: 0xa7},
+		{Lo: 0x23e9, Hi: 0x23f3, Stride: 1},
+		{Lo: 0x23f8, Hi: 0x23fa, Stride: 1},
+		{Lo: 0x24c2, Hi: 0x24c2, Stride: 1},
+		{Lo: 0x25aa, Hi: 0x25ab, Stride: 1},
+		{Lo: 0x25b6, Hi: 0x25c0, Stride: 0x0a},
+		{Lo: 0x25fb, Hi: 0x25fe, Stride: 1},
+		{Lo: 0x2600, Hi: 0x27bf, Stride: 1},
+		{Lo: 0x2934, Hi: 0x2935, Stride: 1},
+		{Lo: 0x2b05, Hi: 0x2b07, Stride: 1},
+		{Lo: 0x2b1b, Hi: 0x2b1c, Stride: 1},
+		{Lo: 0x2b50, Hi: 0x2b55, Stride: 0x05},
+		{Lo: 0x3030, Hi: 0x303d, Stride: 0x0d},
+		{Lo: 0x3297, Hi: 0x3299, Stride: 0x02},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1faff, Stride: 1},
+	},
+}
+
+// emojiModifiers join or modify emoji and are meaningless on their own: the zero width joiner,
+// variation selectors, the keycap combining mark and tag characters.
+var emojiModifiers = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x200d, Hi: 0x200d, Stride: 1},
+		{Lo: 0x20e3, Hi: 0x20e3, Stride: 1},
+		{Lo: 0xfe0e, Hi: 0xfe0f, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0xe0020, Hi: 0xe007f, Stride: 1},
+	},
+}
+
+// StripEmoji removes emoji from text, including keycap sequences such as 1️⃣.
+func StripEmoji(text string) string {
+	runes := []rune(text)
+
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if isKeycapBase(r) && isKeycap(runes[i+1:]) {
+			continue
+		}
+		if unicode.Is(emoji, r) || unicode.Is(emojiModifiers, r) {
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+func isKeycapBase(r rune) bool {
+	return (r >= '0' && r <= '9') || r == '#' || r == '*'
+}
+
+// isKeycap returns true if the runes following a keycap base make it a keycap emoji.
+func isKeycap(next []rune) bool {
+	if len(next) > 0 && next[0] == 0xfe0f {
+		next = next[1:]
+	}
+	return len(next) > 0 && next[0] == 0x20e3
+}
@@ -0,0 +1,166 @@
+// Package manifest reads the metadata and theme contributions of an extension from its
+// extension.vsixmanifest and package.json, without extracting or running anything.
+package manifest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/vscodethemes/backend/internal/cli"
+	"github.com/vscodethemes/backend/internal/jsonc"
+)
+
+// ErrInvalid is returned when the extension's manifest or package.json is missing or invalid.
+// Reading the same package again won't succeed.
+var ErrInvalid = errors.New("invalid extension")
+
+const (
+	ManifestPath = "extension.vsixmanifest"
+
+	packageJsonAsset   = "Microsoft.VisualStudio.Code.Manifest"
+	githubLinkProperty = "Microsoft.VisualStudio.Services.Links.GitHub"
+)
+
+type packageManifest struct {
+	Metadata *struct {
+		DisplayName *string `xml:"DisplayName"`
+		Description *string `xml:"Description"`
+		Properties  struct {
+			Property []struct {
+				ID    string `xml:"Id,attr"`
+				Value string `xml:"Value,attr"`
+			} `xml:"Property"`
+		} `xml:"Properties"`
+	} `xml:"Metadata"`
+	Assets struct {
+		Asset []struct {
+			Type string `xml:"Type,attr"`
+			Path string `xml:"Path,attr"`
+		} `xml:"Asset"`
+	} `xml:"Assets"`
+}
+
+type packageJson struct {
+	Contributes struct {
+		Themes []json.RawMessage `json:"themes"`
+	} `json:"contributes"`
+}
+
+// GetInfo reads the extension in fsys, which is rooted at the root of the package: the manifest
+// is at extension.vsixmanifest and package.json is wherever the manifest points to. Packages are
+// read without extracting them as a downloader.Package.
+func GetInfo(fsys fs.FS) (*cli.GetInfoResult, error) {
+	data, err := fs.ReadFile(fsys, ManifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read manifest: %w", ErrInvalid, err)
+	}
+
+	var manifest packageManifest
+	if err := xml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: invalid manifest xml: %w", ErrInvalid, err)
+	}
+	if manifest.Metadata == nil {
+		return nil, fmt.Errorf("%w: missing metadata in manifest", ErrInvalid)
+	}
+
+	displayName := cleanText(manifest.Metadata.DisplayName)
+	if displayName == "" {
+		return nil, fmt.Errorf("%w: missing extension name in manifest", ErrInvalid)
+	}
+
+	description := cleanText(manifest.Metadata.Description)
+	if description == "" {
+		return nil, fmt.Errorf("%w: missing extension description in manifest", ErrInvalid)
+	}
+
+	result := &cli.GetInfoResult{
+		Extension: cli.Extension{
+			DisplayName: displayName,
+			Description: description,
+		},
+	}
+
+	// The last matching property or asset wins, like in the marketplace.
+	for _, property := range manifest.Metadata.Properties.Property {
+		if property.ID == githubLinkProperty {
+			githubLink := property.Value
+			result.Extension.GithubLink = &githubLink
+		}
+	}
+
+	packageJsonPath := ""
+	for _, asset := range manifest.Assets.Asset {
+		if asset.Type == packageJsonAsset {
+			packageJsonPath = path.Clean(asset.Path)
+		}
+	}
+	if packageJsonPath == "" {
+		return nil, fmt.Errorf("%w: missing package.json path in manifest", ErrInvalid)
+	}
+	if !fs.ValidPath(packageJsonPath) {
+		return nil, fmt.Errorf("%w: invalid package.json path in manifest: %s", ErrInvalid, packageJsonPath)
+	}
+
+	data, err = fs.ReadFile(fsys, packageJsonPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read package.json: %w", ErrInvalid, err)
+	}
+
+	var pkg packageJson
+	if err := jsonc.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("%w: invalid json at %s: %w", ErrInvalid, packageJsonPath, err)
+	}
+
+	result.ThemeContributes = parseThemeContributes(pkg)
+
+	return result, nil
+}
+
+// parseThemeContributes returns the themes with a label, UI theme and path. Themes listed more
+// than once with the same path are only returned once, with the last listed values.
+func parseThemeContributes(pkg packageJson) []cli.ThemeContribute {
+	themeContributes := []cli.ThemeContribute{}
+	indexes := map[string]int{}
+
+	for _, raw := range pkg.Contributes.Themes {
+		var contribute struct {
+			Label   string `json:"label"`
+			UITheme string `json:"uiTheme"`
+			Path    string `json:"path"`
+		}
+		if err := json.Unmarshal(raw, &contribute); err != nil {
+			continue
+		}
+		if contribute.Label == "" || contribute.UITheme == "" || contribute.Path == "" {
+			continue
+		}
+
+		themeContribute := cli.ThemeContribute{
+			Path:    contribute.Path,
+			UITheme: contribute.UITheme,
+			Label:   &contribute.Label,
+		}
+
+		if i, ok := indexes[contribute.Path]; ok {
+			themeContributes[i] = themeContribute
+			continue
+		}
+		indexes[contribute.Path] = len(themeContributes)
+		themeContributes = append(themeContributes, themeContribute)
+	}
+
+	return themeContributes
+}
+
+// cleanText collapses whitespace and strips emoji from the text of a manifest element.
+func cleanText(text *string) string {
+	if text == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(StripEmoji(*text)), " ")
+}
@@ -0,0 +1,52 @@
+package marketplace
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned when the marketplace responds with a 404.
+var ErrNotFound = errors.New("marketplace resource not found")
+
+// ThrottledError is returned when the marketplace keeps responding with a 429 after all
+// retries have been used.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("marketplace throttled request, retry after %s", e.RetryAfter)
+	}
+	return "marketplace throttled request"
+}
+
+// ServerError is returned when the marketplace keeps responding with a 5xx after all
+// retries have been used.
+type ServerError struct {
+	StatusCode int
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("marketplace server error: %d", e.StatusCode)
+}
+
+// StatusError is returned for any other unexpected status code. These are not retried.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+func IsThrottled(err error) bool {
+	var throttledErr *ThrottledError
+	return errors.As(err, &throttledErr)
+}
+
+func IsServerError(err error) bool {
+	var serverErr *ServerError
+	return errors.As(err, &serverErr)
+}
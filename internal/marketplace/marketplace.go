@@ -4,17 +4,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
 
 	"github.com/vscodethemes/backend/internal/marketplace/qo"
+	"golang.org/x/time/rate"
 )
 
 type Client struct {
-	BaseUrl    string
-	httpClient *http.Client
+	BaseUrl     string
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	httpClient  *http.Client
+	limiter     Limiter
+	pause       *pause
 }
 
 type ClientOption func(*Client)
@@ -25,11 +32,40 @@ func WithBaseUrl(baseUrl string) ClientOption {
 	}
 }
 
+// WithLimiter replaces the default token bucket limiter.
+func WithLimiter(limiter Limiter) ClientOption {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// WithRateLimit sets the rate and burst of the default token bucket limiter.
+func WithRateLimit(limit rate.Limit, burst int) ClientOption {
+	return func(c *Client) {
+		c.limiter = rate.NewLimiter(limit, burst)
+	}
+}
+
+// WithRetries sets the number of retries for throttled and failed requests, and the
+// range of the exponential backoff between them.
+func WithRetries(maxRetries int, baseBackoff, maxBackoff time.Duration) ClientOption {
+	return func(c *Client) {
+		c.MaxRetries = maxRetries
+		c.BaseBackoff = baseBackoff
+		c.MaxBackoff = maxBackoff
+	}
+}
+
 func NewClient(opts ...ClientOption) *Client {
 	// Default client options
 	client := &Client{
-		BaseUrl:    "https://marketplace.visualstudio.com/_apis",
-		httpClient: &http.Client{},
+		BaseUrl:     "https://marketplace.visualstudio.com/_apis",
+		MaxRetries:  5,
+		BaseBackoff: 1 * time.Second,
+		MaxBackoff:  1 * time.Minute,
+		httpClient:  &http.Client{},
+		limiter:     rate.NewLimiter(rate.Every(1*time.Second), 1),
+		pause:       &pause{},
 	}
 
 	// Apply option overrides.
@@ -43,7 +79,7 @@ func NewClient(opts ...ClientOption) *Client {
 type QueryBody struct {
 	AssetTypes *string           `json:"assetTypes"`
 	Filters    []qo.QueryOptions `json:"filters"`
-	Flags      int               `json:"flags"`
+	Flags      qo.QueryFlags     `json:"flags"`
 }
 
 type QueryResponse struct {
@@ -80,21 +116,31 @@ type ExtensionPublisherResult struct {
 }
 
 type ExtensionVersionResult struct {
-	Version     string    `json:"version"`
-	Flags       string    `json:"flags"`
-	LastUpdated time.Time `json:"lastUpdated"`
-	Files       []struct {
-		AssetType string `json:"assetType"`
-		Source    string `json:"source"`
-	} `json:"files"`
-	Properties []struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
-	} `json:"properties"`
-	AssetURI         string `json:"assetUri"`
-	FallbackAssetURI string `json:"fallbackAssetUri"`
+	Version          string                           `json:"version"`
+	Flags            string                           `json:"flags"`
+	LastUpdated      time.Time                        `json:"lastUpdated"`
+	Files            []ExtensionVersionFileResult     `json:"files"`
+	Properties       []ExtensionVersionPropertyResult `json:"properties"`
+	TargetPlatform   string                           `json:"targetPlatform"`
+	AssetURI         string                           `json:"assetUri"`
+	FallbackAssetURI string                           `json:"fallbackAssetUri"`
+}
+
+type ExtensionVersionFileResult struct {
+	AssetType string `json:"assetType"`
+	Source    string `json:"source"`
+}
+
+type ExtensionVersionPropertyResult struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
 }
 
+const (
+	AssetTypeVSIXPackage   = "Microsoft.VisualStudio.Services.VSIXPackage"
+	AssetTypeVSIXSignature = "Microsoft.VisualStudio.Services.VsixSignature"
+)
+
 type ExtensionStatisticsResult struct {
 	StatisticName string  `json:"statisticName"`
 	Value         float64 `json:"value"`
@@ -105,37 +151,6 @@ type ExtensionInstallationTargetResult struct {
 	TargetVersion string `json:"targetVersion"`
 }
 
-func (e ExtensionResult) GetLatestVersion() *ExtensionVersionResult {
-	var latestVersion *ExtensionVersionResult
-	for _, version := range e.Versions {
-		if latestVersion == nil {
-			latestVersion = &version
-			continue
-		}
-
-		if version.LastUpdated.After(latestVersion.LastUpdated) {
-			latestVersion = &version
-		}
-	}
-
-	return latestVersion
-}
-
-func (e ExtensionResult) GetPackageURL() string {
-	latestVersion := e.GetLatestVersion()
-	if latestVersion == nil {
-		return ""
-	}
-
-	for _, file := range latestVersion.Files {
-		if file.AssetType == "Microsoft.VisualStudio.Services.VSIXPackage" {
-			return file.Source
-		}
-	}
-
-	return ""
-}
-
 func (m Client) NewQuery(ctx context.Context, opts ...qo.QueryOption) ([]ExtensionResult, error) {
 	// Default query options.
 	queryOptions := &qo.QueryOptions{
@@ -144,6 +159,7 @@ func (m Client) NewQuery(ctx context.Context, opts ...qo.QueryOption) ([]Extensi
 		SortBy:     qo.SortByLastUpdated,
 		Direction:  qo.DirectionAsc,
 		Criteria:   []qo.QueryOptionCriteria{},
+		Flags:      qo.DefaultFlags,
 	}
 
 	// Apply option overrides.
@@ -154,7 +170,7 @@ func (m Client) NewQuery(ctx context.Context, opts ...qo.QueryOption) ([]Extensi
 	// Build the query body.
 	reqBody := QueryBody{
 		Filters: []qo.QueryOptions{*queryOptions},
-		Flags:   870, // TODO: Do we need this?
+		Flags:   queryOptions.Flags,
 	}
 
 	reqJson, err := json.Marshal(reqBody)
@@ -162,9 +178,117 @@ func (m Client) NewQuery(ctx context.Context, opts ...qo.QueryOption) ([]Extensi
 		return nil, fmt.Errorf("failed to marshal query body: %w", err)
 	}
 
+	resBody, err := m.send(ctx, reqJson)
+	if err != nil {
+		return nil, err
+	}
+
+	var queryRes QueryResponse
+	if err := json.Unmarshal(resBody, &queryRes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	if len(queryRes.Results) == 0 {
+		return nil, fmt.Errorf("no results found")
+	}
+
+	return queryRes.Results[0].Extensions, nil
+}
+
+// The marketplace doesn't document a limit on the number of criteria in a filter, so lookups
+// are split into chunks to keep requests small.
+const maxCriteriaPerQuery = 100
+
+// GetExtensionsBySlug resolves many extensions by their "publisher.extension" slug. Extensions
+// that don't exist are left out of the results.
+func (m Client) GetExtensionsBySlug(ctx context.Context, slugs []string, opts ...qo.QueryOption) ([]ExtensionResult, error) {
+	return m.queryByCriteria(ctx, qo.FilterTypeSlug, slugs, opts)
+}
+
+// GetExtensionsByID resolves many extensions by their marketplace ID. Extensions that don't
+// exist are left out of the results.
+func (m Client) GetExtensionsByID(ctx context.Context, extensionIDs []string, opts ...qo.QueryOption) ([]ExtensionResult, error) {
+	return m.queryByCriteria(ctx, qo.FilterTypeExtensionID, extensionIDs, opts)
+}
+
+// queryByCriteria adds every value as a criteria of a single filter, which the marketplace
+// matches as a union, and pages through the results. Each value matches at most one extension,
+// so paging stops once there are as many results as values or a page isn't full.
+func (m Client) queryByCriteria(ctx context.Context, filterType qo.QueryOptionsFilterType, values []string, opts []qo.QueryOption) ([]ExtensionResult, error) {
+	results := []ExtensionResult{}
+	for start := 0; start < len(values); start += maxCriteriaPerQuery {
+		chunk := values[start:min(start+maxCriteriaPerQuery, len(values))]
+
+		chunkResults := 0
+		for pageNumber := 1; chunkResults < len(chunk); pageNumber++ {
+			queryOpts := append([]qo.QueryOption{}, opts...)
+			queryOpts = append(queryOpts, qo.WithPageNumber(pageNumber), qo.WithPageSize(len(chunk)))
+			for _, value := range chunk {
+				queryOpts = append(queryOpts, qo.WithCriteria(filterType, value))
+			}
+
+			queryResults, err := m.NewQuery(ctx, queryOpts...)
+			if err != nil {
+				return nil, err
+			}
+
+			results = append(results, queryResults...)
+			chunkResults += len(queryResults)
+
+			if len(queryResults) < len(chunk) {
+				break
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// send posts a query to the marketplace, retrying throttled requests and server errors
+// with a jittered exponential backoff.
+func (m Client) send(ctx context.Context, reqJson []byte) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt <= m.MaxRetries; attempt++ {
+		if attempt > 0 {
+			delay := backoff(attempt-1, m.BaseBackoff, m.MaxBackoff)
+
+			var throttledErr *ThrottledError
+			if errors.As(lastErr, &throttledErr) && throttledErr.RetryAfter > delay {
+				delay = throttledErr.RetryAfter
+			}
+
+			if err := sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+
+		resBody, err := m.sendOnce(ctx, reqJson)
+		if err == nil {
+			return resBody, nil
+		}
+
+		if !IsThrottled(err) && !IsServerError(err) {
+			return nil, err
+		}
+
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+func (m Client) sendOnce(ctx context.Context, reqJson []byte) ([]byte, error) {
+	if err := m.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := m.pause.wait(ctx); err != nil {
+		return nil, err
+	}
+
 	// Build the request.
 	url := m.BaseUrl + "/public/gallery/extensionquery"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqJson))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqJson))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -178,8 +302,30 @@ func (m Client) NewQuery(ctx context.Context, opts ...qo.QueryOption) ([]Extensi
 	}
 	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	switch {
+	case res.StatusCode == http.StatusOK:
+	case res.StatusCode == http.StatusNotFound:
+		return nil, ErrNotFound
+	case res.StatusCode == http.StatusTooManyRequests:
+		// Hold back every request from this client, not just this one.
+		retryAfter := parseRetryAfter(res.Header.Get("Retry-After"))
+		pauseFor := retryAfter
+		if pauseFor <= 0 {
+			pauseFor = m.BaseBackoff
+		}
+		m.pause.extend(pauseFor)
+
+		throttledErr := &ThrottledError{RetryAfter: retryAfter}
+		if pauser, ok := m.limiter.(Pauser); ok {
+			if err := pauser.Pause(ctx, pauseFor); err != nil {
+				return nil, errors.Join(throttledErr, err)
+			}
+		}
+		return nil, throttledErr
+	case isRetryableStatus(res.StatusCode):
+		return nil, &ServerError{StatusCode: res.StatusCode}
+	default:
+		return nil, &StatusError{StatusCode: res.StatusCode}
 	}
 
 	// Read the response body.
@@ -188,14 +334,5 @@ func (m Client) NewQuery(ctx context.Context, opts ...qo.QueryOption) ([]Extensi
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	var queryRes QueryResponse
-	if err := json.Unmarshal(resBody, &queryRes); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response body: %w", err)
-	}
-
-	if len(queryRes.Results) == 0 {
-		return nil, fmt.Errorf("no results found")
-	}
-
-	return queryRes.Results[0].Extensions, nil
+	return resBody, nil
 }
@@ -0,0 +1,111 @@
+package marketplace_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vscodethemes/backend/internal/marketplace"
+	"github.com/vscodethemes/backend/internal/marketplace/marketplacetest"
+	"golang.org/x/time/rate"
+)
+
+func newTestClient(server *marketplacetest.Server, maxRetries int) *marketplace.Client {
+	return marketplace.NewClient(
+		marketplace.WithBaseUrl(server.URL),
+		marketplace.WithRateLimit(rate.Inf, 1),
+		marketplace.WithRetries(maxRetries, 10*time.Millisecond, 100*time.Millisecond),
+	)
+}
+
+func TestClientHonoursRetryAfter(t *testing.T) {
+	server := marketplacetest.NewServer()
+	defer server.Close()
+	server.AddExtension(marketplacetest.NewExtension("publisher", "theme"), nil)
+	server.ThrottleNext(1, time.Second)
+
+	start := time.Now()
+	extensions, err := newTestClient(server, 3).GetExtensionsBySlug(context.Background(), []string{"publisher.theme"})
+	if err != nil {
+		t.Fatalf("expected the throttled request to be retried, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected the retry to wait for Retry-After, it was sent after %s", elapsed)
+	}
+	if len(extensions) != 1 {
+		t.Errorf("expected 1 extension, got %d", len(extensions))
+	}
+	if requests := server.Requests(); requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+}
+
+func TestClientGivesUpWhenThrottled(t *testing.T) {
+	server := marketplacetest.NewServer()
+	defer server.Close()
+	server.AddExtension(marketplacetest.NewExtension("publisher", "theme"), nil)
+	server.ThrottleNext(3, 0)
+
+	_, err := newTestClient(server, 2).GetExtensionsBySlug(context.Background(), []string{"publisher.theme"})
+	if !marketplace.IsThrottled(err) {
+		t.Fatalf("expected a ThrottledError, got %v", err)
+	}
+	if requests := server.Requests(); requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	server := marketplacetest.NewServer()
+	defer server.Close()
+	server.AddExtension(marketplacetest.NewExtension("publisher", "theme"), nil)
+	server.FailNext(2, http.StatusServiceUnavailable)
+
+	extensions, err := newTestClient(server, 3).GetExtensionsBySlug(context.Background(), []string{"publisher.theme"})
+	if err != nil {
+		t.Fatalf("expected server errors to be retried, got %v", err)
+	}
+	if len(extensions) != 1 {
+		t.Errorf("expected 1 extension, got %d", len(extensions))
+	}
+	if requests := server.Requests(); requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestClientRetriesWithoutBackoff(t *testing.T) {
+	server := marketplacetest.NewServer()
+	defer server.Close()
+	server.AddExtension(marketplacetest.NewExtension("publisher", "theme"), nil)
+	server.FailNext(2, http.StatusServiceUnavailable)
+
+	client := marketplace.NewClient(
+		marketplace.WithBaseUrl(server.URL),
+		marketplace.WithRateLimit(rate.Inf, 1),
+		marketplace.WithRetries(3, 0, 0),
+	)
+	if _, err := client.GetExtensionsBySlug(context.Background(), []string{"publisher.theme"}); err != nil {
+		t.Fatalf("expected server errors to be retried, got %v", err)
+	}
+	if requests := server.Requests(); requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	server := marketplacetest.NewServer()
+	defer server.Close()
+	server.FailNext(1, http.StatusBadRequest)
+
+	_, err := newTestClient(server, 3).GetExtensionsBySlug(context.Background(), []string{"publisher.theme"})
+	var statusErr *marketplace.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a StatusError, got %v", err)
+	}
+	if requests := server.Requests(); requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+}
@@ -0,0 +1,482 @@
+// Package marketplacetest provides an in-process fake of the Visual Studio Marketplace, for
+// running the scan, sync and stats workers without a network connection.
+package marketplacetest
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vscodethemes/backend/internal/marketplace"
+	"github.com/vscodethemes/backend/internal/marketplace/qo"
+)
+
+// Server implements the /public/gallery/extensionquery endpoint and serves the VSIX package of
+// each extension. Pass Server.URL to marketplace.WithBaseUrl.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	extensions []marketplace.ExtensionResult
+	packages   map[string][]byte
+	signatures map[string][]byte
+	latency    time.Duration
+	failures   []failure
+	requests   int
+}
+
+type failure struct {
+	statusCode int
+	retryAfter string
+}
+
+func NewServer() *Server {
+	s := &Server{
+		packages:   map[string][]byte{},
+		signatures: map[string][]byte{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /public/gallery/extensionquery", s.handleQuery)
+	mux.HandleFunc("GET /packages/{slug}/{version}", s.handlePackage)
+	mux.HandleFunc("GET /signatures/{slug}/{version}", s.handleSignature)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// NewExtension returns an extension with every field the workers rely on filled in. Its package
+// is served once it's added with AddExtension.
+func NewExtension(publisherName, extensionName string) marketplace.ExtensionResult {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	return marketplace.ExtensionResult{
+		Publisher: marketplace.ExtensionPublisherResult{
+			PublisherID:   fmt.Sprintf("%s-id", publisherName),
+			PublisherName: publisherName,
+			DisplayName:   publisherName,
+		},
+		ExtensionID:   fmt.Sprintf("%s.%s-id", publisherName, extensionName),
+		ExtensionName: extensionName,
+		DisplayName:   extensionName,
+		LastUpdated:   now.Format(time.RFC3339),
+		PublishedDate: now.Format(time.RFC3339),
+		ReleaseDate:   now.Format(time.RFC3339),
+		Categories:    []string{"Themes"},
+		Versions: []marketplace.ExtensionVersionResult{
+			{Version: "1.0.0", LastUpdated: now},
+		},
+		Stastistics: []marketplace.ExtensionStatisticsResult{
+			{StatisticName: "install", Value: 0},
+		},
+		InstallationTargets: []marketplace.ExtensionInstallationTargetResult{
+			{Target: "Microsoft.VisualStudio.Code"},
+		},
+	}
+}
+
+// AddExtension adds an extension to the server. The package URL of its latest version is
+// pointed at the server, which responds with vsix. A nil vsix leaves the package URL untouched.
+func (s *Server) AddExtension(extension marketplace.ExtensionResult, vsix []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slug := fmt.Sprintf("%s.%s", extension.Publisher.PublisherName, extension.ExtensionName)
+
+	if vsix != nil && len(extension.Versions) > 0 {
+		// Copy the versions so the caller's extension isn't modified.
+		extension.Versions = slices.Clone(extension.Versions)
+		version := &extension.Versions[0]
+		version.Files = slices.DeleteFunc(slices.Clone(version.Files), func(file marketplace.ExtensionVersionFileResult) bool {
+			return file.AssetType == marketplace.AssetTypeVSIXPackage
+		})
+		version.Files = append(version.Files, marketplace.ExtensionVersionFileResult{
+			AssetType: marketplace.AssetTypeVSIXPackage,
+			Source:    fmt.Sprintf("%s/packages/%s/%s", s.URL, slug, version.Version),
+		})
+		s.packages[slug+"/"+version.Version] = vsix
+	}
+
+	s.extensions = slices.DeleteFunc(s.extensions, func(e marketplace.ExtensionResult) bool {
+		return e.ExtensionID == extension.ExtensionID
+	})
+	s.extensions = append(s.extensions, extension)
+}
+
+// AddSignature serves a signature archive for the latest version of an extension that was added,
+// see signaturetest for creating one.
+func (s *Server) AddSignature(publisherName, extensionName string, archive []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slug := fmt.Sprintf("%s.%s", publisherName, extensionName)
+
+	for i, extension := range s.extensions {
+		if extension.Publisher.PublisherName != publisherName || extension.ExtensionName != extensionName || len(extension.Versions) == 0 {
+			continue
+		}
+
+		extension.Versions = slices.Clone(extension.Versions)
+		version := &extension.Versions[0]
+		version.Files = slices.DeleteFunc(slices.Clone(version.Files), func(file marketplace.ExtensionVersionFileResult) bool {
+			return file.AssetType == marketplace.AssetTypeVSIXSignature
+		})
+		version.Files = append(version.Files, marketplace.ExtensionVersionFileResult{
+			AssetType: marketplace.AssetTypeVSIXSignature,
+			Source:    fmt.Sprintf("%s/signatures/%s/%s", s.URL, slug, version.Version),
+		})
+		s.signatures[slug+"/"+version.Version] = archive
+		s.extensions[i] = extension
+	}
+}
+
+// RemoveExtension removes an extension, as if it was unpublished from the marketplace.
+func (s *Server) RemoveExtension(publisherName, extensionName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.extensions = slices.DeleteFunc(s.extensions, func(e marketplace.ExtensionResult) bool {
+		return strings.EqualFold(e.Publisher.PublisherName, publisherName) && strings.EqualFold(e.ExtensionName, extensionName)
+	})
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = d
+}
+
+// FailNext responds to the next n requests with the given status code.
+func (s *Server) FailNext(n int, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for range n {
+		s.failures = append(s.failures, failure{statusCode: statusCode})
+	}
+}
+
+// ThrottleNext responds to the next n requests with a 429 and the given Retry-After, which is
+// left out if zero.
+func (s *Server) ThrottleNext(n int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	header := ""
+	if retryAfter > 0 {
+		header = strconv.Itoa(int(retryAfter.Seconds()))
+	}
+
+	for range n {
+		s.failures = append(s.failures, failure{statusCode: http.StatusTooManyRequests, retryAfter: header})
+	}
+}
+
+// Requests returns the number of requests received, including failed ones.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests
+}
+
+// intercept applies the latency and failures. It returns false if the request was handled.
+func (s *Server) intercept(w http.ResponseWriter, r *http.Request) bool {
+	s.mu.Lock()
+	s.requests++
+	latency := s.latency
+	var next *failure
+	if len(s.failures) > 0 {
+		next = &s.failures[0]
+		s.failures = s.failures[1:]
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-time.After(latency):
+		}
+	}
+
+	if next != nil {
+		if next.retryAfter != "" {
+			w.Header().Set("Retry-After", next.retryAfter)
+		}
+		http.Error(w, http.StatusText(next.statusCode), next.statusCode)
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if !s.intercept(w, r) {
+		return
+	}
+
+	var body marketplace.QueryBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res := marketplace.QueryResponse{}
+	for _, filter := range body.Filters {
+		res.Results = append(res.Results, marketplace.QueryResult{
+			Extensions: s.query(filter),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handlePackage(w http.ResponseWriter, r *http.Request) {
+	if !s.intercept(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	vsix, ok := s.packages[r.PathValue("slug")+"/"+r.PathValue("version")]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(vsix)
+}
+
+func (s *Server) handleSignature(w http.ResponseWriter, r *http.Request) {
+	if !s.intercept(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	archive, ok := s.signatures[r.PathValue("slug")+"/"+r.PathValue("version")]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(archive)
+}
+
+// query applies a filter the way the marketplace does: criteria of the same type match any of
+// their values, criteria of different types must all match.
+func (s *Server) query(filter qo.QueryOptions) []marketplace.ExtensionResult {
+	criteria := map[qo.QueryOptionsFilterType][]string{}
+	for _, c := range filter.Criteria {
+		criteria[c.FilterType] = append(criteria[c.FilterType], c.Value)
+	}
+
+	s.mu.Lock()
+	matches := []marketplace.ExtensionResult{}
+	for _, extension := range s.extensions {
+		if matchesCriteria(extension, criteria) {
+			matches = append(matches, extension)
+		}
+	}
+	s.mu.Unlock()
+
+	slices.SortStableFunc(matches, func(a, b marketplace.ExtensionResult) int {
+		var c int
+		switch filter.SortBy {
+		case qo.SortByInstalls:
+			c = cmp.Compare(installs(a), installs(b))
+		case qo.SortByPublishedDate:
+			c = cmp.Compare(a.PublishedDate, b.PublishedDate)
+		default:
+			c = cmp.Compare(a.LastUpdated, b.LastUpdated)
+		}
+		if filter.Direction == qo.DirectionDesc {
+			c = -c
+		}
+		return c
+	})
+
+	// Page numbers start at 1.
+	start := (filter.PageNumber - 1) * filter.PageSize
+	if start < 0 || start >= len(matches) {
+		return []marketplace.ExtensionResult{}
+	}
+
+	return matches[start:min(start+filter.PageSize, len(matches))]
+}
+
+func matchesCriteria(extension marketplace.ExtensionResult, criteria map[qo.QueryOptionsFilterType][]string) bool {
+	for filterType, values := range criteria {
+		matches := slices.ContainsFunc(values, func(value string) bool {
+			switch filterType {
+			case qo.FilterTypeSlug:
+				slug := fmt.Sprintf("%s.%s", extension.Publisher.PublisherName, extension.ExtensionName)
+				return strings.EqualFold(slug, value)
+			case qo.FilterTypeExtensionID:
+				return strings.EqualFold(extension.ExtensionID, value)
+			case qo.FilterTypePublisherName:
+				return strings.EqualFold(extension.Publisher.PublisherName, value)
+			case qo.FilterTypeCategory:
+				return slices.Contains(extension.Categories, value)
+			case qo.FilterTypeTag:
+				return slices.Contains(extension.Tags, value)
+			case qo.FilterTypeTarget:
+				return slices.ContainsFunc(extension.InstallationTargets, func(t marketplace.ExtensionInstallationTargetResult) bool {
+					return t.Target == value
+				})
+			case qo.FilterTypeSearchText:
+				return matchesSearchText(extension, value)
+			default:
+				// Flags and featured filters aren't modelled, every extension matches.
+				return true
+			}
+		})
+
+		if !matches {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesSearchText matches the words of the text against the extension's names. Qualifiers
+// such as target:"Microsoft.VisualStudio.Code" are ignored.
+func matchesSearchText(extension marketplace.ExtensionResult, text string) bool {
+	haystack := strings.ToLower(strings.Join([]string{
+		extension.ExtensionName,
+		extension.DisplayName,
+		extension.Publisher.PublisherName,
+		extension.Publisher.DisplayName,
+	}, " "))
+
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		if strings.Contains(word, ":") {
+			continue
+		}
+		if !strings.Contains(haystack, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func installs(extension marketplace.ExtensionResult) float64 {
+	for _, statistic := range extension.Stastistics {
+		if statistic.StatisticName == "install" {
+			return statistic.Value
+		}
+	}
+	return 0
+}
+
+// BuildVSIX zips the files into a VSIX package. Paths are relative to the root of the package,
+// e.g. "extension/package.json".
+func BuildVSIX(files map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	zipWriter := zip.NewWriter(&buf)
+
+	// Write the files in a stable order.
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		fileWriter, err := zipWriter.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", name, err)
+		}
+
+		if _, err := fileWriter.Write([]byte(files[name])); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close package: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ThemeVSIX builds a VSIX package with a manifest, a package.json contributing the given themes
+// and the theme files themselves. Themes are keyed by their path relative to the extension.
+func ThemeVSIX(publisherName, extensionName string, themes map[string]string) ([]byte, error) {
+	type themeContribute struct {
+		Label   string `json:"label"`
+		UITheme string `json:"uiTheme"`
+		Path    string `json:"path"`
+	}
+
+	files := map[string]string{}
+	contributes := []themeContribute{}
+	for path, theme := range themes {
+		files["extension/"+strings.TrimPrefix(path, "./")] = theme
+		contributes = append(contributes, themeContribute{
+			Label:   path,
+			UITheme: "vs-dark",
+			Path:    path,
+		})
+	}
+	slices.SortFunc(contributes, func(a, b themeContribute) int {
+		return cmp.Compare(a.Path, b.Path)
+	})
+
+	packageJson, err := json.MarshalIndent(map[string]any{
+		"name":        extensionName,
+		"displayName": extensionName,
+		"publisher":   publisherName,
+		"version":     "1.0.0",
+		"contributes": map[string]any{
+			"themes": contributes,
+		},
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal package.json: %w", err)
+	}
+	files["extension/package.json"] = string(packageJson)
+
+	files["extension.vsixmanifest"] = fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<PackageManifest Version="2.0.0" xmlns="http://schemas.microsoft.com/developer/vsx-schema/2011">
+  <Metadata>
+    <Identity Language="en-US" Id="%[2]s" Version="1.0.0" Publisher="%[1]s" />
+    <DisplayName>%[2]s</DisplayName>
+    <Description xml:space="preserve">%[2]s themes by %[1]s</Description>
+    <Categories>Themes</Categories>
+    <Properties>
+      <Property Id="Microsoft.VisualStudio.Services.Links.GitHub" Value="https://github.com/%[1]s/%[2]s" />
+    </Properties>
+  </Metadata>
+  <Installation>
+    <InstallationTarget Id="Microsoft.VisualStudio.Code"/>
+  </Installation>
+  <Assets>
+    <Asset Type="Microsoft.VisualStudio.Code.Manifest" Path="extension/package.json" Addressable="true" />
+  </Assets>
+</PackageManifest>
+`, publisherName, extensionName)
+
+	return BuildVSIX(files)
+}
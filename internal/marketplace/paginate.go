@@ -0,0 +1,49 @@
+package marketplace
+
+import (
+	"context"
+	"iter"
+
+	"github.com/vscodethemes/backend/internal/marketplace/qo"
+)
+
+// Paginate yields every item from consecutive pages, starting at page 1, until a page is empty.
+// Fetching stops as soon as the caller stops iterating, the context is done or a page fails, in
+// which case the error is yielded once.
+func Paginate[T any](ctx context.Context, fetchPage func(ctx context.Context, pageNumber int) ([]T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		for pageNumber := 1; ; pageNumber++ {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+
+			items, err := fetchPage(ctx, pageNumber)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+
+			if len(items) == 0 {
+				return
+			}
+
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Query yields every extension matching the query options, fetching pages as needed. The page
+// number option is ignored, pages always start at 1.
+func (m Client) Query(ctx context.Context, opts ...qo.QueryOption) iter.Seq2[ExtensionResult, error] {
+	return Paginate(ctx, func(ctx context.Context, pageNumber int) ([]ExtensionResult, error) {
+		pageOpts := append([]qo.QueryOption{}, opts...)
+		pageOpts = append(pageOpts, qo.WithPageNumber(pageNumber))
+		return m.NewQuery(ctx, pageOpts...)
+	})
+}
@@ -1,11 +1,15 @@
 package qo
 
+import "strconv"
+
 type QueryOptions struct {
 	PageNumber int                   `json:"pageNumber"`
 	PageSize   int                   `json:"pageSize"`
 	SortBy     QueryOptionSortBy     `json:"sortBy"`
 	Direction  QueryOptionDirection  `json:"direction"`
 	Criteria   []QueryOptionCriteria `json:"criteria"`
+	// Flags are sent with the query body rather than the filter.
+	Flags QueryFlags `json:"-"`
 }
 
 type QueryOption func(o *QueryOptions)
@@ -33,13 +37,78 @@ type QueryOptionCriteria struct {
 type QueryOptionsFilterType int
 
 const (
-	FilterTypeCategory  QueryOptionsFilterType = 5
-	FilterTypeSlug      QueryOptionsFilterType = 7
-	FilterTypeUnknown8  QueryOptionsFilterType = 8
-	FilterTypeUnknown10 QueryOptionsFilterType = 10
-	FilterTypeUnknown12 QueryOptionsFilterType = 12
+	FilterTypeTag              QueryOptionsFilterType = 1
+	FilterTypeExtensionID      QueryOptionsFilterType = 4
+	FilterTypeCategory         QueryOptionsFilterType = 5
+	FilterTypeSlug             QueryOptionsFilterType = 7
+	FilterTypeTarget           QueryOptionsFilterType = 8
+	FilterTypeFeatured         QueryOptionsFilterType = 9
+	FilterTypeSearchText       QueryOptionsFilterType = 10
+	FilterTypeExcludeWithFlags QueryOptionsFilterType = 12
+	FilterTypePublisherName    QueryOptionsFilterType = 18
+)
+
+// QueryFlags decide what is included in each extension of the response.
+type QueryFlags int
+
+const (
+	FlagNone                       QueryFlags = 0x0
+	FlagIncludeVersions            QueryFlags = 0x1
+	FlagIncludeFiles               QueryFlags = 0x2
+	FlagIncludeCategoryAndTags     QueryFlags = 0x4
+	FlagIncludeSharedAccounts      QueryFlags = 0x8
+	FlagIncludeVersionProperties   QueryFlags = 0x10
+	FlagExcludeNonValidated        QueryFlags = 0x20
+	FlagIncludeInstallationTargets QueryFlags = 0x40
+	FlagIncludeAssetUri            QueryFlags = 0x80
+	FlagIncludeStatistics          QueryFlags = 0x100
+	FlagIncludeLatestVersionOnly   QueryFlags = 0x200
+	FlagUseFallbackAssetUri        QueryFlags = 0x400
+	FlagIncludeMetadata            QueryFlags = 0x800
+	FlagIncludeNameConflictInfo    QueryFlags = 0x8000
 )
 
+// DefaultFlags return the latest version of each extension with its files, categories, tags,
+// installation targets and statistics.
+const DefaultFlags = FlagIncludeFiles |
+	FlagIncludeCategoryAndTags |
+	FlagExcludeNonValidated |
+	FlagIncludeInstallationTargets |
+	FlagIncludeStatistics |
+	FlagIncludeLatestVersionOnly
+
+// VersionFlags return every version of each extension with its properties instead of only the
+// latest one, for picking the version to index.
+const VersionFlags = DefaultFlags&^FlagIncludeLatestVersionOnly |
+	FlagIncludeVersions |
+	FlagIncludeVersionProperties
+
+// ExtensionFlags are the flags set on published extensions, used to exclude extensions
+// with FilterTypeExcludeWithFlags.
+type ExtensionFlags int
+
+const (
+	ExtensionFlagDisabled     ExtensionFlags = 0x1
+	ExtensionFlagBuiltIn      ExtensionFlags = 0x2
+	ExtensionFlagValidated    ExtensionFlags = 0x4
+	ExtensionFlagTrusted      ExtensionFlags = 0x8
+	ExtensionFlagPaid         ExtensionFlags = 0x10
+	ExtensionFlagPreview      ExtensionFlags = 0x20
+	ExtensionFlagPublic       ExtensionFlags = 0x100
+	ExtensionFlagMultiVersion ExtensionFlags = 0x200
+	ExtensionFlagSystem       ExtensionFlags = 0x400
+	ExtensionFlagServiceFlags ExtensionFlags = 0x800
+	ExtensionFlagUnpublished  ExtensionFlags = 0x1000
+	ExtensionFlagTrial        ExtensionFlags = 0x2000
+)
+
+// excludeUndocumentedFlag isn't a flag the marketplace documents or returns, it's only kept in
+// DefaultExcludeFlags so scans send the same exclude value they always have.
+const excludeUndocumentedFlag ExtensionFlags = 0x8000
+
+// DefaultExcludeFlags exclude the extensions that can't be installed from VS Code.
+const DefaultExcludeFlags = ExtensionFlagSystem | ExtensionFlagUnpublished | excludeUndocumentedFlag
+
 func WithPageNumber(pageNumber int) QueryOption {
 	return func(o *QueryOptions) {
 		o.PageNumber = pageNumber
@@ -73,6 +142,47 @@ func WithCriteria(filterType QueryOptionsFilterType, value string) QueryOption {
 	}
 }
 
+func WithFlags(flags QueryFlags) QueryOption {
+	return func(o *QueryOptions) {
+		o.Flags = flags
+	}
+}
+
+// WithSlug filters by the fully qualified extension name, "publisher.extension".
 func WithSlug(slug string) QueryOption {
 	return WithCriteria(FilterTypeSlug, slug)
 }
+
+func WithExtensionID(extensionID string) QueryOption {
+	return WithCriteria(FilterTypeExtensionID, extensionID)
+}
+
+func WithPublisherName(publisherName string) QueryOption {
+	return WithCriteria(FilterTypePublisherName, publisherName)
+}
+
+func WithCategory(category string) QueryOption {
+	return WithCriteria(FilterTypeCategory, category)
+}
+
+func WithTag(tag string) QueryOption {
+	return WithCriteria(FilterTypeTag, tag)
+}
+
+// WithTarget filters by installation target, e.g. "Microsoft.VisualStudio.Code".
+func WithTarget(target string) QueryOption {
+	return WithCriteria(FilterTypeTarget, target)
+}
+
+// WithSearchText filters by free text, matched against names, descriptions and tags.
+func WithSearchText(text string) QueryOption {
+	return WithCriteria(FilterTypeSearchText, text)
+}
+
+func WithFeatured() QueryOption {
+	return WithCriteria(FilterTypeFeatured, "")
+}
+
+func WithExcludeFlags(flags ExtensionFlags) QueryOption {
+	return WithCriteria(FilterTypeExcludeWithFlags, strconv.Itoa(int(flags)))
+}
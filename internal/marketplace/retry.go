@@ -0,0 +1,97 @@
+package marketplace
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limiter blocks until a request is allowed to be sent.
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Pauser is implemented by limiters that can hold back requests from other clients too, such as
+// a limiter shared between processes. It's called when the marketplace throttles a request.
+type Pauser interface {
+	Pause(ctx context.Context, d time.Duration) error
+}
+
+// pause is shared by every request sent by a client. When the marketplace throttles a
+// request, all requests are held back until the pause is over instead of each of them
+// running into the limit on their own.
+type pause struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+func (p *pause) extend(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(p.until) {
+		p.until = until
+	}
+}
+
+func (p *pause) wait(ctx context.Context) error {
+	p.mu.Lock()
+	d := time.Until(p.until)
+	p.mu.Unlock()
+
+	return sleep(ctx, d)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns a delay with full jitter for the given attempt, starting at 0. Retries aren't
+// delayed if the backoff is 0.
+func backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base << attempt
+	if d <= 0 || d > max {
+		d = max
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+// parseRetryAfter supports both the delay-seconds and HTTP-date forms of the header.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+
+	return 0
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
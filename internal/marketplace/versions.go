@@ -0,0 +1,126 @@
+package marketplace
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+const (
+	// PropertyPreRelease is set to "true" on versions published with `vsce publish --pre-release`.
+	PropertyPreRelease = "Microsoft.VisualStudio.Code.PreRelease"
+
+	// VersionFlagPreRelease marks pre-release versions in the version flags. The property is the
+	// documented signal, the flag is checked as well since older versions only set one of them.
+	VersionFlagPreRelease = "prerelease"
+
+	TargetPlatformUniversal = "universal"
+)
+
+// Platforms to fall back to, in order, when a version has no universal build. Themes don't
+// depend on the platform so any build will do, but the choice should be stable between syncs.
+var fallbackTargetPlatforms = []string{
+	"web",
+	"linux-x64",
+	"darwin-arm64",
+	"darwin-x64",
+	"win32-x64",
+}
+
+// ParseVersionFlags splits the comma separated version flags, e.g. "validated, prerelease".
+func ParseVersionFlags(flags string) []string {
+	parsed := []string{}
+	for _, flag := range strings.Split(flags, ",") {
+		flag = strings.ToLower(strings.TrimSpace(flag))
+		if flag != "" {
+			parsed = append(parsed, flag)
+		}
+	}
+	return parsed
+}
+
+// GetProperty returns the value of the version property, or an empty string if it's not set.
+func (v ExtensionVersionResult) GetProperty(key string) string {
+	for _, property := range v.Properties {
+		if property.Key == key {
+			return property.Value
+		}
+	}
+	return ""
+}
+
+func (v ExtensionVersionResult) IsPreRelease() bool {
+	if strings.EqualFold(v.GetProperty(PropertyPreRelease), "true") {
+		return true
+	}
+	return slices.Contains(ParseVersionFlags(v.Flags), VersionFlagPreRelease)
+}
+
+// IsUniversal returns true if the version runs on every platform. Versions without a target
+// platform were published before platform-specific builds existed.
+func (v ExtensionVersionResult) IsUniversal() bool {
+	return v.TargetPlatform == "" || v.TargetPlatform == TargetPlatformUniversal
+}
+
+func (v ExtensionVersionResult) GetPackageURL() string {
+	for _, file := range v.Files {
+		if file.AssetType == AssetTypeVSIXPackage {
+			return file.Source
+		}
+	}
+	return ""
+}
+
+// GetSignatureURL returns the URL of the version's signature archive, empty if it's unsigned.
+func (v ExtensionVersionResult) GetSignatureURL() string {
+	for _, file := range v.Files {
+		if file.AssetType == AssetTypeVSIXSignature {
+			return file.Source
+		}
+	}
+	return ""
+}
+
+// platformRank orders builds of the same version, lower is preferred.
+func (v ExtensionVersionResult) platformRank() int {
+	if v.IsUniversal() {
+		return 0
+	}
+	if i := slices.Index(fallbackTargetPlatforms, v.TargetPlatform); i >= 0 {
+		return i + 1
+	}
+	return len(fallbackTargetPlatforms) + 1
+}
+
+// SelectVersion picks the version to index. The most recently updated version of the requested
+// release channel is chosen, preferring its universal build over platform-specific ones.
+// Nil is returned if the extension has no version in that channel, pre-releases are never
+// returned in place of a missing stable version.
+func (e ExtensionResult) SelectVersion(preRelease bool) *ExtensionVersionResult {
+	candidates := []ExtensionVersionResult{}
+	for _, version := range e.Versions {
+		if version.IsPreRelease() == preRelease {
+			candidates = append(candidates, version)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Find the latest version, ties keep the order of the marketplace, which lists versions
+	// newest first.
+	latest := slices.MaxFunc(candidates, func(a, b ExtensionVersionResult) int {
+		return a.LastUpdated.Compare(b.LastUpdated)
+	})
+
+	// Pick the preferred build of that version.
+	builds := slices.DeleteFunc(slices.Clone(candidates), func(version ExtensionVersionResult) bool {
+		return version.Version != latest.Version
+	})
+	selected := slices.MinFunc(builds, func(a, b ExtensionVersionResult) int {
+		return cmp.Compare(a.platformRank(), b.platformRank())
+	})
+
+	return &selected
+}
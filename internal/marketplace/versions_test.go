@@ -0,0 +1,105 @@
+package marketplace_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vscodethemes/backend/internal/marketplace"
+)
+
+func version(v string, daysAgo int, targetPlatform string, preRelease bool) marketplace.ExtensionVersionResult {
+	result := marketplace.ExtensionVersionResult{
+		Version:        v,
+		LastUpdated:    time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC).AddDate(0, 0, -daysAgo),
+		TargetPlatform: targetPlatform,
+	}
+	if preRelease {
+		result.Properties = []marketplace.ExtensionVersionPropertyResult{{Key: marketplace.PropertyPreRelease, Value: "true"}}
+	}
+	return result
+}
+
+func TestSelectVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		versions   []marketplace.ExtensionVersionResult
+		preRelease bool
+		want       string
+		platform   string
+	}{
+		{
+			name:     "latest stable",
+			versions: []marketplace.ExtensionVersionResult{version("1.0.0", 10, "", false), version("1.1.0", 5, "", false)},
+			want:     "1.1.0",
+		},
+		{
+			name:     "stable skips newer pre-release",
+			versions: []marketplace.ExtensionVersionResult{version("1.1.0", 1, "", true), version("1.0.0", 5, "", false)},
+			want:     "1.0.0",
+		},
+		{
+			name:       "latest pre-release",
+			versions:   []marketplace.ExtensionVersionResult{version("1.1.0", 1, "", true), version("1.2.0", 5, "", false)},
+			preRelease: true,
+			want:       "1.1.0",
+		},
+		{
+			name:     "pre-release flag",
+			versions: []marketplace.ExtensionVersionResult{{Version: "2.0.0", Flags: "validated, PreRelease"}, version("1.0.0", 5, "", false)},
+			want:     "1.0.0",
+		},
+		{
+			name:     "no stable version",
+			versions: []marketplace.ExtensionVersionResult{version("1.1.0", 1, "", true)},
+		},
+		{
+			name:       "no pre-release version",
+			versions:   []marketplace.ExtensionVersionResult{version("1.0.0", 1, "", false)},
+			preRelease: true,
+		},
+		{
+			name: "universal build preferred",
+			versions: []marketplace.ExtensionVersionResult{
+				version("1.0.0", 1, "win32-x64", false),
+				version("1.0.0", 1, "universal", false),
+				version("1.0.0", 1, "linux-x64", false),
+			},
+			want:     "1.0.0",
+			platform: "universal",
+		},
+		{
+			name: "fallback platform order",
+			versions: []marketplace.ExtensionVersionResult{
+				version("1.0.0", 1, "win32-x64", false),
+				version("1.0.0", 1, "alpine-arm64", false),
+				version("1.0.0", 1, "linux-x64", false),
+				version("0.9.0", 5, "", false),
+			},
+			want:     "1.0.0",
+			platform: "linux-x64",
+		},
+		{
+			name:     "ties keep the marketplace order",
+			versions: []marketplace.ExtensionVersionResult{version("1.1.0", 1, "", false), version("1.0.0", 1, "", false)},
+			want:     "1.1.0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selected := marketplace.ExtensionResult{Versions: test.versions}.SelectVersion(test.preRelease)
+			if test.want == "" {
+				if selected != nil {
+					t.Fatalf("expected no version, got %s", selected.Version)
+				}
+				return
+			}
+			if selected == nil {
+				t.Fatalf("expected %s, got no version", test.want)
+			}
+			if selected.Version != test.want || selected.TargetPlatform != test.platform {
+				t.Errorf("expected %s (%q), got %s (%q)", test.want, test.platform, selected.Version, selected.TargetPlatform)
+			}
+		})
+	}
+}
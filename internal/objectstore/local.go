@@ -0,0 +1,163 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// LocalStore stores objects in a directory on the local filesystem, for development without an
+// S3 compatible service. The API serves the directory under /static.
+type LocalStore struct {
+	Dir string
+	// BaseURL is the URL the directory is served from.
+	BaseURL string
+}
+
+func NewLocalStore(dir, baseURL string) *LocalStore {
+	return &LocalStore{
+		Dir:     dir,
+		BaseURL: baseURL,
+	}
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+
+	// Directories are the prefixes of other objects, not objects.
+	if info, err := file.Stat(); err != nil || !info.Mode().IsRegular() {
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat object: %w", err)
+		}
+		return nil, ErrNotFound
+	}
+
+	return file, nil
+}
+
+// Put writes the object to its file. The options are ignored, the content type is inferred from
+// the file extension when the file is served.
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	objectPath := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(objectPath), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+
+	// Write to a temporary file first so that a partially written object is never served.
+	file, err := os.CreateTemp(filepath.Dir(objectPath), ".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create object file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+
+	// Temporary files are only readable by their owner.
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+
+	if err := os.Rename(file.Name(), objectPath); err != nil {
+		return fmt.Errorf("failed to move object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LocalStore) Exists(ctx context.Context, key string) (bool, error) {
+	if err := validateKey(key); err != nil {
+		return false, err
+	}
+
+	info, err := os.Stat(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat object: %w", err)
+	}
+
+	return info.Mode().IsRegular(), nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	err := os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LocalStore) List(ctx context.Context, prefix string) ([]string, error) {
+	// Only walk the directory the prefix is in.
+	dir := "."
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i]
+		if err := validateKey(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := []string{}
+	err := fs.WalkDir(os.DirFS(s.Dir), dir, func(key string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fs.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		// Skip temporary files.
+		if strings.HasPrefix(entry.Name(), ".") && key != "." {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func (s *LocalStore) URL(key string) string {
+	return joinURL(s.BaseURL, key)
+}
@@ -0,0 +1,173 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalStore(t.TempDir(), "http://localhost:8080/static/")
+
+	for _, key := range []string{"a/one.svg", "a/two.svg", "a/b/three.png", "c/four.webp"} {
+		if err := store.Put(ctx, key, strings.NewReader(key), PutOptions{ContentType: "image/svg+xml"}); err != nil {
+			t.Fatalf("failed to put %s: %v", key, err)
+		}
+	}
+
+	// Objects are replaced.
+	if err := store.Put(ctx, "a/one.svg", strings.NewReader("replaced"), PutOptions{}); err != nil {
+		t.Fatalf("failed to replace object: %v", err)
+	}
+	if got := readObject(t, store, "a/one.svg"); got != "replaced" {
+		t.Errorf("expected replaced content, got %q", got)
+	}
+
+	info, err := os.Stat(filepath.Join(store.Dir, "a", "one.svg"))
+	if err != nil {
+		t.Fatalf("failed to stat object: %v", err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Errorf("expected object to be world readable, got %v", info.Mode().Perm())
+	}
+
+	for key, want := range map[string]bool{"a/two.svg": true, "a/missing.svg": false, "a": false, "a/b": false} {
+		exists, err := store.Exists(ctx, key)
+		if err != nil {
+			t.Fatalf("failed to check %s: %v", key, err)
+		}
+		if exists != want {
+			t.Errorf("expected %s to exist: %t, got %t", key, want, exists)
+		}
+	}
+
+	if _, err := store.Get(ctx, "a/missing.svg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing object, got %v", err)
+	}
+	if _, err := store.Get(ctx, "a/b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a directory, got %v", err)
+	}
+
+	for prefix, want := range map[string][]string{
+		"":          {"a/b/three.png", "a/one.svg", "a/two.svg", "c/four.webp"},
+		"a/":        {"a/b/three.png", "a/one.svg", "a/two.svg"},
+		"a/t":       {"a/two.svg"},
+		"a/b/":      {"a/b/three.png"},
+		"c":         {"c/four.webp"},
+		"missing/":  {},
+		"a/missing": {},
+	} {
+		keys, err := store.List(ctx, prefix)
+		if err != nil {
+			t.Fatalf("failed to list %q: %v", prefix, err)
+		}
+		if !slices.Equal(keys, want) {
+			t.Errorf("expected %q to list %v, got %v", prefix, want, keys)
+		}
+	}
+
+	if err := store.Delete(ctx, "a/two.svg"); err != nil {
+		t.Fatalf("failed to delete object: %v", err)
+	}
+	if err := store.Delete(ctx, "a/two.svg"); err != nil {
+		t.Fatalf("expected deleting a missing object to succeed, got %v", err)
+	}
+	if exists, _ := store.Exists(ctx, "a/two.svg"); exists {
+		t.Errorf("expected deleted object not to exist")
+	}
+
+	if got := store.URL("a/one.svg"); got != "http://localhost:8080/static/a/one.svg" {
+		t.Errorf("unexpected URL: %s", got)
+	}
+}
+
+func TestLocalStoreSkipsTemporaryFiles(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalStore(t.TempDir(), "")
+
+	if err := store.Put(ctx, "a/one.svg", strings.NewReader("one"), PutOptions{}); err != nil {
+		t.Fatalf("failed to put object: %v", err)
+	}
+
+	// Files of puts that are still in progress, or were interrupted.
+	if err := os.WriteFile(filepath.Join(store.Dir, "a", ".123.tmp"), []byte("partial"), 0o600); err != nil {
+		t.Fatalf("failed to write temporary file: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(store.Dir, ".hidden"), os.ModePerm); err != nil {
+		t.Fatalf("failed to create hidden directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(store.Dir, ".hidden", "two.svg"), []byte("two"), 0o644); err != nil {
+		t.Fatalf("failed to write hidden file: %v", err)
+	}
+
+	keys, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("failed to list objects: %v", err)
+	}
+	if !slices.Equal(keys, []string{"a/one.svg"}) {
+		t.Errorf("expected only a/one.svg, got %v", keys)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(store.Dir, "a"))
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected puts to clean up their temporary files, found %d entries", len(entries))
+	}
+}
+
+func TestLocalStoreInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalStore(t.TempDir(), "")
+
+	for _, key := range []string{"", ".", "..", "../escape.svg", "a/../b.svg", "/absolute.svg", "a//b.svg", "a/", "a/.hidden.svg", ".tmp/a.svg", `a\b.svg`} {
+		if err := store.Put(ctx, key, strings.NewReader("data"), PutOptions{}); err == nil {
+			t.Errorf("expected put of %q to fail", key)
+		}
+		if _, err := store.Exists(ctx, key); err == nil {
+			t.Errorf("expected exists of %q to fail", key)
+		}
+		if _, err := store.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("expected get of %q to fail validation, got %v", key, err)
+		}
+		if err := store.Delete(ctx, key); err == nil {
+			t.Errorf("expected delete of %q to fail", key)
+		}
+	}
+
+	if _, err := store.List(ctx, "../"); err == nil {
+		t.Errorf("expected listing outside the store to fail")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(store.Dir))
+	if err != nil {
+		t.Fatalf("failed to read parent directory: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == "escape.svg" {
+			t.Errorf("expected nothing to be written outside the store")
+		}
+	}
+}
+
+func readObject(t *testing.T, store *LocalStore, key string) string {
+	t.Helper()
+	body, err := store.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("failed to get %s: %v", key, err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", key, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,55 @@
+// Package objectstore stores the files served to clients, like preview images, and gives them
+// public URLs. Keys are slash separated paths.
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// PutOptions are the headers objects are served with. Stores that serve files themselves, like
+// the local store, may ignore them.
+type PutOptions struct {
+	ContentType  string
+	CacheControl string
+}
+
+var ErrNotFound = errors.New("object not found")
+
+type Store interface {
+	// Get opens the object, or returns ErrNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Put stores the object read from r under the key, replacing any object with the same key.
+	Put(ctx context.Context, key string, r io.Reader, opts PutOptions) error
+	// Exists returns true if there's an object with the key.
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete deletes the object. Deleting an object that doesn't exist isn't an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the keys of the objects starting with the prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// URL returns the public URL of the object.
+	URL(key string) string
+}
+
+// validateKey checks that the key can be used as a path. Segments starting with a dot are
+// reserved for temporary files.
+func validateKey(key string) error {
+	if !fs.ValidPath(key) || key == "." {
+		return fmt.Errorf("invalid object key: %q", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if strings.HasPrefix(segment, ".") || strings.Contains(segment, `\`) {
+			return fmt.Errorf("invalid object key: %q", key)
+		}
+	}
+	return nil
+}
+
+// joinURL returns the URL of the key under the base URL.
+func joinURL(baseURL, key string) string {
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(baseURL, "/"), key)
+}
@@ -0,0 +1,210 @@
+package openvsx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var ErrNotFound = errors.New("extension not found")
+
+type Client struct {
+	BaseUrl    string
+	httpClient *http.Client
+	limiter    *rate.Limiter
+}
+
+type ClientOption func(*Client)
+
+func WithBaseUrl(baseUrl string) ClientOption {
+	return func(c *Client) {
+		c.BaseUrl = baseUrl
+	}
+}
+
+func WithRateLimit(limit rate.Limit, burst int) ClientOption {
+	return func(c *Client) {
+		c.limiter = rate.NewLimiter(limit, burst)
+	}
+}
+
+func NewClient(opts ...ClientOption) *Client {
+	// Default client options
+	client := &Client{
+		BaseUrl:    "https://open-vsx.org/api",
+		httpClient: &http.Client{},
+		limiter:    rate.NewLimiter(rate.Every(500*time.Millisecond), 1),
+	}
+
+	// Apply option overrides.
+	for _, applyOpt := range opts {
+		applyOpt(client)
+	}
+
+	return client
+}
+
+type SortBy string
+
+const (
+	SortByRelevance     SortBy = "relevance"
+	SortByTimestamp     SortBy = "timestamp"
+	SortByDownloadCount SortBy = "downloadCount"
+)
+
+type SortOrder string
+
+const (
+	SortOrderAsc  SortOrder = "asc"
+	SortOrderDesc SortOrder = "desc"
+)
+
+type SearchOptions struct {
+	Category  string
+	Offset    int
+	Size      int
+	SortBy    SortBy
+	SortOrder SortOrder
+}
+
+type SearchResponse struct {
+	Offset     int               `json:"offset"`
+	TotalSize  int               `json:"totalSize"`
+	Extensions []ExtensionResult `json:"extensions"`
+	Error      string            `json:"error"`
+}
+
+// ExtensionResult is returned by both the search and extension endpoints. Search
+// results only populate a subset of the fields.
+type ExtensionResult struct {
+	Namespace            string            `json:"namespace"`
+	NamespaceDisplayName string            `json:"namespaceDisplayName"`
+	Name                 string            `json:"name"`
+	DisplayName          string            `json:"displayName"`
+	Description          *string           `json:"description"`
+	Version              string            `json:"version"`
+	PreRelease           bool              `json:"preRelease"`
+	TargetPlatform       string            `json:"targetPlatform"`
+	Timestamp            string            `json:"timestamp"`
+	Files                map[string]string `json:"files"`
+	DownloadCount        int               `json:"downloadCount"`
+	AverageRating        *float64          `json:"averageRating"`
+	ReviewCount          int               `json:"reviewCount"`
+	Categories           []string          `json:"categories"`
+	Tags                 []string          `json:"tags"`
+	Error                string            `json:"error"`
+}
+
+func (e ExtensionResult) GetPackageURL() string {
+	return e.Files["download"]
+}
+
+func (c Client) Search(ctx context.Context, opts SearchOptions) ([]ExtensionResult, error) {
+	query := url.Values{}
+	if opts.Category != "" {
+		query.Set("category", opts.Category)
+	}
+	if opts.SortBy != "" {
+		query.Set("sortBy", string(opts.SortBy))
+	}
+	if opts.SortOrder != "" {
+		query.Set("sortOrder", string(opts.SortOrder))
+	}
+	query.Set("offset", strconv.Itoa(opts.Offset))
+	query.Set("size", strconv.Itoa(opts.Size))
+
+	var searchRes SearchResponse
+	if err := c.get(ctx, "/-/search?"+query.Encode(), &searchRes); err != nil {
+		return nil, err
+	}
+
+	if searchRes.Error != "" {
+		return nil, fmt.Errorf("search failed: %s", searchRes.Error)
+	}
+
+	return searchRes.Extensions, nil
+}
+
+func (c Client) GetExtension(ctx context.Context, namespace, name string) (*ExtensionResult, error) {
+	path := fmt.Sprintf("/%s/%s", url.PathEscape(namespace), url.PathEscape(name))
+
+	var extension ExtensionResult
+	if err := c.get(ctx, path, &extension); err != nil {
+		return nil, err
+	}
+
+	if extension.Error != "" {
+		return nil, fmt.Errorf("failed to get extension: %s", extension.Error)
+	}
+
+	return &extension, nil
+}
+
+// GetLatestStableVersion returns the latest version of the extension that isn't a pre-release.
+// ErrNotFound is returned if it only has pre-releases.
+func (c Client) GetLatestStableVersion(ctx context.Context, namespace, name string) (*ExtensionResult, error) {
+	path := fmt.Sprintf("/%s/%s/latest?includePreRelease=false", url.PathEscape(namespace), url.PathEscape(name))
+
+	var extension ExtensionResult
+	if err := c.get(ctx, path, &extension); err != nil {
+		return nil, err
+	}
+
+	if extension.Error != "" {
+		return nil, fmt.Errorf("failed to get extension: %s", extension.Error)
+	}
+
+	if extension.PreRelease {
+		return nil, ErrNotFound
+	}
+
+	return &extension, nil
+}
+
+func (c Client) get(ctx context.Context, path string, v any) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	// Build the request.
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseUrl+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	// Send the request.
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	// Read the response body.
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if err := json.Unmarshal(resBody, v); err != nil {
+		return fmt.Errorf("failed to unmarshal response body: %w", err)
+	}
+
+	return nil
+}
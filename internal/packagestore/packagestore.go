@@ -0,0 +1,156 @@
+// Package packagestore keeps downloaded extension packages, so that syncing a version again
+// doesn't download it again. Packages are content addressed by extension ID, version and SHA-256
+// and kept in an object store under a prefix.
+package packagestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/vscodethemes/backend/internal/objectstore"
+)
+
+var ErrNotFound = errors.New("package not found")
+
+// Key identifies a stored package.
+type Key struct {
+	ExtensionID string
+	Version     string
+	SHA256      string
+}
+
+// Path returns the path of the package relative to the root of the store.
+func (k Key) Path() string {
+	return path.Join(k.ExtensionID, k.Version, k.SHA256+packageExt)
+}
+
+// Validate checks that the key can be used as a path. Versions come from the registries and
+// can't be trusted to be a single path segment.
+func (k Key) Validate() error {
+	for _, segment := range []string{k.ExtensionID, k.Version, k.SHA256} {
+		if err := validateSegment(segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const packageExt = ".vsix"
+
+// Store keeps packages in an object store, either the one images are uploaded to or one of its
+// own.
+type Store struct {
+	Objects objectstore.Store
+	Prefix  string
+}
+
+func New(objects objectstore.Store, prefix string) *Store {
+	return &Store{
+		Objects: objects,
+		Prefix:  prefix,
+	}
+}
+
+// Find returns the key of the stored package of the extension version, or ErrNotFound.
+func (s *Store) Find(ctx context.Context, extensionID, version string) (Key, error) {
+	key := Key{ExtensionID: extensionID, Version: version, SHA256: "_"}
+	if err := key.Validate(); err != nil {
+		return Key{}, err
+	}
+
+	versionPrefix := s.objectKey(path.Join(extensionID, version)) + "/"
+	objectKeys, err := s.Objects.List(ctx, versionPrefix)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to list packages: %w", err)
+	}
+
+	for _, objectKey := range objectKeys {
+		name := strings.TrimPrefix(objectKey, versionPrefix)
+		if sha256, ok := parseSHA256(name); ok && !strings.Contains(name, "/") {
+			key.SHA256 = sha256
+			return key, nil
+		}
+	}
+
+	return Key{}, ErrNotFound
+}
+
+// Get opens the stored package, or returns ErrNotFound.
+func (s *Store) Get(ctx context.Context, key Key) (io.ReadCloser, error) {
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+
+	body, err := s.Objects.Get(ctx, s.objectKey(key.Path()))
+	if errors.Is(err, objectstore.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+
+	return body, nil
+}
+
+// Put stores the package read from r.
+func (s *Store) Put(ctx context.Context, key Key, r io.Reader) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+
+	err := s.Objects.Put(ctx, s.objectKey(key.Path()), r, objectstore.PutOptions{
+		ContentType: "application/vsix",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put package: %w", err)
+	}
+
+	return nil
+}
+
+// Prune deletes the extension's packages of every version except the ones given.
+func (s *Store) Prune(ctx context.Context, extensionID string, keepVersions []string) error {
+	if err := validateSegment(extensionID); err != nil {
+		return err
+	}
+
+	extensionPrefix := s.objectKey(extensionID) + "/"
+	objectKeys, err := s.Objects.List(ctx, extensionPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list packages: %w", err)
+	}
+
+	for _, objectKey := range objectKeys {
+		version, _, _ := strings.Cut(strings.TrimPrefix(objectKey, extensionPrefix), "/")
+		if slices.Contains(keepVersions, version) {
+			continue
+		}
+		if err := s.Objects.Delete(ctx, objectKey); err != nil {
+			return fmt.Errorf("failed to delete package: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) objectKey(name string) string {
+	return path.Join(s.Prefix, name)
+}
+
+func validateSegment(segment string) error {
+	if segment == "" || segment == "." || segment == ".." || strings.ContainsAny(segment, `/\`) {
+		return fmt.Errorf("invalid package key segment: %q", segment)
+	}
+	return nil
+}
+
+// parseSHA256 returns the checksum from a package file name.
+func parseSHA256(name string) (string, bool) {
+	sha256, ok := strings.CutSuffix(name, packageExt)
+	return sha256, ok && sha256 != ""
+}
@@ -0,0 +1,91 @@
+package packagestore_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vscodethemes/backend/internal/objectstore"
+	"github.com/vscodethemes/backend/internal/packagestore"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	objects := objectstore.NewLocalStore(t.TempDir(), "")
+	store := packagestore.New(objects, "packages")
+
+	if _, err := store.Find(ctx, "ext", "1.0.0"); !errors.Is(err, packagestore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound before the package is stored, got %v", err)
+	}
+
+	keys := []packagestore.Key{
+		{ExtensionID: "ext", Version: "1.0.0", SHA256: "aaa"},
+		{ExtensionID: "ext", Version: "1.1.0", SHA256: "bbb"},
+		{ExtensionID: "other", Version: "1.0.0", SHA256: "ccc"},
+	}
+	for _, key := range keys {
+		if err := store.Put(ctx, key, strings.NewReader(key.SHA256)); err != nil {
+			t.Fatalf("failed to put %s: %v", key.Path(), err)
+		}
+	}
+
+	// Packages are kept under the prefix.
+	if exists, _ := objects.Exists(ctx, "packages/ext/1.0.0/aaa.vsix"); !exists {
+		t.Errorf("expected package to be stored under the prefix")
+	}
+
+	key, err := store.Find(ctx, "ext", "1.0.0")
+	if err != nil {
+		t.Fatalf("failed to find package: %v", err)
+	}
+	if key != keys[0] {
+		t.Errorf("expected %v, got %v", keys[0], key)
+	}
+
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to get package: %v", err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(data) != "aaa" {
+		t.Errorf("unexpected package content %q: %v", data, err)
+	}
+
+	if err := store.Prune(ctx, "ext", []string{"1.1.0"}); err != nil {
+		t.Fatalf("failed to prune packages: %v", err)
+	}
+	if _, err := store.Find(ctx, "ext", "1.0.0"); !errors.Is(err, packagestore.ErrNotFound) {
+		t.Errorf("expected pruned version to be gone, got %v", err)
+	}
+	if _, err := store.Get(ctx, keys[0]); !errors.Is(err, packagestore.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a pruned package, got %v", err)
+	}
+	for _, key := range keys[1:] {
+		if _, err := store.Find(ctx, key.ExtensionID, key.Version); err != nil {
+			t.Errorf("expected %s to be kept, got %v", key.Path(), err)
+		}
+	}
+}
+
+func TestStoreInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	store := packagestore.New(objectstore.NewLocalStore(t.TempDir(), ""), "packages")
+
+	for _, key := range []packagestore.Key{
+		{ExtensionID: "ext", Version: "../1.0.0", SHA256: "aaa"},
+		{ExtensionID: "ext", Version: "..", SHA256: "aaa"},
+		{ExtensionID: "", Version: "1.0.0", SHA256: "aaa"},
+		{ExtensionID: "ext", Version: "1.0.0", SHA256: `a\b`},
+	} {
+		if err := store.Put(ctx, key, strings.NewReader("data")); err == nil {
+			t.Errorf("expected put of %+v to fail", key)
+		}
+	}
+
+	if err := store.Prune(ctx, "../ext", nil); err == nil {
+		t.Errorf("expected prune of an invalid extension ID to fail")
+	}
+}
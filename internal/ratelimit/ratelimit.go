@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vscodethemes/backend/internal/db"
+)
+
+// PostgresLimiter is a token bucket stored in Postgres, so that every process using the same
+// bucket name shares one budget. Tokens are reserved up front: when the bucket is empty the
+// reservation puts it into debt and the caller waits until its token has been refilled.
+type PostgresLimiter struct {
+	Name   string
+	Rate   float64 // Tokens refilled per second.
+	Burst  float64 // Maximum number of tokens in the bucket.
+	DBPool *pgxpool.Pool
+}
+
+// NewPostgresLimiter returns a limiter for the bucket, the rate must be positive and the burst at
+// least 1.
+func NewPostgresLimiter(dbPool *pgxpool.Pool, name string, rate float64, burst int) (*PostgresLimiter, error) {
+	if !(rate > 0) || math.IsInf(rate, 1) {
+		return nil, fmt.Errorf("invalid rate limit: %v", rate)
+	}
+	if burst < 1 {
+		return nil, fmt.Errorf("invalid rate limit burst: %d", burst)
+	}
+
+	return &PostgresLimiter{
+		Name:   name,
+		Rate:   rate,
+		Burst:  float64(burst),
+		DBPool: dbPool,
+	}, nil
+}
+
+// Wait reserves a token and blocks until it's available or the context is done.
+func (l *PostgresLimiter) Wait(ctx context.Context) error {
+	queries := db.New(l.DBPool)
+
+	tokens, err := queries.ReserveRateLimitToken(ctx, db.ReserveRateLimitTokenParams{
+		Name:  l.Name,
+		Burst: l.Burst,
+		Rate:  l.Rate,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reserve rate limit token: %w", err)
+	}
+
+	if tokens >= 0 {
+		return nil
+	}
+
+	delay := time.Duration(-tokens / l.Rate * float64(time.Second))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Pause empties the bucket so that no process gets a token for at least the given duration.
+func (l *PostgresLimiter) Pause(ctx context.Context, d time.Duration) error {
+	queries := db.New(l.DBPool)
+
+	err := queries.DrainRateLimitTokens(ctx, db.DrainRateLimitTokensParams{
+		Name:         l.Name,
+		Rate:         l.Rate,
+		PauseSeconds: d.Seconds(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to drain rate limit tokens: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package ratelimit
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewPostgresLimiter(t *testing.T) {
+	tests := []struct {
+		rate  float64
+		burst int
+		valid bool
+	}{
+		{rate: 1, burst: 1, valid: true},
+		{rate: 0.5, burst: 10, valid: true},
+		{rate: 0, burst: 1},
+		{rate: -1, burst: 1},
+		{rate: math.NaN(), burst: 1},
+		{rate: math.Inf(1), burst: 1},
+		{rate: 1, burst: 0},
+	}
+
+	for _, test := range tests {
+		_, err := NewPostgresLimiter(nil, "test", test.rate, test.burst)
+		if test.valid && err != nil {
+			t.Errorf("expected rate %v and burst %d to be valid, got %v", test.rate, test.burst, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected rate %v and burst %d to be rejected", test.rate, test.burst)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/vscodethemes/backend/internal/marketplace"
+	"github.com/vscodethemes/backend/internal/marketplace/qo"
+)
+
+type marketplaceRegistry struct {
+	client *marketplace.Client
+}
+
+func NewMarketplace(client *marketplace.Client) Registry {
+	return &marketplaceRegistry{client: client}
+}
+
+func (r *marketplaceRegistry) Name() string {
+	return Marketplace
+}
+
+func (r *marketplaceRegistry) ScanThemes(ctx context.Context, query ThemesQuery) iter.Seq2[marketplace.ExtensionResult, error] {
+	return r.client.Query(ctx,
+		qo.WithSortBy(query.SortBy),
+		qo.WithDirection(query.Direction),
+		qo.WithCategory("Themes"),
+		qo.WithTarget("Microsoft.VisualStudio.Code"),
+		qo.WithSearchText("target:\"Microsoft.VisualStudio.Code\" "),
+		qo.WithExcludeFlags(qo.DefaultExcludeFlags),
+		qo.WithPageSize(query.PageSize),
+	)
+}
+
+func (r *marketplaceRegistry) GetExtension(ctx context.Context, publisherName, extensionName string) (*marketplace.ExtensionResult, error) {
+	extensionSlug := fmt.Sprintf("%s.%s", publisherName, extensionName)
+
+	// Include every version so that workers can pick which one to index.
+	queryResults, err := r.client.NewQuery(ctx, qo.WithSlug(extensionSlug), qo.WithFlags(qo.VersionFlags))
+	if errors.Is(err, marketplace.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(queryResults) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &queryResults[0], nil
+}
+
+func (r *marketplaceRegistry) GetExtensions(ctx context.Context, slugs []string) ([]marketplace.ExtensionResult, error) {
+	return r.client.GetExtensionsBySlug(ctx, slugs)
+}
+
+func (r *marketplaceRegistry) GetPackageURL(version marketplace.ExtensionVersionResult) string {
+	return version.GetPackageURL()
+}
+
+func (r *marketplaceRegistry) GetSignatureURL(version marketplace.ExtensionVersionResult) string {
+	return version.GetSignatureURL()
+}
+
+func (r *marketplaceRegistry) GetStatistics(extension marketplace.ExtensionResult) Statistics {
+	return Statistics{
+		Installs:        findStatistic(extension.Stastistics, "install"),
+		TrendingDaily:   findStatistic(extension.Stastistics, "trendingdaily"),
+		TrendingWeekly:  findStatistic(extension.Stastistics, "trendingweekly"),
+		TrendingMonthly: findStatistic(extension.Stastistics, "trendingmonthly"),
+		WeightedRating:  findStatistic(extension.Stastistics, "weightedRating"),
+	}
+}
+
+func findStatistic(statistics []marketplace.ExtensionStatisticsResult, name string) float64 {
+	for _, statistic := range statistics {
+		if statistic.StatisticName == name {
+			return statistic.Value
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,217 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+	"time"
+
+	"github.com/vscodethemes/backend/internal/marketplace"
+	"github.com/vscodethemes/backend/internal/marketplace/qo"
+	"github.com/vscodethemes/backend/internal/openvsx"
+)
+
+type openVSXRegistry struct {
+	client *openvsx.Client
+}
+
+func NewOpenVSX(client *openvsx.Client) Registry {
+	return &openVSXRegistry{client: client}
+}
+
+func (r *openVSXRegistry) Name() string {
+	return OpenVSX
+}
+
+func (r *openVSXRegistry) ScanThemes(ctx context.Context, query ThemesQuery) iter.Seq2[marketplace.ExtensionResult, error] {
+	sortBy := openvsx.SortByTimestamp
+	if query.SortBy == qo.SortByInstalls {
+		sortBy = openvsx.SortByDownloadCount
+	}
+
+	sortOrder := openvsx.SortOrderDesc
+	if query.Direction == qo.DirectionAsc {
+		sortOrder = openvsx.SortOrderAsc
+	}
+
+	return marketplace.Paginate(ctx, func(ctx context.Context, pageNumber int) ([]marketplace.ExtensionResult, error) {
+		// Page numbers start at 1.
+		searchResults, err := r.client.Search(ctx, openvsx.SearchOptions{
+			Category:  "Themes",
+			Offset:    (pageNumber - 1) * query.PageSize,
+			Size:      query.PageSize,
+			SortBy:    sortBy,
+			SortOrder: sortOrder,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		results := make([]marketplace.ExtensionResult, 0, len(searchResults))
+		for _, searchResult := range searchResults {
+			result, err := convertOpenVSXExtension(searchResult)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+
+		return results, nil
+	})
+}
+
+func (r *openVSXRegistry) GetExtension(ctx context.Context, publisherName, extensionName string) (*marketplace.ExtensionResult, error) {
+	extension, err := r.client.GetExtension(ctx, publisherName, extensionName)
+	if errors.Is(err, openvsx.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := convertOpenVSXExtension(*extension)
+	if err != nil {
+		return nil, err
+	}
+
+	// The extension is returned with its latest version, which may be a pre-release. Add the
+	// latest stable version so that it can be selected too.
+	if extension.PreRelease {
+		stable, err := r.client.GetLatestStableVersion(ctx, publisherName, extensionName)
+		if err != nil && !errors.Is(err, openvsx.ErrNotFound) {
+			return nil, err
+		}
+
+		if stable != nil {
+			version, err := convertOpenVSXVersion(*stable)
+			if err != nil {
+				return nil, err
+			}
+			result.Versions = append(result.Versions, version)
+		}
+	}
+
+	return &result, nil
+}
+
+// GetExtensions fetches each extension on its own, Open VSX doesn't support batched lookups.
+func (r *openVSXRegistry) GetExtensions(ctx context.Context, slugs []string) ([]marketplace.ExtensionResult, error) {
+	results := []marketplace.ExtensionResult{}
+	for _, slug := range slugs {
+		publisherName, extensionName, ok := strings.Cut(slug, ".")
+		if !ok {
+			return nil, fmt.Errorf("invalid extension slug: %s", slug)
+		}
+
+		result, err := r.GetExtension(ctx, publisherName, extensionName)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, *result)
+	}
+
+	return results, nil
+}
+
+func (r *openVSXRegistry) GetPackageURL(version marketplace.ExtensionVersionResult) string {
+	return version.GetPackageURL()
+}
+
+// GetSignatureURL returns an empty URL, Open VSX signs packages with its own keys in a format
+// that isn't supported, so its packages are treated as unsigned.
+func (r *openVSXRegistry) GetSignatureURL(version marketplace.ExtensionVersionResult) string {
+	return ""
+}
+
+func (r *openVSXRegistry) GetStatistics(extension marketplace.ExtensionResult) Statistics {
+	// Open VSX does not track trending statistics.
+	return Statistics{
+		Installs:       findStatistic(extension.Stastistics, "install"),
+		WeightedRating: findStatistic(extension.Stastistics, "weightedRating"),
+	}
+}
+
+// convertOpenVSXExtension maps an Open VSX extension onto the marketplace result type. Open VSX
+// only exposes the latest version, so its timestamp is used for all the marketplace dates.
+func convertOpenVSXExtension(extension openvsx.ExtensionResult) (marketplace.ExtensionResult, error) {
+	version, err := convertOpenVSXVersion(extension)
+	if err != nil {
+		return marketplace.ExtensionResult{}, err
+	}
+
+	// Open VSX doesn't expose stable IDs, so the namespaced slug is used instead. It's prefixed
+	// with the registry name to never collide with a marketplace extension ID.
+	extensionID := fmt.Sprintf("%s:%s.%s", OpenVSX, extension.Namespace, extension.Name)
+
+	publisherDisplayName := extension.NamespaceDisplayName
+	if publisherDisplayName == "" {
+		publisherDisplayName = extension.Namespace
+	}
+
+	displayName := extension.DisplayName
+	if displayName == "" {
+		displayName = extension.Name
+	}
+
+	result := marketplace.ExtensionResult{
+		Publisher: marketplace.ExtensionPublisherResult{
+			PublisherID:   fmt.Sprintf("%s:%s", OpenVSX, extension.Namespace),
+			PublisherName: extension.Namespace,
+			DisplayName:   publisherDisplayName,
+		},
+		ExtensionID:      extensionID,
+		ExtensionName:    extension.Name,
+		DisplayName:      displayName,
+		LastUpdated:      extension.Timestamp,
+		PublishedDate:    extension.Timestamp,
+		ReleaseDate:      extension.Timestamp,
+		ShortDescription: extension.Description,
+		Categories:       extension.Categories,
+		Tags:             extension.Tags,
+		Stastistics: []marketplace.ExtensionStatisticsResult{
+			{StatisticName: "install", Value: float64(extension.DownloadCount)},
+		},
+		Versions: []marketplace.ExtensionVersionResult{version},
+	}
+
+	if extension.AverageRating != nil {
+		result.Stastistics = append(result.Stastistics, marketplace.ExtensionStatisticsResult{
+			StatisticName: "weightedRating",
+			Value:         *extension.AverageRating,
+		})
+	}
+
+	return result, nil
+}
+
+// convertOpenVSXVersion maps the version an Open VSX extension was returned with onto the
+// marketplace version type.
+func convertOpenVSXVersion(extension openvsx.ExtensionResult) (marketplace.ExtensionVersionResult, error) {
+	timestamp, err := time.Parse(time.RFC3339, extension.Timestamp)
+	if err != nil {
+		return marketplace.ExtensionVersionResult{}, fmt.Errorf("failed to parse timestamp: %w", err)
+	}
+
+	version := marketplace.ExtensionVersionResult{
+		Version:        extension.Version,
+		LastUpdated:    timestamp,
+		TargetPlatform: extension.TargetPlatform,
+		Files: []marketplace.ExtensionVersionFileResult{
+			{AssetType: marketplace.AssetTypeVSIXPackage, Source: extension.GetPackageURL()},
+		},
+	}
+
+	if extension.PreRelease {
+		version.Properties = []marketplace.ExtensionVersionPropertyResult{
+			{Key: marketplace.PropertyPreRelease, Value: "true"},
+		}
+	}
+
+	return version, nil
+}
@@ -0,0 +1,92 @@
+package registry_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vscodethemes/backend/internal/openvsx"
+	"github.com/vscodethemes/backend/internal/registry"
+	"golang.org/x/time/rate"
+)
+
+func TestOpenVSXGetExtension(t *testing.T) {
+	preRelease := openvsx.ExtensionResult{
+		Namespace:      "publisher",
+		Name:           "theme",
+		Version:        "2.0.0",
+		PreRelease:     true,
+		TargetPlatform: "linux-x64",
+		Timestamp:      "2024-02-01T00:00:00Z",
+		Files:          map[string]string{"download": "https://example.com/theme-2.0.0.vsix"},
+	}
+	stable := openvsx.ExtensionResult{
+		Namespace:      "publisher",
+		Name:           "theme",
+		Version:        "1.0.0",
+		TargetPlatform: "universal",
+		Timestamp:      "2024-01-01T00:00:00Z",
+		Files:          map[string]string{"download": "https://example.com/theme-1.0.0.vsix"},
+	}
+
+	tests := []struct {
+		name               string
+		latest             openvsx.ExtensionResult
+		latestStable       *openvsx.ExtensionResult
+		wantStable         string
+		wantPreRelease     string
+		wantStablePlatform string
+	}{
+		{name: "stable", latest: stable, wantStable: "1.0.0", wantStablePlatform: "universal"},
+		{name: "pre-release after stable", latest: preRelease, latestStable: &stable, wantStable: "1.0.0", wantPreRelease: "2.0.0", wantStablePlatform: "universal"},
+		{name: "only pre-releases", latest: preRelease, latestStable: &preRelease, wantPreRelease: "2.0.0"},
+		{name: "stable not found", latest: preRelease, wantPreRelease: "2.0.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /publisher/theme", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(test.latest)
+			})
+			mux.HandleFunc("GET /publisher/theme/latest", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("includePreRelease") != "false" {
+					t.Errorf("expected pre-releases to be excluded, got %s", r.URL.RawQuery)
+				}
+				if test.latestStable == nil {
+					http.NotFound(w, r)
+					return
+				}
+				json.NewEncoder(w).Encode(test.latestStable)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			reg := registry.NewOpenVSX(openvsx.NewClient(openvsx.WithBaseUrl(server.URL), openvsx.WithRateLimit(rate.Inf, 1)))
+			extension, err := reg.GetExtension(context.Background(), "publisher", "theme")
+			if err != nil {
+				t.Fatalf("failed to get extension: %v", err)
+			}
+
+			version := extension.SelectVersion(false)
+			switch {
+			case test.wantStable == "" && version != nil:
+				t.Errorf("expected no stable version, got %s", version.Version)
+			case test.wantStable != "" && (version == nil || version.Version != test.wantStable):
+				t.Errorf("expected stable version %s, got %+v", test.wantStable, version)
+			case version != nil && version.TargetPlatform != test.wantStablePlatform:
+				t.Errorf("expected platform %s, got %s", test.wantStablePlatform, version.TargetPlatform)
+			}
+
+			version = extension.SelectVersion(true)
+			switch {
+			case test.wantPreRelease == "" && version != nil:
+				t.Errorf("expected no pre-release version, got %s", version.Version)
+			case test.wantPreRelease != "" && (version == nil || version.Version != test.wantPreRelease):
+				t.Errorf("expected pre-release version %s, got %+v", test.wantPreRelease, version)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	"github.com/vscodethemes/backend/internal/marketplace"
+	"github.com/vscodethemes/backend/internal/marketplace/qo"
+)
+
+const (
+	Marketplace = "marketplace"
+	OpenVSX     = "open-vsx"
+)
+
+var ErrNotFound = errors.New("extension not found")
+
+// Registry is a source of extensions. Results are normalized to the marketplace
+// response types so that workers can handle extensions from every registry the same way.
+type Registry interface {
+	// Name is the identifier stored on each extension row.
+	Name() string
+	// ScanThemes yields every theme extension, fetching pages as needed.
+	ScanThemes(ctx context.Context, query ThemesQuery) iter.Seq2[marketplace.ExtensionResult, error]
+	// GetExtension returns a single extension, or ErrNotFound if it does not exist.
+	GetExtension(ctx context.Context, publisherName, extensionName string) (*marketplace.ExtensionResult, error)
+	// GetExtensions returns many extensions by their "publisher.extension" slug. Extensions that
+	// don't exist are left out of the results.
+	GetExtensions(ctx context.Context, slugs []string) ([]marketplace.ExtensionResult, error)
+	// GetPackageURL returns the download URL of the version's VSIX package.
+	GetPackageURL(version marketplace.ExtensionVersionResult) string
+	// GetSignatureURL returns the download URL of the version's signature archive, empty if the
+	// version isn't signed.
+	GetSignatureURL(version marketplace.ExtensionVersionResult) string
+	// GetStatistics returns the install and rating statistics for the extension.
+	GetStatistics(extension marketplace.ExtensionResult) Statistics
+}
+
+type ThemesQuery struct {
+	PageSize  int
+	SortBy    qo.QueryOptionSortBy
+	Direction qo.QueryOptionDirection
+}
+
+type Statistics struct {
+	Installs        float64
+	TrendingDaily   float64
+	TrendingWeekly  float64
+	TrendingMonthly float64
+	WeightedRating  float64
+}
+
+// Registries is a set of registries keyed by name.
+type Registries map[string]Registry
+
+func NewRegistries(registries ...Registry) Registries {
+	set := Registries{}
+	for _, r := range registries {
+		set[r.Name()] = r
+	}
+	return set
+}
+
+// Get returns the registry with the given name. An empty name resolves to the marketplace,
+// which was the only registry before extensions recorded where they came from.
+func (r Registries) Get(name string) (Registry, error) {
+	if name == "" {
+		name = Marketplace
+	}
+
+	registry, ok := r[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown registry: %s", name)
+	}
+
+	return registry, nil
+}
+
+// Ordered returns the registries in order of preference, the marketplace first.
+func (r Registries) Ordered() []Registry {
+	ordered := []Registry{}
+	for _, name := range []string{Marketplace, OpenVSX} {
+		if registry, ok := r[name]; ok {
+			ordered = append(ordered, registry)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,311 @@
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+)
+
+// Only the parts of CMS (RFC 5652) used by package signatures are supported: SignedData with
+// detached content, signed by a single signer with RSA or ECDSA keys.
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional,explicit,tag:0"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// signed is a parsed detached signature.
+type signed struct {
+	signer        *x509.Certificate
+	intermediates *x509.CertPool
+}
+
+// verifyDetached checks that p7s is a valid signature of content and returns the signer's
+// certificate. The certificate chain isn't verified.
+func verifyDetached(p7s, content []byte) (*signed, error) {
+	var info contentInfo
+	rest, err := asn1.Unmarshal(p7s, &info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signature: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after signature")
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("unexpected signature content type: %s", info.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("failed to parse signed data: %w", err)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("expected one signer, found %d", len(sd.SignerInfos))
+	}
+
+	// Signatures with attached content are accepted as long as it's the expected content.
+	if len(sd.EncapContentInfo.EContent.Bytes) > 0 {
+		var eContent []byte
+		if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &eContent); err != nil {
+			return nil, fmt.Errorf("failed to parse signed content: %w", err)
+		}
+		if !bytes.Equal(eContent, content) {
+			return nil, errors.New("signed content doesn't match")
+		}
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificates: %w", err)
+	}
+
+	si := sd.SignerInfos[0]
+	signer, err := findSigner(si.SID, certs)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := digestHash(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &signed{signer: signer, intermediates: x509.NewCertPool()}
+	for _, cert := range certs {
+		if cert != signer {
+			result.intermediates.AddCert(cert)
+		}
+	}
+
+	// Without signed attributes the signature is over the content itself. Otherwise it's over
+	// the attributes, which include the digest of the content.
+	signedBytes := content
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		// The attributes are signed as a SET rather than the implicitly tagged field.
+		signedBytes = append([]byte{0x31}, si.SignedAttrs.FullBytes[1:]...)
+
+		var attrs []attribute
+		if _, err := asn1.UnmarshalWithParams(signedBytes, &attrs, "set"); err != nil {
+			return nil, fmt.Errorf("failed to parse signed attributes: %w", err)
+		}
+
+		var digest []byte
+		for _, attr := range attrs {
+			if attr.Type.Equal(oidMessageDigest) {
+				if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+					return nil, fmt.Errorf("failed to parse message digest: %w", err)
+				}
+			}
+		}
+
+		h := hash.New()
+		h.Write(content)
+		if digest == nil || !bytes.Equal(digest, h.Sum(nil)) {
+			return nil, errors.New("content digest doesn't match")
+		}
+	}
+
+	algorithm, err := signatureAlgorithm(hash, signer)
+	if err != nil {
+		return nil, err
+	}
+	if err := signer.CheckSignature(algorithm, signedBytes, si.Signature); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	return result, nil
+}
+
+// SignDetached signs content with a detached CMS signature, the inverse of what's verified.
+// It's used to create signatures for packages served by test registries.
+func SignDetached(content []byte, cert *x509.Certificate, key crypto.Signer, intermediates ...*x509.Certificate) ([]byte, error) {
+	digest := crypto.SHA256.New()
+	digest.Write(content)
+
+	contentType, err := asn1.Marshal(oidData)
+	if err != nil {
+		return nil, err
+	}
+	messageDigest, err := asn1.Marshal(digest.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	signingTime, err := asn1.Marshal(time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	attrs, err := asn1.MarshalWithParams([]attribute{
+		{Type: oidContentType, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: contentType}},
+		{Type: oidMessageDigest, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: messageDigest}},
+		{Type: oidSigningTime, Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: signingTime}},
+	}, "set")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signed attributes: %w", err)
+	}
+
+	attrsDigest := crypto.SHA256.New()
+	attrsDigest.Write(attrs)
+	signature, err := key.Sign(rand.Reader, attrsDigest.Sum(nil), crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+
+	signatureOID := oidRSAEncryption
+	if _, ok := key.Public().(*ecdsa.PublicKey); ok {
+		signatureOID = oidECDSAWithSHA
+	}
+
+	certs := slices.Clone(cert.Raw)
+	for _, intermediate := range intermediates {
+		certs = append(certs, intermediate.Raw...)
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+		SerialNumber: cert.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapContentInfo{EContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        asn1.RawValue{FullBytes: append([]byte{0xA0}, attrs[1:]...)},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: signatureOID},
+			Signature:          signature,
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signed data: %w", err)
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
+
+// findSigner returns the certificate identified by the signer info, by issuer and serial number
+// or by subject key identifier.
+func findSigner(sid asn1.RawValue, certs []*x509.Certificate) (*x509.Certificate, error) {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, cert := range certs {
+			if bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+				return cert, nil
+			}
+		}
+		return nil, errors.New("signer certificate not found")
+	}
+
+	var ias issuerAndSerialNumber
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil, fmt.Errorf("failed to parse signer identifier: %w", err)
+	}
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+			return cert, nil
+		}
+	}
+
+	return nil, errors.New("signer certificate not found")
+}
+
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported digest algorithm: %s", oid)
+}
+
+func signatureAlgorithm(hash crypto.Hash, cert *x509.Certificate) (x509.SignatureAlgorithm, error) {
+	switch cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		switch hash {
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, nil
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, nil
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, nil
+		}
+	case *ecdsa.PublicKey:
+		switch hash {
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256, nil
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384, nil
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512, nil
+		}
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signer key: %T", cert.PublicKey)
+}
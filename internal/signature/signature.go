@@ -0,0 +1,173 @@
+// Package signature verifies the signatures of VSIX packages published to the marketplace.
+//
+// A signed version has a signature archive next to its package. The archive is a zip with a
+// .signature.manifest, a JSON file with the size and SHA-256 digest of the package, and a
+// .signature.p7s, a detached CMS signature of the manifest. The package is verified when the
+// signature chains to a trusted root and the manifest matches the package.
+package signature
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusVerified Status = "verified"
+	StatusUnsigned Status = "unsigned"
+	StatusInvalid  Status = "invalid"
+)
+
+const (
+	ManifestName  = ".signature.manifest"
+	SignatureName = ".signature.p7s"
+	// maxArchiveFileSize is far larger than any signature or manifest.
+	maxArchiveFileSize = 1 << 20
+)
+
+// ErrInvalid is returned when a package has a signature that doesn't verify.
+var ErrInvalid = errors.New("invalid package signature")
+
+// Manifest lists the digests of a signed package.
+type Manifest struct {
+	Package ManifestEntry            `json:"package"`
+	Entries map[string]ManifestEntry `json:"entries,omitempty"`
+}
+
+type ManifestEntry struct {
+	Size    int64             `json:"size"`
+	Digests map[string]string `json:"digests"`
+}
+
+type Verifier struct {
+	Roots *x509.CertPool
+}
+
+func NewVerifier(roots *x509.CertPool) *Verifier {
+	return &Verifier{Roots: roots}
+}
+
+// LoadTrustRoots reads the PEM encoded certificates at path.
+func LoadTrustRoots(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust roots: %w", err)
+	}
+
+	roots := x509.NewCertPool()
+	count := 0
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trust root: %w", err)
+		}
+		roots.AddCert(cert)
+		count++
+	}
+
+	if count == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+
+	return roots, nil
+}
+
+// Verify checks the signature archive of a package with the given hex encoded SHA-256 and size.
+// Every reason for the package not to verify is returned as ErrInvalid.
+func (v *Verifier) Verify(archive []byte, packageSHA256 string, packageSize int64) error {
+	manifestData, p7s, err := readArchive(archive)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	signed, err := verifyDetached(p7s, manifestData)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+
+	// The chain is checked at the current time. The signing time attribute is asserted by the
+	// signer itself, so it can't extend the validity of an expired or backdated certificate.
+	_, err = signed.signer.Verify(x509.VerifyOptions{
+		Roots:         v.Roots,
+		Intermediates: signed.intermediates,
+		CurrentTime:   time.Now(),
+		// The trust roots decide who may sign packages, not the key usage of the certificate.
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: untrusted signer: %w", ErrInvalid, err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return fmt.Errorf("%w: failed to parse manifest: %w", ErrInvalid, err)
+	}
+
+	if manifest.Package.Size != packageSize {
+		return fmt.Errorf("%w: package is %d bytes, signed for %d", ErrInvalid, packageSize, manifest.Package.Size)
+	}
+
+	digest, err := base64.StdEncoding.DecodeString(manifest.Package.Digests["sha256"])
+	if err != nil {
+		return fmt.Errorf("%w: failed to decode package digest: %w", ErrInvalid, err)
+	}
+	if hex.EncodeToString(digest) != packageSHA256 {
+		return fmt.Errorf("%w: package digest doesn't match", ErrInvalid)
+	}
+
+	return nil
+}
+
+func readArchive(archive []byte) ([]byte, []byte, error) {
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open signature archive: %w", err)
+	}
+
+	var manifest, p7s []byte
+	for _, file := range reader.File {
+		var dst *[]byte
+		switch file.Name {
+		case ManifestName:
+			dst = &manifest
+		case SignatureName:
+			dst = &p7s
+		default:
+			continue
+		}
+
+		readCloser, err := file.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %s: %w", file.Name, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(readCloser, maxArchiveFileSize))
+		readCloser.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+		}
+		*dst = data
+	}
+
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("%s not found", ManifestName)
+	}
+	if p7s == nil {
+		return nil, nil, fmt.Errorf("%s not found", SignatureName)
+	}
+
+	return manifest, p7s, nil
+}
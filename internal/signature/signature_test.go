@@ -0,0 +1,220 @@
+package signature_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vscodethemes/backend/internal/signature"
+	"github.com/vscodethemes/backend/internal/signature/signaturetest"
+)
+
+var vsix = []byte("PK\x03\x04 not a real package, only its bytes are signed")
+
+func TestVerify(t *testing.T) {
+	ca := newCA(t, "Test Root")
+	signer := newSigner(t, ca, 24*time.Hour)
+
+	digest := sha256.Sum256(vsix)
+	packageSHA256 := hex.EncodeToString(digest[:])
+
+	signed, err := signer.SignatureArchive(vsix)
+	if err != nil {
+		t.Fatalf("failed to sign package: %v", err)
+	}
+
+	tampered := signaturetest.NewManifest(vsix)
+	otherDigest := sha256.Sum256([]byte("another package"))
+	tampered.Package.Digests["sha256"] = base64.StdEncoding.EncodeToString(otherDigest[:])
+	tamperedDigest, err := signer.SignManifest(tampered)
+	if err != nil {
+		t.Fatalf("failed to sign manifest: %v", err)
+	}
+
+	wrongSize := signaturetest.NewManifest(vsix)
+	wrongSize.Package.Size++
+	signedWrongSize, err := signer.SignManifest(wrongSize)
+	if err != nil {
+		t.Fatalf("failed to sign manifest: %v", err)
+	}
+
+	expired, err := newSigner(t, ca, -time.Minute).SignatureArchive(vsix)
+	if err != nil {
+		t.Fatalf("failed to sign package: %v", err)
+	}
+
+	untrusted, err := newSigner(t, newCA(t, "Other Root"), 24*time.Hour).SignatureArchive(vsix)
+	if err != nil {
+		t.Fatalf("failed to sign package: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		archive []byte
+		size    int64
+		valid   bool
+	}{
+		{name: "verified", archive: signed, size: int64(len(vsix)), valid: true},
+		{name: "unsigned", archive: manifestOnly(t, vsix)},
+		{name: "not an archive", archive: []byte("not a zip")},
+		{name: "tampered digest", archive: tamperedDigest, size: int64(len(vsix))},
+		{name: "wrong package size", archive: signed, size: int64(len(vsix)) + 1},
+		{name: "wrong signed size", archive: signedWrongSize, size: int64(len(vsix))},
+		{name: "expired signer", archive: expired, size: int64(len(vsix))},
+		{name: "untrusted root", archive: untrusted, size: int64(len(vsix))},
+	}
+
+	verifier := signature.NewVerifier(ca.Pool())
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifier.Verify(test.archive, packageSHA256, test.size)
+			if test.valid && err != nil {
+				t.Fatalf("expected package to verify, got %v", err)
+			}
+			if !test.valid && !errors.Is(err, signature.ErrInvalid) {
+				t.Fatalf("expected ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyModifiedManifest(t *testing.T) {
+	ca := newCA(t, "Test Root")
+	signer := newSigner(t, ca, 24*time.Hour)
+
+	// Sign the manifest of another package and swap in the manifest of this one, so that the
+	// signature no longer covers the manifest.
+	other := []byte("another package")
+	otherArchive, err := signer.SignatureArchive(other)
+	if err != nil {
+		t.Fatalf("failed to sign package: %v", err)
+	}
+	_, p7s := readArchive(t, otherArchive)
+
+	manifest, _ := readArchive(t, mustSign(t, signer, vsix))
+	archive, err := signaturetest.BuildArchive(manifest, p7s)
+	if err != nil {
+		t.Fatalf("failed to build archive: %v", err)
+	}
+
+	digest := sha256.Sum256(vsix)
+	err = signature.NewVerifier(ca.Pool()).Verify(archive, hex.EncodeToString(digest[:]), int64(len(vsix)))
+	if !errors.Is(err, signature.ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+}
+
+func TestLoadTrustRoots(t *testing.T) {
+	ca := newCA(t, "Test Root")
+
+	path := filepath.Join(t.TempDir(), "roots.pem")
+	if err := os.WriteFile(path, ca.PEM(), 0o644); err != nil {
+		t.Fatalf("failed to write roots: %v", err)
+	}
+
+	roots, err := signature.LoadTrustRoots(path)
+	if err != nil {
+		t.Fatalf("failed to load roots: %v", err)
+	}
+
+	digest := sha256.Sum256(vsix)
+	err = signature.NewVerifier(roots).Verify(mustSign(t, newSigner(t, ca, time.Hour), vsix), hex.EncodeToString(digest[:]), int64(len(vsix)))
+	if err != nil {
+		t.Fatalf("expected package to verify with loaded roots, got %v", err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatalf("failed to write roots: %v", err)
+	}
+	if _, err := signature.LoadTrustRoots(empty); err == nil {
+		t.Fatalf("expected an error for a file without certificates")
+	}
+}
+
+func newCA(t *testing.T, name string) *signaturetest.CA {
+	t.Helper()
+	ca, err := signaturetest.NewCA(name)
+	if err != nil {
+		t.Fatalf("failed to create CA: %v", err)
+	}
+	return ca
+}
+
+func newSigner(t *testing.T, ca *signaturetest.CA, validFor time.Duration) *signaturetest.Signer {
+	t.Helper()
+	signer, err := ca.NewSigner("Test Publisher", validFor)
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	return signer
+}
+
+func mustSign(t *testing.T, signer *signaturetest.Signer, vsix []byte) []byte {
+	t.Helper()
+	archive, err := signer.SignatureArchive(vsix)
+	if err != nil {
+		t.Fatalf("failed to sign package: %v", err)
+	}
+	return archive
+}
+
+// manifestOnly returns a signature archive with a manifest but no signature.
+func manifestOnly(t *testing.T, vsix []byte) []byte {
+	t.Helper()
+	manifest, err := json.Marshal(signaturetest.NewManifest(vsix))
+	if err != nil {
+		t.Fatalf("failed to marshal manifest: %v", err)
+	}
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	file, err := writer.Create(signature.ManifestName)
+	if err != nil {
+		t.Fatalf("failed to create manifest: %v", err)
+	}
+	if _, err := file.Write(manifest); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func readArchive(t *testing.T, archive []byte) (manifest, p7s []byte) {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+
+	for _, file := range reader.File {
+		readCloser, err := file.Open()
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", file.Name, err)
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(readCloser); err != nil {
+			t.Fatalf("failed to read %s: %v", file.Name, err)
+		}
+		readCloser.Close()
+
+		switch file.Name {
+		case signature.ManifestName:
+			manifest = buf.Bytes()
+		case signature.SignatureName:
+			p7s = buf.Bytes()
+		}
+	}
+
+	return manifest, p7s
+}
@@ -0,0 +1,164 @@
+// Package signaturetest creates certificates and signature archives for verifying packages
+// offline, without the marketplace's signing keys.
+package signaturetest
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/vscodethemes/backend/internal/signature"
+)
+
+// CA is a self-signed root to use as the trust root of a signature.Verifier.
+type CA struct {
+	Cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// Signer signs packages with a certificate issued by a CA.
+type Signer struct {
+	Cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func NewCA(name string) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	cert, err := createCertificate(template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CA{Cert: cert, key: key}, nil
+}
+
+// Pool returns a cert pool with only the CA.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	return pool
+}
+
+// PEM returns the CA certificate in the format read by signature.LoadTrustRoots.
+func (ca *CA) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Cert.Raw})
+}
+
+// NewSigner issues a code signing certificate valid for the given duration from now.
+func (ca *CA) NewSigner(name string, validFor time.Duration) (*Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+
+	cert, err := createCertificate(template, ca.Cert, key.Public(), ca.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{Cert: cert, key: key}, nil
+}
+
+// SignatureArchive returns a signature archive for the package.
+func (s *Signer) SignatureArchive(vsix []byte) ([]byte, error) {
+	return s.SignManifest(NewManifest(vsix))
+}
+
+// SignManifest returns a signature archive for any manifest, such as one that doesn't match its
+// package.
+func (s *Signer) SignManifest(manifest signature.Manifest) ([]byte, error) {
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	p7s, err := signature.SignDetached(manifestData, s.Cert, s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildArchive(manifestData, p7s)
+}
+
+// NewManifest returns the manifest of a package.
+func NewManifest(vsix []byte) signature.Manifest {
+	digest := sha256.Sum256(vsix)
+
+	return signature.Manifest{
+		Package: signature.ManifestEntry{
+			Size:    int64(len(vsix)),
+			Digests: map[string]string{"sha256": base64.StdEncoding.EncodeToString(digest[:])},
+		},
+	}
+}
+
+// BuildArchive zips a manifest and signature, either can be tampered with before.
+func BuildArchive(manifest, p7s []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+
+	for name, data := range map[string][]byte{signature.ManifestName: manifest, signature.SignatureName: p7s} {
+		file, err := writer.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func createCertificate(template, parent *x509.Certificate, pub crypto.PublicKey, key crypto.Signer) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+
+	return x509.ParseCertificate(der)
+}
@@ -0,0 +1,175 @@
+package theme
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// colorValue is a default color, it can depend on colors resolved before it.
+type colorValue interface {
+	resolve(colors map[string]string) (string, bool)
+}
+
+type hex string
+
+func (h hex) resolve(map[string]string) (string, bool) {
+	return string(h), true
+}
+
+// ref defaults to another color.
+type ref string
+
+func (r ref) resolve(colors map[string]string) (string, bool) {
+	color, ok := colors[string(r)]
+	return color, ok
+}
+
+// transparent defaults to a color with its alpha multiplied by factor.
+type transparent struct {
+	value  colorValue
+	factor float64
+}
+
+func (t transparent) resolve(colors map[string]string) (string, bool) {
+	color, ok := t.value.resolve(colors)
+	if !ok {
+		return "", false
+	}
+	r, g, b, a, ok := parseHex(color)
+	if !ok {
+		return "", false
+	}
+	alpha := uint8(math.Round(float64(a) * t.factor))
+	return fmt.Sprintf("#%02X%02X%02X%02X", r, g, b, alpha), true
+}
+
+// colorDefault is the default of a color for each type of theme, nil when there's none.
+type colorDefault struct {
+	key                          string
+	dark, light, hcDark, hcLight colorValue
+}
+
+func (d colorDefault) forType(themeType Type) colorValue {
+	switch themeType {
+	case TypeLight:
+		return d.light
+	case TypeHCDark:
+		return d.hcDark
+	case TypeHCLight:
+		return d.hcLight
+	default:
+		return d.dark
+	}
+}
+
+// colorDefaults are the editor's defaults for the colors most themes rely on, from
+// https://github.com/microsoft/vscode/blob/main/src/vs/workbench/common/theme.ts and the color
+// registry. They're listed in order of dependency, colors that default to other colors come after
+// them.
+var colorDefaults = []colorDefault{
+	{"foreground", hex("#CCCCCC"), hex("#616161"), hex("#FFFFFF"), hex("#292929")},
+	{"focusBorder", hex("#007FD4"), hex("#0090F1"), hex("#F38518"), hex("#006BBD")},
+	{"contrastBorder", nil, nil, hex("#6FC3DF"), hex("#0F4A85")},
+	{"contrastActiveBorder", nil, nil, hex("#F38518"), hex("#006BBD")},
+	{"descriptionForeground", transparent{ref("foreground"), 0.7}, transparent{ref("foreground"), 0.7}, ref("foreground"), ref("foreground")},
+	{"errorForeground", hex("#F48771"), hex("#A1260D"), hex("#F48771"), hex("#B5200D")},
+	{"icon.foreground", hex("#C5C5C5"), hex("#424242"), hex("#FFFFFF"), hex("#292929")},
+	{"widget.shadow", hex("#0000005C"), hex("#00000029"), nil, nil},
+
+	{"editor.background", hex("#1E1E1E"), hex("#FFFFFF"), hex("#000000"), hex("#FFFFFF")},
+	{"editor.foreground", hex("#BBBBBB"), hex("#333333"), hex("#FFFFFF"), ref("foreground")},
+	{"editor.selectionBackground", hex("#264F78"), hex("#ADD6FF"), hex("#F3F518"), hex("#0F4A85")},
+	{"editorCursor.foreground", hex("#AEAFAD"), hex("#000000"), hex("#FFFFFF"), hex("#0F4A85")},
+	{"editorLineNumber.foreground", hex("#858585"), hex("#237893"), hex("#FFFFFF"), hex("#292929")},
+	{"editorLineNumber.activeForeground", hex("#C6C6C6"), hex("#0B216F"), ref("contrastActiveBorder"), ref("contrastActiveBorder")},
+	{"editorWhitespace.foreground", hex("#E3E4E229"), hex("#33333333"), hex("#E3E4E229"), hex("#CCCCCC")},
+	{"editorWidget.background", hex("#252526"), hex("#F3F3F3"), hex("#0C141F"), hex("#FFFFFF")},
+
+	{"activityBar.background", hex("#333333"), hex("#2C2C2C"), hex("#000000"), hex("#FFFFFF")},
+	{"activityBar.foreground", hex("#FFFFFF"), hex("#FFFFFF"), hex("#FFFFFF"), ref("editor.foreground")},
+	{"activityBar.inactiveForeground", transparent{ref("activityBar.foreground"), 0.4}, transparent{ref("activityBar.foreground"), 0.4}, hex("#FFFFFF"), ref("editor.foreground")},
+	{"activityBar.border", nil, nil, ref("contrastBorder"), ref("contrastBorder")},
+	{"activityBar.activeBorder", ref("activityBar.foreground"), ref("activityBar.foreground"), ref("contrastBorder"), ref("contrastBorder")},
+	{"activityBarBadge.background", hex("#007ACC"), hex("#007ACC"), hex("#000000"), hex("#0F4A85")},
+	{"activityBarBadge.foreground", hex("#FFFFFF"), hex("#FFFFFF"), hex("#FFFFFF"), hex("#FFFFFF")},
+
+	{"sideBar.background", hex("#252526"), hex("#F3F3F3"), hex("#000000"), hex("#FFFFFF")},
+	{"sideBar.border", nil, nil, ref("contrastBorder"), ref("contrastBorder")},
+
+	{"editorGroupHeader.tabsBackground", hex("#252526"), hex("#F3F3F3"), nil, nil},
+	{"tab.activeBackground", ref("editor.background"), ref("editor.background"), ref("editor.background"), ref("editor.background")},
+	{"tab.inactiveBackground", hex("#2D2D2D"), hex("#ECECEC"), nil, nil},
+	{"tab.activeForeground", hex("#FFFFFF"), hex("#333333"), hex("#FFFFFF"), hex("#292929")},
+	{"tab.inactiveForeground", transparent{ref("tab.activeForeground"), 0.5}, transparent{ref("tab.activeForeground"), 0.7}, hex("#FFFFFF"), hex("#292929")},
+	{"tab.border", hex("#252526"), hex("#F3F3F3"), ref("contrastBorder"), ref("contrastBorder")},
+	{"tab.activeBorderTop", nil, nil, nil, hex("#B5200D")},
+
+	{"panel.background", ref("editor.background"), ref("editor.background"), ref("editor.background"), ref("editor.background")},
+	{"panel.border", transparent{hex("#808080"), 0.35}, transparent{hex("#808080"), 0.35}, ref("contrastBorder"), ref("contrastBorder")},
+
+	{"statusBar.background", hex("#007ACC"), hex("#007ACC"), nil, nil},
+	{"statusBar.foreground", hex("#FFFFFF"), hex("#FFFFFF"), hex("#FFFFFF"), ref("editor.foreground")},
+	{"statusBar.border", nil, nil, ref("contrastBorder"), ref("contrastBorder")},
+
+	{"titleBar.activeBackground", hex("#3C3C3C"), hex("#DDDDDD"), hex("#000000"), hex("#FFFFFF")},
+	{"titleBar.activeForeground", hex("#CCCCCC"), hex("#333333"), hex("#FFFFFF"), hex("#292929")},
+	{"titleBar.border", nil, nil, ref("contrastBorder"), ref("contrastBorder")},
+
+	{"input.background", hex("#3C3C3C"), hex("#FFFFFF"), hex("#000000"), hex("#FFFFFF")},
+	{"input.foreground", ref("foreground"), ref("foreground"), ref("foreground"), ref("foreground")},
+	{"button.background", hex("#0E639C"), hex("#007ACC"), nil, hex("#0F4A85")},
+	{"button.foreground", hex("#FFFFFF"), hex("#FFFFFF"), hex("#FFFFFF"), hex("#FFFFFF")},
+	{"list.activeSelectionBackground", hex("#04395E"), hex("#0060C0"), nil, transparent{hex("#0F4A85"), 0.1}},
+	{"list.hoverBackground", hex("#2A2D2E"), hex("#F0F0F0"), nil, transparent{hex("#0F4A85"), 0.1}},
+	{"scrollbarSlider.background", transparent{hex("#797979"), 0.4}, transparent{hex("#646464"), 0.4}, transparent{ref("contrastBorder"), 0.6}, transparent{ref("contrastBorder"), 0.4}},
+}
+
+// applyDefaults sets the default of every color the theme doesn't define, or defines with a value
+// that isn't a hex color, like the editor ignores those.
+func applyDefaults(colors map[string]string, themeType Type) {
+	for _, d := range colorDefaults {
+		if _, _, _, _, ok := parseHex(colors[d.key]); ok {
+			continue
+		}
+		value := d.forType(themeType)
+		if value == nil {
+			continue
+		}
+		if color, ok := value.resolve(colors); ok {
+			if _, _, _, _, ok := parseHex(color); ok {
+				colors[d.key] = color
+			}
+		}
+	}
+}
+
+// parseHex parses #RGB, #RGBA, #RRGGBB and #RRGGBBAA colors.
+func parseHex(color string) (r, g, b, a uint8, ok bool) {
+	digits, found := strings.CutPrefix(color, "#")
+	if !found {
+		return 0, 0, 0, 0, false
+	}
+
+	if len(digits) == 3 || len(digits) == 4 {
+		expanded := make([]byte, 0, len(digits)*2)
+		for i := range len(digits) {
+			expanded = append(expanded, digits[i], digits[i])
+		}
+		digits = string(expanded)
+	}
+	if len(digits) == 6 {
+		digits += "FF"
+	}
+	if len(digits) != 8 {
+		return 0, 0, 0, 0, false
+	}
+
+	value, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return 0, 0, 0, 0, false
+	}
+
+	return uint8(value >> 24), uint8(value >> 16), uint8(value >> 8), uint8(value), true
+}
@@ -0,0 +1,363 @@
+// Package theme resolves VS Code color themes the way the editor does: theme files are JSON
+// with comments, can include other themes and TextMate themes, and colors they don't define fall
+// back to the defaults for their kind of theme.
+package theme
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"strings"
+
+	"github.com/vscodethemes/backend/internal/cli"
+	"github.com/vscodethemes/backend/internal/jsonc"
+)
+
+// ErrInvalid is returned when a theme is missing or can't be read. Reading the same theme again
+// won't succeed.
+var ErrInvalid = errors.New("invalid theme")
+
+type Type string
+
+const (
+	TypeDark    Type = "dark"
+	TypeLight   Type = "light"
+	TypeHCDark  Type = "hcDark"
+	TypeHCLight Type = "hcLight"
+)
+
+const (
+	extensionDir = "extension"
+	// maxIncludes is the maximum length of a chain of included themes.
+	maxIncludes = 10
+)
+
+type Theme struct {
+	// Path is the path of the theme file, relative to the extension.
+	Path string `json:"path"`
+	Name string `json:"name"`
+	Type Type   `json:"type"`
+	// Colors are the workbench colors, with defaults for the colors the theme doesn't define.
+	Colors      map[string]string `json:"colors"`
+	TokenColors []TokenColor      `json:"tokenColors"`
+	// SemanticTokenColors are keyed by semantic token selector. Values are either a color or
+	// an object with foreground and font style settings, they're left as is.
+	SemanticTokenColors  map[string]json.RawMessage `json:"semanticTokenColors"`
+	SemanticHighlighting bool                       `json:"semanticHighlighting"`
+}
+
+// TokenColor is a TextMate theme rule.
+type TokenColor struct {
+	Name     string        `json:"name,omitempty"`
+	Scope    Scope         `json:"scope,omitempty"`
+	Settings TokenSettings `json:"settings"`
+}
+
+type TokenSettings struct {
+	Foreground string `json:"foreground,omitempty"`
+	Background string `json:"background,omitempty"`
+	FontStyle  string `json:"fontStyle,omitempty"`
+}
+
+// Scope is the list of scope selectors of a rule. Themes write it either as an array or as a
+// comma separated string.
+type Scope []string
+
+func (s *Scope) UnmarshalJSON(data []byte) error {
+	var selectors []string
+	if err := json.Unmarshal(data, &selectors); err != nil {
+		var joined string
+		if err := json.Unmarshal(data, &joined); err != nil {
+			return fmt.Errorf("scope must be a string or an array of strings")
+		}
+		selectors = strings.Split(joined, ",")
+	}
+
+	*s = Scope{}
+	for _, selector := range selectors {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			*s = append(*s, selector)
+		}
+	}
+
+	return nil
+}
+
+// source is a theme file as it's written.
+type source struct {
+	Name                 string                     `json:"name"`
+	Type                 string                     `json:"type"`
+	Include              string                     `json:"include"`
+	Colors               map[string]any             `json:"colors"`
+	TokenColors          json.RawMessage            `json:"tokenColors"`
+	Settings             json.RawMessage            `json:"settings"`
+	SemanticTokenColors  map[string]json.RawMessage `json:"semanticTokenColors"`
+	SemanticHighlighting bool                       `json:"semanticHighlighting"`
+}
+
+// Load resolves a theme contributed by the extension in fsys, which is rooted at the root of the
+// package like manifest.GetInfo expects.
+func Load(fsys fs.FS, contribute cli.ThemeContribute) (*Theme, error) {
+	name := path.Join(extensionDir, contribute.Path)
+	if !inExtension(name) {
+		return nil, fmt.Errorf("%w: invalid theme path: %s", ErrInvalid, contribute.Path)
+	}
+
+	theme := &Theme{
+		Path:                contribute.Path,
+		Colors:              map[string]string{},
+		TokenColors:         []TokenColor{},
+		SemanticTokenColors: map[string]json.RawMessage{},
+	}
+
+	var themeType string
+	if err := theme.load(fsys, name, &themeType, 0); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalid, contribute.Path, err)
+	}
+
+	if contribute.Label != nil && *contribute.Label != "" {
+		theme.Name = *contribute.Label
+	}
+	if theme.Name == "" {
+		return nil, fmt.Errorf("%w: %s: theme must have a name", ErrInvalid, contribute.Path)
+	}
+
+	var ok bool
+	theme.Type, ok = parseType(themeType, contribute.UITheme)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: unknown theme type %q and ui theme %q", ErrInvalid, contribute.Path, themeType, contribute.UITheme)
+	}
+
+	applyDefaults(theme.Colors, theme.Type)
+
+	return theme, nil
+}
+
+// load reads a theme file into the theme, after the theme it includes. The including theme's
+// colors override the included ones and its token colors are appended after them, so they take
+// precedence too.
+func (t *Theme) load(fsys fs.FS, name string, themeType *string, depth int) error {
+	if depth > maxIncludes {
+		return fmt.Errorf("more than %d included themes", maxIncludes)
+	}
+
+	if IsTMTheme(name) {
+		tmThemeName, err := t.loadTMTheme(fsys, name)
+		if err != nil {
+			return err
+		}
+		if tmThemeName != "" {
+			t.Name = tmThemeName
+		}
+		return nil
+	}
+
+	if !strings.EqualFold(path.Ext(name), ".json") {
+		return fmt.Errorf("unsupported theme file: %s", name)
+	}
+
+	data, err := readFileFold(fsys, name)
+	if err != nil {
+		return err
+	}
+
+	var src source
+	if err := jsonc.Unmarshal(data, &src); err != nil {
+		return fmt.Errorf("invalid json at %s: %w", name, err)
+	}
+
+	if src.Include != "" {
+		include := path.Join(path.Dir(name), src.Include)
+		if !inExtension(include) {
+			return fmt.Errorf("invalid include path in %s: %s", name, src.Include)
+		}
+		if err := t.load(fsys, include, themeType, depth+1); err != nil {
+			return err
+		}
+	}
+
+	if src.Name != "" {
+		t.Name = src.Name
+	}
+	if src.Type != "" {
+		*themeType = src.Type
+	}
+
+	for key, value := range src.Colors {
+		// Colors that aren't strings are ignored by the editor too.
+		if color, ok := value.(string); ok {
+			t.Colors[key] = color
+		}
+	}
+
+	if err := t.loadTokenColors(fsys, name, src); err != nil {
+		return err
+	}
+
+	for selector, value := range src.SemanticTokenColors {
+		t.SemanticTokenColors[selector] = value
+	}
+	t.SemanticHighlighting = t.SemanticHighlighting || src.SemanticHighlighting
+
+	return nil
+}
+
+// loadTokenColors appends the rules of a theme file to the theme. Older themes list them under
+// settings, like TextMate themes do, or point to a TextMate theme relative to the theme file.
+func (t *Theme) loadTokenColors(fsys fs.FS, name string, src source) error {
+	raw := src.TokenColors
+	if len(raw) == 0 {
+		raw = src.Settings
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+
+	var tmThemePath string
+	if err := json.Unmarshal(raw, &tmThemePath); err == nil {
+		tmTheme := path.Join(path.Dir(name), tmThemePath)
+		if !inExtension(tmTheme) || !IsTMTheme(tmTheme) {
+			return fmt.Errorf("invalid token colors path in %s: %s", name, tmThemePath)
+		}
+		// Only the rules and colors of the TextMate theme are used, not its name.
+		_, err := t.loadTMTheme(fsys, tmTheme)
+		return err
+	}
+
+	var rules []json.RawMessage
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return fmt.Errorf("token colors in %s must be an array", name)
+	}
+	t.TokenColors = append(t.TokenColors, parseTokenColors(rules)...)
+
+	return nil
+}
+
+// parseTokenColors returns the valid rules, invalid rules are skipped like in the editor.
+func parseTokenColors(rules []json.RawMessage) []TokenColor {
+	tokenColors := []TokenColor{}
+	for _, rule := range rules {
+		var tokenColor TokenColor
+		if err := json.Unmarshal(rule, &tokenColor); err != nil {
+			continue
+		}
+		tokenColors = append(tokenColors, tokenColor)
+	}
+	return tokenColors
+}
+
+// MarshalSource returns the theme as a json theme file, with its includes and TextMate themes
+// resolved, for tools that only read json themes.
+func (t *Theme) MarshalSource() ([]byte, error) {
+	src := struct {
+		Name                 string                     `json:"name"`
+		Type                 string                     `json:"type"`
+		Colors               map[string]string          `json:"colors"`
+		TokenColors          []TokenColor               `json:"tokenColors"`
+		SemanticTokenColors  map[string]json.RawMessage `json:"semanticTokenColors"`
+		SemanticHighlighting bool                       `json:"semanticHighlighting"`
+	}{
+		Name:                 t.Name,
+		Type:                 sourceTypes[t.Type],
+		Colors:               t.Colors,
+		TokenColors:          t.TokenColors,
+		SemanticTokenColors:  t.SemanticTokenColors,
+		SemanticHighlighting: t.SemanticHighlighting,
+	}
+
+	return json.MarshalIndent(src, "", "  ")
+}
+
+// OpaqueColor returns a color as #rrggbb with its transparency blended over the editor
+// background, which is what it looks like in most of the workbench.
+func (t *Theme) OpaqueColor(key string) (string, bool) {
+	return t.opaque(t.Colors[key])
+}
+
+func (t *Theme) opaque(color string) (string, bool) {
+	r, g, b, a, ok := parseHex(color)
+	if !ok {
+		return "", false
+	}
+
+	if br, bg, bb, _, ok := parseHex(t.Colors["editor.background"]); ok && a < 255 {
+		blend := func(c, background uint8) uint8 {
+			return uint8(math.Round((float64(c)*float64(a) + float64(background)*float64(255-a)) / 255))
+		}
+		r, g, b = blend(r, br), blend(g, bg), blend(b, bb)
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b), true
+}
+
+// sourceTypes are the types as they're written in theme files.
+var sourceTypes = map[Type]string{
+	TypeLight:   "light",
+	TypeDark:    "dark",
+	TypeHCDark:  "hc-dark",
+	TypeHCLight: "hc-light",
+}
+
+// parseType returns the type of theme. The type in the theme file takes precedence over the UI
+// theme it's contributed as.
+func parseType(themeType, uiTheme string) (Type, bool) {
+	for t, sourceType := range sourceTypes {
+		if themeType == sourceType {
+			return t, true
+		}
+	}
+
+	switch uiTheme {
+	case "vs":
+		return TypeLight, true
+	case "vs-dark":
+		return TypeDark, true
+	case "hc-black":
+		return TypeHCDark, true
+	case "hc-light":
+		return TypeHCLight, true
+	}
+
+	return "", false
+}
+
+// inExtension returns true if the path is a file in the extension directory of the package.
+// Themes can't point outside of it.
+func inExtension(name string) bool {
+	return fs.ValidPath(name) && strings.HasPrefix(name, extensionDir+"/")
+}
+
+// readFileFold reads a file, matching the path case-insensitively if there's no exact match.
+// Themes are often written on case-insensitive filesystems with paths that don't match their
+// files' case.
+func readFileFold(fsys fs.FS, name string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return data, err
+	}
+
+	resolved := "."
+	for _, segment := range strings.Split(name, "/") {
+		entries, dirErr := fs.ReadDir(fsys, resolved)
+		if dirErr != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, entry := range entries {
+			if strings.EqualFold(entry.Name(), segment) {
+				resolved = path.Join(resolved, entry.Name())
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, err
+		}
+	}
+
+	return fs.ReadFile(fsys, resolved)
+}
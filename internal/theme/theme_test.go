@@ -0,0 +1,327 @@
+package theme_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/vscodethemes/backend/internal/cli"
+	"github.com/vscodethemes/backend/internal/theme"
+)
+
+func file(data string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(data)}
+}
+
+func contribute(path, uiTheme string) cli.ThemeContribute {
+	return cli.ThemeContribute{Path: path, UITheme: uiTheme}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"extension/themes/base.json": file(`{
+			// Comments and trailing commas are allowed.
+			"name": "Base",
+			"type": "light",
+			"colors": {
+				"editor.background": "#101010",
+				"editor.foreground": "#eeeeee",
+				"tab.border": "#202020",
+			},
+			"tokenColors": [
+				{ "scope": "comment", "settings": { "foreground": "#00ff00" } },
+			],
+		}`),
+		"extension/themes/dark.json": file(`{
+			"name": "Dark",
+			"type": "dark",
+			"include": "./base.json",
+			"colors": {
+				"editor.background": "#000000",
+				"activityBar.foreground": 1
+			},
+			"tokenColors": [
+				{ "scope": "keyword, storage", "settings": { "foreground": "#ff0000", "fontStyle": "bold" } },
+				{ "scope": 1, "settings": { "foreground": "#0000ff" } }
+			],
+			"semanticHighlighting": true,
+			"semanticTokenColors": { "variable": "#ffffff" }
+		}`),
+		"extension/themes/settings.json": file(`{
+			"name": "Settings",
+			"settings": [
+				{ "scope": ["string"], "settings": { "foreground": "#ffff00" } }
+			]
+		}`),
+		"extension/themes/Mixed/Case.json": file(`{ "name": "Case", "include": "../BASE.JSON" }`),
+		"extension/themes/outside.json":    file(`{ "name": "Outside", "include": "../../outside.json" }`),
+		"outside.json":                     file(`{ "name": "Outside" }`),
+		"extension/themes/broken.json":     file(`{ "name": `),
+		"extension/themes/nameless.json":   file(`{ "colors": {} }`),
+		"extension/themes/untyped.json":    file(`{ "name": "Untyped" }`),
+		"extension/themes/badtokens.json":  file(`{ "name": "Bad tokens", "tokenColors": {} }`),
+		"extension/themes/loop.json":       file(`{ "name": "Loop", "include": "./loop.json" }`),
+	}
+
+	// A chain of includes one longer than allowed, and one at the limit.
+	for i := range 12 {
+		fsys[fmt.Sprintf("extension/chain/%d.json", i)] = file(fmt.Sprintf(`{ "name": "Chain %d", "include": "./%d.json" }`, i, i+1))
+	}
+	fsys["extension/chain/12.json"] = file(`{ "name": "End" }`)
+
+	tests := []struct {
+		name        string
+		contribute  cli.ThemeContribute
+		invalid     bool
+		wantName    string
+		wantType    theme.Type
+		wantColors  map[string]string
+		wantScopes  [][]string
+		wantMissing []string
+	}{
+		{
+			name:       "include",
+			contribute: contribute("./themes/dark.json", "vs"),
+			wantName:   "Dark",
+			wantType:   theme.TypeDark,
+			wantColors: map[string]string{
+				// Overridden by the including theme.
+				"editor.background": "#000000",
+				// Inherited from the included theme.
+				"editor.foreground": "#eeeeee",
+				"tab.border":        "#202020",
+				// Not a string, so the default is used.
+				"activityBar.foreground": "#FFFFFF",
+			},
+			// Rules of the included theme come first, invalid rules are skipped.
+			wantScopes: [][]string{{"comment"}, {"keyword", "storage"}},
+		},
+		{
+			name:       "settings instead of token colors",
+			contribute: contribute("./themes/settings.json", "vs-dark"),
+			wantName:   "Settings",
+			wantType:   theme.TypeDark,
+			wantScopes: [][]string{{"string"}},
+		},
+		{
+			name:       "paths matched regardless of case",
+			contribute: contribute("./themes/mixed/case.JSON", "vs-dark"),
+			wantName:   "Case",
+			wantType:   theme.TypeLight,
+			wantColors: map[string]string{"editor.background": "#101010"},
+		},
+		{
+			name:       "chain at the include limit",
+			contribute: contribute("./chain/2.json", "vs-dark"),
+			wantName:   "Chain 2",
+			wantType:   theme.TypeDark,
+		},
+		{name: "chain over the include limit", contribute: contribute("./chain/1.json", "vs-dark"), invalid: true},
+		{name: "include cycle", contribute: contribute("./themes/loop.json", "vs-dark"), invalid: true},
+		{name: "include outside the extension", contribute: contribute("./themes/outside.json", "vs-dark"), invalid: true},
+		{name: "path outside the extension", contribute: contribute("../outside.json", "vs-dark"), invalid: true},
+		{name: "missing file", contribute: contribute("./themes/missing.json", "vs-dark"), invalid: true},
+		{name: "invalid json", contribute: contribute("./themes/broken.json", "vs-dark"), invalid: true},
+		{name: "no name", contribute: contribute("./themes/nameless.json", "vs-dark"), invalid: true},
+		{name: "unknown type", contribute: contribute("./themes/untyped.json", "unknown"), invalid: true},
+		{name: "token colors not an array", contribute: contribute("./themes/badtokens.json", "vs-dark"), invalid: true},
+		{name: "unsupported file", contribute: contribute("./themes/dark.yaml", "vs-dark"), invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolved, err := theme.Load(fsys, test.contribute)
+			if test.invalid {
+				if !errors.Is(err, theme.ErrInvalid) {
+					t.Fatalf("expected ErrInvalid, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to load theme: %v", err)
+			}
+
+			if resolved.Name != test.wantName {
+				t.Errorf("expected name %q, got %q", test.wantName, resolved.Name)
+			}
+			if resolved.Type != test.wantType {
+				t.Errorf("expected type %q, got %q", test.wantType, resolved.Type)
+			}
+			for key, want := range test.wantColors {
+				if got := resolved.Colors[key]; got != want {
+					t.Errorf("expected %s to be %s, got %s", key, want, got)
+				}
+			}
+			if test.wantScopes != nil {
+				scopes := [][]string{}
+				for _, tokenColor := range resolved.TokenColors {
+					scopes = append(scopes, tokenColor.Scope)
+				}
+				if !slices.EqualFunc(scopes, test.wantScopes, slices.Equal) {
+					t.Errorf("expected scopes %v, got %v", test.wantScopes, scopes)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadLabel(t *testing.T) {
+	fsys := fstest.MapFS{"extension/theme.json": file(`{ "name": "Name" }`)}
+
+	label := "Label"
+	resolved, err := theme.Load(fsys, cli.ThemeContribute{Path: "./theme.json", UITheme: "vs-dark", Label: &label})
+	if err != nil {
+		t.Fatalf("failed to load theme: %v", err)
+	}
+	if resolved.Name != "Label" {
+		t.Errorf("expected the label to take precedence over the name, got %q", resolved.Name)
+	}
+}
+
+func TestLoadType(t *testing.T) {
+	tests := []struct {
+		themeType string
+		uiTheme   string
+		want      theme.Type
+	}{
+		// The type in the theme file takes precedence over the UI theme.
+		{themeType: "light", uiTheme: "vs-dark", want: theme.TypeLight},
+		{themeType: "dark", uiTheme: "vs", want: theme.TypeDark},
+		{themeType: "hc-dark", uiTheme: "vs", want: theme.TypeHCDark},
+		{themeType: "hc-light", uiTheme: "vs-dark", want: theme.TypeHCLight},
+		// Unknown types fall back to the UI theme.
+		{themeType: "unknown", uiTheme: "vs", want: theme.TypeLight},
+		{uiTheme: "vs-dark", want: theme.TypeDark},
+		{uiTheme: "hc-black", want: theme.TypeHCDark},
+		{uiTheme: "hc-light", want: theme.TypeHCLight},
+	}
+
+	for _, test := range tests {
+		fsys := fstest.MapFS{"extension/theme.json": file(fmt.Sprintf(`{ "name": "Theme", "type": %q }`, test.themeType))}
+
+		resolved, err := theme.Load(fsys, contribute("./theme.json", test.uiTheme))
+		if err != nil {
+			t.Fatalf("failed to load theme of type %q and ui theme %q: %v", test.themeType, test.uiTheme, err)
+		}
+		if resolved.Type != test.want {
+			t.Errorf("expected type %q and ui theme %q to be %q, got %q", test.themeType, test.uiTheme, test.want, resolved.Type)
+		}
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	tests := []struct {
+		uiTheme string
+		colors  string
+		want    map[string]string
+	}{
+		{
+			uiTheme: "vs-dark",
+			want: map[string]string{
+				"editor.background":      "#1E1E1E",
+				"activityBar.foreground": "#FFFFFF",
+				// Defaults that depend on other colors.
+				"activityBar.activeBorder":       "#FFFFFF",
+				"activityBar.inactiveForeground": "#FFFFFF66",
+				"tab.activeBackground":           "#1E1E1E",
+			},
+		},
+		{
+			uiTheme: "vs-dark",
+			colors:  `{ "editor.background": "#123456", "activityBar.foreground": "#abcdef" }`,
+			want: map[string]string{
+				"tab.activeBackground":           "#123456",
+				"activityBar.activeBorder":       "#abcdef",
+				"activityBar.inactiveForeground": "#ABCDEF66",
+			},
+		},
+		{
+			// Colors that aren't hex colors are replaced by their default.
+			uiTheme: "vs-dark",
+			colors:  `{ "tab.border": "", "activityBar.foreground": "transparent", "editor.background": "#12" }`,
+			want: map[string]string{
+				"tab.border":               "#252526",
+				"activityBar.foreground":   "#FFFFFF",
+				"activityBar.activeBorder": "#FFFFFF",
+				"editor.background":        "#1E1E1E",
+				"tab.activeBackground":     "#1E1E1E",
+			},
+		},
+		{
+			uiTheme: "vs",
+			want:    map[string]string{"editor.background": "#FFFFFF", "editor.foreground": "#333333"},
+		},
+		{
+			uiTheme: "hc-black",
+			want:    map[string]string{"editor.background": "#000000", "tab.border": "#6FC3DF"},
+		},
+		{
+			uiTheme: "hc-light",
+			want:    map[string]string{"editor.foreground": "#292929", "activityBar.border": "#0F4A85"},
+		},
+	}
+
+	for _, test := range tests {
+		colors := test.colors
+		if colors == "" {
+			colors = "{}"
+		}
+		fsys := fstest.MapFS{"extension/theme.json": file(fmt.Sprintf(`{ "name": "Theme", "colors": %s }`, colors))}
+
+		resolved, err := theme.Load(fsys, contribute("./theme.json", test.uiTheme))
+		if err != nil {
+			t.Fatalf("failed to load theme: %v", err)
+		}
+		for key, want := range test.want {
+			if got := resolved.Colors[key]; got != want {
+				t.Errorf("expected %s of a %s theme to default to %s, got %s", key, test.uiTheme, want, got)
+			}
+		}
+	}
+}
+
+func TestOpaqueColor(t *testing.T) {
+	fsys := fstest.MapFS{"extension/theme.json": file(`{
+		"name": "Theme",
+		"colors": {
+			"editor.background": "#000000",
+			"opaque": "#FF8000",
+			"short": "#f80",
+			"half": "#FFFFFF80",
+			"shortHalf": "#fff8",
+			"transparent": "#FF000000",
+			"named": "red",
+			"empty": ""
+		}
+	}`)}
+
+	resolved, err := theme.Load(fsys, contribute("./theme.json", "vs-dark"))
+	if err != nil {
+		t.Fatalf("failed to load theme: %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{key: "opaque", want: "#ff8000", ok: true},
+		{key: "short", want: "#ff8800", ok: true},
+		// Blended over the editor background.
+		{key: "half", want: "#808080", ok: true},
+		{key: "shortHalf", want: "#888888", ok: true},
+		{key: "transparent", want: "#000000", ok: true},
+		{key: "named"},
+		{key: "empty"},
+		{key: "missing"},
+	}
+
+	for _, test := range tests {
+		got, ok := resolved.OpaqueColor(test.key)
+		if got != test.want || ok != test.ok {
+			t.Errorf("expected %s to be %q (%t), got %q (%t)", test.key, test.want, test.ok, got, ok)
+		}
+	}
+}
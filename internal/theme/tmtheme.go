@@ -0,0 +1,235 @@
+package theme
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// tmThemeColors maps the global settings of a TextMate theme to workbench colors, from
+// https://github.com/microsoft/vscode/blob/main/src/vs/workbench/services/themes/common/themeCompatibility.ts
+var tmThemeColors = map[string][]string{
+	"background":                {"editor.background"},
+	"foreground":                {"editor.foreground"},
+	"selection":                 {"editor.selectionBackground"},
+	"inactiveSelection":         {"editor.inactiveSelectionBackground"},
+	"selectionHighlightColor":   {"editor.selectionHighlightBackground"},
+	"findMatchHighlight":        {"editor.findMatchHighlightBackground", "peekViewResult.matchHighlightBackground"},
+	"currentFindMatchHighlight": {"editor.findMatchBackground"},
+	"hoverHighlight":            {"editor.hoverHighlightBackground"},
+	"wordHighlight":             {"editor.wordHighlightBackground"},
+	"wordHighlightStrong":       {"editor.wordHighlightStrongBackground"},
+	"findRangeHighlight":        {"editor.findRangeHighlightBackground"},
+	"referenceHighlight":        {"peekViewEditor.matchHighlightBackground"},
+	"lineHighlight":             {"editor.lineHighlightBackground"},
+	"rangeHighlight":            {"editor.rangeHighlightBackground"},
+	"caret":                     {"editorCursor.foreground"},
+	"invisibles":                {"editorWhitespace.foreground"},
+	"guide":                     {"editorIndentGuide.background"},
+	"activeGuide":               {"editorIndentGuide.activeBackground"},
+	"ansiBlack":                 {"terminal.ansiBlack"},
+	"ansiRed":                   {"terminal.ansiRed"},
+	"ansiGreen":                 {"terminal.ansiGreen"},
+	"ansiYellow":                {"terminal.ansiYellow"},
+	"ansiBlue":                  {"terminal.ansiBlue"},
+	"ansiMagenta":               {"terminal.ansiMagenta"},
+	"ansiCyan":                  {"terminal.ansiCyan"},
+	"ansiWhite":                 {"terminal.ansiWhite"},
+	"ansiBrightBlack":           {"terminal.ansiBrightBlack"},
+	"ansiBrightRed":             {"terminal.ansiBrightRed"},
+	"ansiBrightGreen":           {"terminal.ansiBrightGreen"},
+	"ansiBrightYellow":          {"terminal.ansiBrightYellow"},
+	"ansiBrightBlue":            {"terminal.ansiBrightBlue"},
+	"ansiBrightMagenta":         {"terminal.ansiBrightMagenta"},
+	"ansiBrightCyan":            {"terminal.ansiBrightCyan"},
+	"ansiBrightWhite":           {"terminal.ansiBrightWhite"},
+}
+
+// IsTMTheme returns true if the path is a TextMate theme.
+func IsTMTheme(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".tmtheme")
+}
+
+// loadTMTheme reads a TextMate theme into the theme and returns its name. Its rules are appended
+// to the token colors and the settings of the rule without a scope, which apply to the whole
+// editor, are converted to workbench colors.
+func (t *Theme) loadTMTheme(fsys fs.FS, name string) (string, error) {
+	data, err := readFileFold(fsys, name)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := decodePlist(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid plist at %s: %w", name, err)
+	}
+
+	// Round trip through json to parse the rules like the rules of json themes.
+	data, err = json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid plist at %s: %w", name, err)
+	}
+
+	var src struct {
+		Name     string            `json:"name"`
+		Settings []json.RawMessage `json:"settings"`
+	}
+	if err := json.Unmarshal(data, &src); err != nil {
+		return "", fmt.Errorf("settings in %s must be an array", name)
+	}
+
+	tokenColors := parseTokenColors(src.Settings)
+	for _, raw := range src.Settings {
+		var rule struct {
+			Scope    any            `json:"scope"`
+			Settings map[string]any `json:"settings"`
+		}
+		if err := json.Unmarshal(raw, &rule); err != nil || rule.Scope != nil {
+			continue
+		}
+		for setting, value := range rule.Settings {
+			color, ok := value.(string)
+			if !ok {
+				continue
+			}
+			for _, key := range tmThemeColors[setting] {
+				t.Colors[key] = color
+			}
+		}
+	}
+	t.TokenColors = append(t.TokenColors, tokenColors...)
+
+	return src.Name, nil
+}
+
+// decodePlist decodes an XML property list into maps, slices, strings, numbers and booleans.
+func decodePlist(data []byte) (any, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// Property lists declare a doctype that doesn't need to be resolved.
+	decoder.Strict = false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, errors.New("missing plist element")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "plist" {
+			return nil, fmt.Errorf("unexpected element %s", start.Name.Local)
+		}
+
+		value, end, err := decodePlistValue(decoder)
+		if err != nil {
+			return nil, err
+		}
+		if end {
+			return nil, errors.New("empty plist")
+		}
+		return value, nil
+	}
+}
+
+// decodePlistValue decodes the next value, returning true instead if the enclosing element ends
+// first.
+func decodePlistValue(decoder *xml.Decoder) (any, bool, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch token := token.(type) {
+		case xml.EndElement:
+			return nil, true, nil
+		case xml.StartElement:
+			value, err := decodePlistElement(decoder, token)
+			return value, false, err
+		}
+	}
+}
+
+func decodePlistElement(decoder *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := map[string]any{}
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			switch token := token.(type) {
+			case xml.EndElement:
+				return dict, nil
+			case xml.StartElement:
+				if token.Name.Local != "key" {
+					return nil, fmt.Errorf("expected key in dict, got %s", token.Name.Local)
+				}
+				var key string
+				if err := decoder.DecodeElement(&key, &token); err != nil {
+					return nil, err
+				}
+				value, end, err := decodePlistValue(decoder)
+				if err != nil {
+					return nil, err
+				}
+				if end {
+					return nil, fmt.Errorf("missing value for key %s", key)
+				}
+				dict[key] = value
+			}
+		}
+
+	case "array":
+		array := []any{}
+		for {
+			value, end, err := decodePlistValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if end {
+				return array, nil
+			}
+			array = append(array, value)
+		}
+
+	case "true", "false":
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+
+	case "integer", "real":
+		var text string
+		if err := decoder.DecodeElement(&text, &start); err != nil {
+			return nil, err
+		}
+		number, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", start.Name.Local, text)
+		}
+		return number, nil
+
+	case "string", "date", "data":
+		var text string
+		if err := decoder.DecodeElement(&text, &start); err != nil {
+			return nil, err
+		}
+		return text, nil
+	}
+
+	return nil, fmt.Errorf("unexpected element %s", start.Name.Local)
+}
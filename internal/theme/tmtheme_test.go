@@ -0,0 +1,182 @@
+package theme_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"github.com/vscodethemes/backend/internal/theme"
+)
+
+const tmTheme = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>name</key>
+	<string>Monokai</string>
+	<key>settings</key>
+	<array>
+		<dict>
+			<key>settings</key>
+			<dict>
+				<key>background</key>
+				<string>#272822</string>
+				<key>foreground</key>
+				<string>#F8F8F2</string>
+				<key>caret</key>
+				<string>#F8F8F0</string>
+				<key>lineHighlight</key>
+				<string>#3E3D32</string>
+			</dict>
+		</dict>
+		<dict>
+			<key>name</key>
+			<string>Keyword</string>
+			<key>scope</key>
+			<string>keyword, storage</string>
+			<key>settings</key>
+			<dict>
+				<key>foreground</key>
+				<string>#F92672</string>
+				<key>fontStyle</key>
+				<string>italic</string>
+			</dict>
+		</dict>
+		<dict>
+			<key>scope</key>
+			<string>string</string>
+			<key>settings</key>
+			<dict>
+				<key>foreground</key>
+				<string>#E6DB74</string>
+			</dict>
+		</dict>
+	</array>
+	<key>uuid</key>
+	<string>D8D5E82E-3D5B-46B5-B38E-8C841C21347D</string>
+	<key>semanticClass</key>
+	<integer>1</integer>
+	<key>colorSpaceName</key>
+	<true/>
+</dict>
+</plist>`
+
+func TestLoadTMTheme(t *testing.T) {
+	fsys := fstest.MapFS{
+		"extension/themes/Monokai.tmTheme": file(tmTheme),
+		"extension/themes/json.json": file(`{
+			"name": "Json",
+			"colors": { "editor.background": "#000000" },
+			"tokenColors": "./monokai.tmtheme"
+		}`),
+		"extension/themes/notplist.tmTheme": file(`<dict></dict>`),
+		"extension/themes/broken.tmTheme":   file(`<plist><dict><key>name</key></dict></plist>`),
+		"extension/themes/nottmtheme.json":  file(`{ "name": "Json", "tokenColors": "./json.json" }`),
+		"extension/themes/outside.json":     file(`{ "name": "Json", "tokenColors": "../../monokai.tmTheme" }`),
+	}
+
+	t.Run("tmTheme", func(t *testing.T) {
+		resolved, err := theme.Load(fsys, contribute("./themes/Monokai.tmTheme", "vs-dark"))
+		if err != nil {
+			t.Fatalf("failed to load theme: %v", err)
+		}
+
+		if resolved.Name != "Monokai" {
+			t.Errorf("expected the name of the tmTheme, got %q", resolved.Name)
+		}
+		if resolved.Type != theme.TypeDark {
+			t.Errorf("expected the type of the ui theme, got %q", resolved.Type)
+		}
+
+		// Global settings are converted to workbench colors.
+		for key, want := range map[string]string{
+			"editor.background":              "#272822",
+			"editor.foreground":              "#F8F8F2",
+			"editorCursor.foreground":        "#F8F8F0",
+			"editor.lineHighlightBackground": "#3E3D32",
+			"tab.activeBackground":           "#272822",
+		} {
+			if got := resolved.Colors[key]; got != want {
+				t.Errorf("expected %s to be %s, got %s", key, want, got)
+			}
+		}
+
+		if len(resolved.TokenColors) != 3 {
+			t.Fatalf("expected 3 rules, got %d", len(resolved.TokenColors))
+		}
+		keyword := resolved.TokenColors[1]
+		if keyword.Name != "Keyword" || !slices.Equal(keyword.Scope, []string{"keyword", "storage"}) {
+			t.Errorf("unexpected rule: %+v", keyword)
+		}
+		if keyword.Settings.Foreground != "#F92672" || keyword.Settings.FontStyle != "italic" {
+			t.Errorf("unexpected rule settings: %+v", keyword.Settings)
+		}
+	})
+
+	t.Run("token colors in a tmTheme", func(t *testing.T) {
+		resolved, err := theme.Load(fsys, contribute("./themes/json.json", "vs-dark"))
+		if err != nil {
+			t.Fatalf("failed to load theme: %v", err)
+		}
+
+		// The name of the json theme is kept, its colors are overridden by the tmTheme's, which
+		// is read after them.
+		if resolved.Name != "Json" {
+			t.Errorf("expected the name of the json theme, got %q", resolved.Name)
+		}
+		if got := resolved.Colors["editor.background"]; got != "#272822" {
+			t.Errorf("expected the background of the tmTheme, got %s", got)
+		}
+		if len(resolved.TokenColors) != 3 {
+			t.Errorf("expected the 3 rules of the tmTheme, got %d", len(resolved.TokenColors))
+		}
+	})
+
+	for name, path := range map[string]string{
+		"not a plist":                    "./themes/notplist.tmTheme",
+		"invalid plist":                  "./themes/broken.tmTheme",
+		"token colors in a json file":    "./themes/nottmtheme.json",
+		"token colors outside extension": "./themes/outside.json",
+		"missing tmTheme":                "./themes/missing.tmTheme",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := theme.Load(fsys, contribute(path, "vs-dark")); !errors.Is(err, theme.ErrInvalid) {
+				t.Errorf("expected ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMarshalSource(t *testing.T) {
+	fsys := fstest.MapFS{"extension/theme.tmTheme": file(tmTheme)}
+
+	resolved, err := theme.Load(fsys, contribute("./theme.tmTheme", "vs"))
+	if err != nil {
+		t.Fatalf("failed to load theme: %v", err)
+	}
+
+	// The converted theme is read back like the image generator reads it.
+	source, err := resolved.MarshalSource()
+	if err != nil {
+		t.Fatalf("failed to marshal theme: %v", err)
+	}
+	fsys["extension/theme.json"] = file(string(source))
+
+	converted, err := theme.Load(fsys, contribute("./theme.json", "vs-dark"))
+	if err != nil {
+		t.Fatalf("failed to load converted theme: %v", err)
+	}
+
+	if converted.Name != resolved.Name || converted.Type != theme.TypeLight {
+		t.Errorf("expected name %q and type light, got %q and %q", resolved.Name, converted.Name, converted.Type)
+	}
+	for key, want := range resolved.Colors {
+		if got := converted.Colors[key]; got != want {
+			t.Errorf("expected %s to be %s, got %s", key, want, got)
+		}
+	}
+	if len(converted.TokenColors) != len(resolved.TokenColors) {
+		t.Errorf("expected %d rules, got %d", len(resolved.TokenColors), len(converted.TokenColors))
+	}
+}
@@ -0,0 +1,169 @@
+package theme
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/vscodethemes/backend/internal/cli"
+)
+
+// TokenCategory is a kind of token themes can be searched by, styled like tokens of its TextMate
+// scope or, with semantic highlighting, of its semantic token type.
+type TokenCategory struct {
+	Name         string
+	Scope        string
+	SemanticType string
+}
+
+// TokenCategories are the kinds of tokens that give themes their look.
+var TokenCategories = []TokenCategory{
+	{Name: "keyword", Scope: "keyword.control", SemanticType: "keyword"},
+	{Name: "string", Scope: "string.quoted", SemanticType: "string"},
+	{Name: "comment", Scope: "comment", SemanticType: "comment"},
+	{Name: "function", Scope: "entity.name.function", SemanticType: "function"},
+	{Name: "type", Scope: "entity.name.type", SemanticType: "type"},
+	{Name: "number", Scope: "constant.numeric", SemanticType: "number"},
+	{Name: "constant", Scope: "constant.language"},
+	{Name: "variable", Scope: "variable.other", SemanticType: "variable"},
+	{Name: "operator", Scope: "keyword.operator", SemanticType: "operator"},
+	{Name: "tag", Scope: "entity.name.tag"},
+}
+
+// TokenStyle is how tokens are styled. FontStyle is a space separated list of italic, bold,
+// underline and strikethrough.
+type TokenStyle struct {
+	Foreground string
+	FontStyle  string
+}
+
+// TokenStyle returns the style of the tokens of a category, with the foreground as #rrggbb. The
+// style is taken from the first token of the category in the rendered languages, as the renderer
+// styled it, and semantic token colors are applied on top when the theme enables them. False is
+// returned if neither styles the category.
+func (t *Theme) TokenStyle(category TokenCategory, languages []cli.LanguageResult) (TokenStyle, bool) {
+	// The renderer leaves tokens with the default foreground without a color, they're drawn in
+	// the editor foreground.
+	style, ok := renderedStyle(category.Scope, languages)
+	if style.Foreground == "" {
+		style.Foreground = t.Colors["editor.foreground"]
+	}
+
+	if t.SemanticHighlighting && category.SemanticType != "" {
+		if raw, found := t.SemanticTokenColors[category.SemanticType]; found {
+			style, ok = applySemanticStyle(style, raw), true
+		}
+	}
+	if !ok {
+		return TokenStyle{}, false
+	}
+
+	style.Foreground, _ = t.opaque(style.Foreground)
+	style.FontStyle = normalizeFontStyle(style.FontStyle)
+
+	return style, true
+}
+
+// renderedStyle returns the style of the first token whose innermost scope is the scope or one
+// it's a prefix of. Outer scopes are ignored, since the innermost one decides the token's style.
+func renderedStyle(scope string, languages []cli.LanguageResult) (TokenStyle, bool) {
+	for _, language := range languages {
+		for _, line := range language.Tokens {
+			for _, token := range line {
+				if len(token.Scopes) == 0 || strings.TrimSpace(token.Text) == "" {
+					continue
+				}
+				innermost := token.Scopes[len(token.Scopes)-1]
+				if innermost != scope && !strings.HasPrefix(innermost, scope+".") {
+					continue
+				}
+				return tokenStyle(token.Style), true
+			}
+		}
+	}
+	return TokenStyle{}, false
+}
+
+// tokenStyle converts the style of a rendered token.
+func tokenStyle(style cli.Style) TokenStyle {
+	var converted TokenStyle
+	if style.Color != nil {
+		converted.Foreground = *style.Color
+	}
+
+	fontStyles := []string{}
+	for _, value := range []*string{style.FontStyle, style.FontWeight, style.TextDecoration} {
+		if value == nil {
+			continue
+		}
+		switch *value {
+		case "italic", "bold", "underline":
+			fontStyles = append(fontStyles, *value)
+		case "line-through":
+			fontStyles = append(fontStyles, "strikethrough")
+		}
+	}
+	converted.FontStyle = strings.Join(fontStyles, " ")
+
+	return converted
+}
+
+// applySemanticStyle overrides the style with a semantic token style, which is either a color or
+// an object with a foreground, a font style and font style flags.
+func applySemanticStyle(style TokenStyle, raw json.RawMessage) TokenStyle {
+	var color string
+	if err := json.Unmarshal(raw, &color); err == nil {
+		style.Foreground = color
+		return style
+	}
+
+	var settings struct {
+		Foreground    string  `json:"foreground"`
+		FontStyle     *string `json:"fontStyle"`
+		Bold          *bool   `json:"bold"`
+		Italic        *bool   `json:"italic"`
+		Underline     *bool   `json:"underline"`
+		Strikethrough *bool   `json:"strikethrough"`
+	}
+	if err := json.Unmarshal(raw, &settings); err != nil {
+		return style
+	}
+
+	if settings.Foreground != "" {
+		style.Foreground = settings.Foreground
+	}
+	if settings.FontStyle != nil {
+		style.FontStyle = *settings.FontStyle
+	}
+
+	fontStyles := strings.Fields(style.FontStyle)
+	for name, enabled := range map[string]*bool{
+		"bold":          settings.Bold,
+		"italic":        settings.Italic,
+		"underline":     settings.Underline,
+		"strikethrough": settings.Strikethrough,
+	} {
+		if enabled == nil {
+			continue
+		}
+		fontStyles = slices.DeleteFunc(fontStyles, func(fontStyle string) bool { return fontStyle == name })
+		if *enabled {
+			fontStyles = append(fontStyles, name)
+		}
+	}
+	style.FontStyle = strings.Join(fontStyles, " ")
+
+	return style
+}
+
+// normalizeFontStyle returns the known font styles in a consistent order.
+func normalizeFontStyle(fontStyle string) string {
+	fontStyles := strings.Fields(fontStyle)
+	normalized := []string{}
+	for _, name := range []string{"italic", "bold", "underline", "strikethrough"} {
+		if slices.Contains(fontStyles, name) {
+			normalized = append(normalized, name)
+		}
+	}
+	return strings.Join(normalized, " ")
+}
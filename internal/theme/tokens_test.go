@@ -0,0 +1,79 @@
+package theme_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/vscodethemes/backend/internal/cli"
+	"github.com/vscodethemes/backend/internal/theme"
+)
+
+func token(text, color string, scopes ...string) cli.Token {
+	var style cli.Style
+	if color != "" {
+		style.Color = &color
+	}
+	return cli.Token{Text: text, Style: style, Scopes: scopes}
+}
+
+func TestTokenStyle(t *testing.T) {
+	fsys := fstest.MapFS{"extension/theme.json": file(`{
+		"name": "Light",
+		"type": "light",
+		"colors": { "editor.background": "#ffffff", "editor.foreground": "#444444" },
+		"semanticHighlighting": true,
+		"semanticTokenColors": {
+			"function": "#0000ff80",
+			"type": { "foreground": "#00ff00", "bold": true }
+		}
+	}`)}
+
+	resolved, err := theme.Load(fsys, contribute("./theme.json", "vs"))
+	if err != nil {
+		t.Fatalf("failed to load theme: %v", err)
+	}
+
+	bold := "bold"
+	typeToken := token("T", "#ff0000", "source.go", "entity.name.type.go")
+	typeToken.Style.FontWeight = &bold
+
+	languages := []cli.LanguageResult{{Tokens: [][]cli.Token{{
+		token(" ", "#ff0000", "source.go", "keyword.control.go"),
+		// Black is a color like any other in a light theme.
+		token("if", "#000000", "source.go", "keyword.control.go"),
+		// Tokens with the default foreground have no color.
+		token("x", "", "source.go", "variable.other.go"),
+		token("f", "#ff0000", "source.go", "entity.name.function.go"),
+		typeToken,
+		// Only the innermost scope is matched.
+		token("1", "#ff0000", "constant.numeric.go", "source.go"),
+	}}}}
+
+	tests := []struct {
+		category string
+		want     theme.TokenStyle
+		ok       bool
+	}{
+		{category: "keyword", want: theme.TokenStyle{Foreground: "#000000"}, ok: true},
+		{category: "variable", want: theme.TokenStyle{Foreground: "#444444"}, ok: true},
+		// Semantic token colors are applied on top, blended over the editor background.
+		{category: "function", want: theme.TokenStyle{Foreground: "#7f7fff"}, ok: true},
+		{category: "type", want: theme.TokenStyle{Foreground: "#00ff00", FontStyle: "bold"}, ok: true},
+		{category: "number"},
+		{category: "tag"},
+	}
+
+	for _, test := range tests {
+		var category theme.TokenCategory
+		for _, c := range theme.TokenCategories {
+			if c.Name == test.category {
+				category = c
+			}
+		}
+
+		got, ok := resolved.TokenStyle(category, languages)
+		if got != test.want || ok != test.ok {
+			t.Errorf("expected %s to be %+v (%t), got %+v (%t)", test.category, test.want, test.ok, got, ok)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/gommon/log"
+	"github.com/riverqueue/river"
+	"github.com/vscodethemes/backend/internal/db"
+	"github.com/vscodethemes/backend/internal/registry"
+)
+
+type BatchUpdateExtensionStatsArgs struct {
+	Registry   string   `json:"registry"`
+	Extensions []string `json:"extensions"`
+}
+
+func (BatchUpdateExtensionStatsArgs) Kind() string {
+	return "batchUpdateExtensionStats"
+}
+
+func (BatchUpdateExtensionStatsArgs) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{
+		Queue:       UpdateExtenstionStatsQueue,
+		MaxAttempts: 5,
+	}
+}
+
+type BatchUpdateExtensionStatsWorker struct {
+	river.WorkerDefaults[BatchUpdateExtensionStatsArgs]
+	Registries registry.Registries
+	DBPool     *pgxpool.Pool
+}
+
+func (w *BatchUpdateExtensionStatsWorker) Timeout(*river.Job[BatchUpdateExtensionStatsArgs]) time.Duration {
+	return 5 * time.Minute
+}
+
+func (w *BatchUpdateExtensionStatsWorker) Work(ctx context.Context, job *river.Job[BatchUpdateExtensionStatsArgs]) error {
+	log.Infof("Updating stats for %d extensions", len(job.Args.Extensions))
+
+	reg, err := w.Registries.Get(job.Args.Registry)
+	if err != nil {
+		return err
+	}
+
+	// Fetch all extensions in the batch from the registry.
+	extensions, err := reg.GetExtensions(ctx, job.Args.Extensions)
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", reg.Name(), err)
+	}
+
+	// Reconcile the extensions that weren't found, they may have been unpublished.
+	found := map[string]bool{}
+	for _, extension := range extensions {
+		found[strings.ToLower(fmt.Sprintf("%s.%s", extension.Publisher.PublisherName, extension.ExtensionName))] = true
+	}
+	missing := []string{}
+	for _, slug := range job.Args.Extensions {
+		if !found[strings.ToLower(slug)] {
+			missing = append(missing, slug)
+		}
+	}
+	if len(missing) > 0 {
+		log.Infof("%d extensions not found in %s", len(missing), reg.Name())
+		if err := insertReconcileExtensions(ctx, reg, missing); err != nil {
+			return err
+		}
+	}
+
+	params := make([]db.UpdateExtensionStatsParams, len(extensions))
+	for i, extension := range extensions {
+		upsertExtensionParams, err := convertUpsertExtensionParams(reg, extension)
+		if err != nil {
+			return fmt.Errorf("failed to convert upsert extension params: %w", err)
+		}
+
+		params[i] = db.UpdateExtensionStatsParams{
+			VscExtensionID:  upsertExtensionParams.VscExtensionID,
+			Installs:        upsertExtensionParams.Installs,
+			TrendingDaily:   upsertExtensionParams.TrendingDaily,
+			TrendingWeekly:  upsertExtensionParams.TrendingWeekly,
+			TrendingMonthly: upsertExtensionParams.TrendingMonthly,
+			WeightedRating:  upsertExtensionParams.WeightedRating,
+		}
+	}
+
+	log.Infof("Saving extension stats to database")
+
+	err = pgx.BeginFunc(ctx, w.DBPool, func(tx pgx.Tx) error {
+		var batchErr error
+		db.New(tx).UpdateExtensionStats(ctx, params).Exec(func(i int, err error) {
+			if err != nil && batchErr == nil {
+				batchErr = fmt.Errorf("failed to update stats for %s: %w", params[i].VscExtensionID, err)
+			}
+		})
+		return batchErr
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update extension stats: %w", err)
+	}
+
+	log.Infof("Extension stats saved to database")
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/labstack/gommon/log"
+	"github.com/riverqueue/river"
+	"github.com/vscodethemes/backend/internal/db"
+	"github.com/vscodethemes/backend/internal/registry"
+)
+
+// Delay before confirming an extension is missing, so that a registry briefly failing to
+// return an extension isn't mistaken for it being unpublished.
+const reconcileExtensionDelay = 1 * time.Hour
+
+type ReconcileExtensionArgs struct {
+	ExtensionName string `json:"extensionName"`
+	PublisherName string `json:"publisherName"`
+	Registry      string `json:"registry"`
+}
+
+func (ReconcileExtensionArgs) Kind() string {
+	return "reconcileExtension"
+}
+
+func (ReconcileExtensionArgs) InsertOpts() river.InsertOpts {
+	return river.InsertOpts{
+		Queue:       ReconcileExtensionQueue,
+		MaxAttempts: 5,
+		// Extensions can go missing from several jobs at once, only reconcile them once.
+		UniqueOpts: river.UniqueOpts{
+			ByArgs:   true,
+			ByPeriod: reconcileExtensionDelay,
+		},
+	}
+}
+
+type ReconcileExtensionWorker struct {
+	river.WorkerDefaults[ReconcileExtensionArgs]
+	Registries registry.Registries
+	DBPool     *pgxpool.Pool
+}
+
+func (w *ReconcileExtensionWorker) Timeout(*river.Job[ReconcileExtensionArgs]) time.Duration {
+	return 1 * time.Minute
+}
+
+func (w *ReconcileExtensionWorker) Work(ctx context.Context, job *river.Job[ReconcileExtensionArgs]) error {
+	extensionSlug := fmt.Sprintf("%s.%s", job.Args.PublisherName, job.Args.ExtensionName)
+	log.Infof("Reconciling extension: %s", extensionSlug)
+
+	reg, err := w.Registries.Get(job.Args.Registry)
+	if err != nil {
+		return err
+	}
+
+	queries := db.New(w.DBPool)
+
+	_, err = reg.GetExtension(ctx, job.Args.PublisherName, job.Args.ExtensionName)
+	if err == nil {
+		// The extension is back, make sure it's searchable again.
+		restored, err := queries.RestoreExtension(ctx, db.RestoreExtensionParams{
+			ExtensionName: job.Args.ExtensionName,
+			PublisherName: job.Args.PublisherName,
+			Registry:      reg.Name(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to restore extension: %w", err)
+		}
+		if restored > 0 {
+			log.Infof("Extension found in %s, restored", reg.Name())
+		} else {
+			log.Infof("Extension found in %s, nothing to reconcile", reg.Name())
+		}
+		return nil
+	}
+	if !errors.Is(err, registry.ErrNotFound) {
+		return fmt.Errorf("failed to query %s: %w", reg.Name(), err)
+	}
+
+	removed, err := queries.MarkExtensionRemoved(ctx, db.MarkExtensionRemovedParams{
+		ExtensionName: job.Args.ExtensionName,
+		PublisherName: job.Args.PublisherName,
+		Registry:      reg.Name(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark extension removed: %w", err)
+	}
+
+	if removed > 0 {
+		log.Infof("Extension not found in %s, marked removed", reg.Name())
+	} else {
+		log.Infof("Extension not found in %s, already removed or never indexed", reg.Name())
+	}
+
+	return nil
+}
+
+// cancelMissingExtension schedules the extension to be reconciled and cancels the current job,
+// retrying can't bring back an extension that was unpublished.
+func cancelMissingExtension(ctx context.Context, reg registry.Registry, publisherName, extensionName string) error {
+	if err := insertReconcileExtensions(ctx, reg, []string{fmt.Sprintf("%s.%s", publisherName, extensionName)}); err != nil {
+		return err
+	}
+
+	return river.JobCancel(fmt.Errorf("extension not found in %s: %w", reg.Name(), registry.ErrNotFound))
+}
+
+// insertReconcileExtensions schedules reconciliation for each "publisher.extension" slug. Jobs
+// are inserted one at a time, InsertMany doesn't respect unique options.
+func insertReconcileExtensions(ctx context.Context, reg registry.Registry, slugs []string) error {
+	client, err := river.ClientFromContextSafely[pgx.Tx](ctx)
+	if err != nil {
+		return fmt.Errorf("error getting client from context: %w", err)
+	}
+
+	opts := ReconcileExtensionArgs{}.InsertOpts()
+	opts.ScheduledAt = time.Now().Add(reconcileExtensionDelay)
+
+	for _, slug := range slugs {
+		publisherName, extensionName, ok := strings.Cut(slug, ".")
+		if !ok {
+			return fmt.Errorf("invalid extension slug: %s", slug)
+		}
+
+		_, err := client.Insert(ctx, ReconcileExtensionArgs{
+			ExtensionName: extensionName,
+			PublisherName: publisherName,
+			Registry:      reg.Name(),
+		}, &opts)
+		if err != nil {
+			return fmt.Errorf("failed to insert reconcile job: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -2,7 +2,9 @@ package workers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -12,6 +14,7 @@ import (
 	"github.com/vscodethemes/backend/internal/db"
 	"github.com/vscodethemes/backend/internal/marketplace"
 	"github.com/vscodethemes/backend/internal/marketplace/qo"
+	"github.com/vscodethemes/backend/internal/registry"
 )
 
 type ScanPriority string
@@ -29,6 +32,9 @@ type ScanExtensionsArgs struct {
 	BatchSize                int                     `json:"batchSize"`
 	StopAtEqualPublishedDate bool                    `json:"stopAtEqualPublishedDate"`
 	Force                    bool                    `json:"force"`
+	Registry                 string                  `json:"registry"`
+	// IncludePreReleases also syncs each extension's pre-release version, if it has one.
+	IncludePreReleases bool `json:"includePreReleases"`
 }
 
 func (ScanExtensionsArgs) Kind() string {
@@ -44,8 +50,8 @@ func (ScanExtensionsArgs) InsertOpts() river.InsertOpts {
 
 type ScanExtensionsWorker struct {
 	river.WorkerDefaults[ScanExtensionsArgs]
-	Marketplace *marketplace.Client
-	DBPool      *pgxpool.Pool
+	Registries registry.Registries
+	DBPool     *pgxpool.Pool
 }
 
 func (w *ScanExtensionsWorker) Timeout(*river.Job[ScanExtensionsArgs]) time.Duration {
@@ -68,86 +74,152 @@ func (w *ScanExtensionsWorker) Work(ctx context.Context, job *river.Job[ScanExte
 		batchSize = job.Args.BatchSize
 	}
 
+	registries := w.Registries.Ordered()
+	if job.Args.Registry != "" {
+		reg, err := w.Registries.Get(job.Args.Registry)
+		if err != nil {
+			return err
+		}
+		registries = []registry.Registry{reg}
+	}
+
+	for _, reg := range registries {
+		err := w.scanRegistry(ctx, client, reg, job.Args, insertQueue, batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", reg.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+func (w *ScanExtensionsWorker) scanRegistry(ctx context.Context, client *river.Client[pgx.Tx], reg registry.Registry, args ScanExtensionsArgs, insertQueue string, batchSize int) error {
+	log.Infof("Scanning %s", reg.Name())
+
 	queries := db.New(w.DBPool)
 
 	extensionsScanned := 0
-	pageNumber := 1
-	stopScanning := false
-	for !stopScanning {
-		log.Infof("Scanning page %d", pageNumber)
-
-		// Add a delay to avoid rate limiting from the martketplace API.
-		time.Sleep(2 * time.Second)
-
-		queryResults, err := w.Marketplace.NewQuery(ctx,
-			qo.WithSortBy(job.Args.SortBy),
-			qo.WithDirection(job.Args.SortDirection),
-			qo.WithCriteria(qo.FilterTypeCategory, "Themes"),
-			qo.WithCriteria(qo.FilterTypeUnknown8, "Microsoft.VisualStudio.Code"),
-			qo.WithCriteria(qo.FilterTypeUnknown10, "target:\"Microsoft.VisualStudio.Code\" "),
-			qo.WithCriteria(qo.FilterTypeUnknown12, "37888"),
-			qo.WithPageNumber(pageNumber),
-			qo.WithPageSize(batchSize),
-		)
+	batch := []river.InsertManyParams{}
+
+	insertBatch := func() error {
+		if len(batch) > 0 {
+			if _, err := client.InsertMany(ctx, batch); err != nil {
+				return fmt.Errorf("failed to insert job: %w", err)
+			}
+		}
+
+		log.Infof("Scanned %d extensions in batch, %d total", len(batch), extensionsScanned)
+		batch = []river.InsertManyParams{}
+
+		return nil
+	}
+
+	themes := reg.ScanThemes(ctx, registry.ThemesQuery{
+		PageSize:  batchSize,
+		SortBy:    args.SortBy,
+		Direction: args.SortDirection,
+	})
+
+	for extension, err := range themes {
 		if err != nil {
-			return fmt.Errorf("failed to query marketplace: %w", err)
+			return fmt.Errorf("failed to query registry: %w", err)
 		}
 
-		if len(queryResults) == 0 {
-			log.Infof("No more extensions found, stopping scan")
+		if extensionsScanned >= args.MaxExtensions {
+			log.Infof("Reached max extensions, stopping scan")
 			break
 		}
 
-		pageNumber++
-
-		batch := []river.InsertManyParams{}
-		for _, extension := range queryResults {
-			if extensionsScanned >= job.Args.MaxExtensions {
-				log.Infof("Reached max extensions, stopping scan")
-				stopScanning = true
-				break
+		// If the job is configured to stop at the first extension that's already synced, check
+		// if the extension is up to date and stop scanning if it is. This is useful when sorting
+		// by last updated data.
+		if args.StopAtEqualPublishedDate {
+			isUpToDate, err := isLatestVersionSynced(ctx, queries, extension)
+			if err != nil {
+				return fmt.Errorf("failed to check if extension is up to date: %w", err)
 			}
 
-			// If the job is configured to stop at the first extension with the same published date,
-			// check if the extension is up to date and stop scanning if it is. This is useful when
-			// sorting by last updated data.
-			if job.Args.StopAtEqualPublishedDate {
-				isUpToDate, err := isExtensionUpToDate(ctx, queries, extension)
-				if err != nil {
-					return fmt.Errorf("failed to check if extension is up to date: %w", err)
-				}
-
-				if isUpToDate {
-					log.Infof("Extension %s.%s is up to date, stopping scan", extension.Publisher.PublisherName, extension.ExtensionName)
-					stopScanning = true
-					break
-				}
+			if isUpToDate {
+				log.Infof("Extension %s.%s is up to date, stopping scan", extension.Publisher.PublisherName, extension.ExtensionName)
+				break
 			}
+		}
 
-			log.Debugf("Adding extension to batch: %s.%s", extension.Publisher.PublisherName, extension.ExtensionName)
-
+		log.Debugf("Adding extension to batch: %s.%s", extension.Publisher.PublisherName, extension.ExtensionName)
+
+		batch = append(batch, river.InsertManyParams{
+			Args: SyncExtensionArgs{
+				PublisherName: extension.Publisher.PublisherName,
+				ExtensionName: extension.ExtensionName,
+				Registry:      reg.Name(),
+				Force:         args.Force,
+			},
+			InsertOpts: &river.InsertOpts{
+				Queue: insertQueue,
+			},
+		})
+
+		if args.IncludePreReleases {
 			batch = append(batch, river.InsertManyParams{
 				Args: SyncExtensionArgs{
 					PublisherName: extension.Publisher.PublisherName,
 					ExtensionName: extension.ExtensionName,
-					Force:         job.Args.Force,
+					Registry:      reg.Name(),
+					Force:         args.Force,
+					PreRelease:    true,
 				},
 				InsertOpts: &river.InsertOpts{
 					Queue: insertQueue,
 				},
 			})
-
-			extensionsScanned++
 		}
 
-		if len(batch) > 0 {
-			if _, err = client.InsertMany(ctx, batch); err != nil {
-				return fmt.Errorf("failed to insert job: %w", err)
+		extensionsScanned++
+
+		if len(batch) >= batchSize {
+			if err := insertBatch(); err != nil {
+				return err
 			}
 		}
+	}
 
-		log.Infof("Scanned %d extensions in batch, %d total", len(batch), extensionsScanned)
+	return insertBatch()
+}
+
+// isLatestVersionSynced returns true if the latest version in the scan result was last synced
+// as either the stable or the pre-release version. Scan results only include the latest version
+// without its properties, so the release channel can't be told reliably from the result.
+func isLatestVersionSynced(ctx context.Context, queries *db.Queries, extension marketplace.ExtensionResult) (bool, error) {
+	if len(extension.Versions) == 0 {
+		return false, nil
 	}
 
-	return nil
+	latest := slices.MaxFunc(extension.Versions, func(a, b marketplace.ExtensionVersionResult) int {
+		return a.LastUpdated.Compare(b.LastUpdated)
+	})
+
+	for _, preRelease := range []bool{false, true} {
+		syncedVersion, err := queries.GetExtensionSyncedVersion(ctx, db.GetExtensionSyncedVersionParams{
+			PreRelease:    preRelease,
+			ExtensionName: extension.ExtensionName,
+			PublisherName: extension.Publisher.PublisherName,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to get synced version: %w", err)
+		}
+
+		// Extensions that reappear after being removed are synced again to restore them.
+		if syncedVersion.RemovedAt.Valid {
+			return false, nil
+		}
+
+		if syncedVersion.Version == latest.Version {
+			return true, nil
+		}
+	}
+
+	return false, nil
 }
@@ -1,20 +1,25 @@
 package workers
 
 import (
+	"cmp"
 	"context"
-	"encoding/base64"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"math/big"
+	"io"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gosimple/slug"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/labstack/gommon/log"
 	"github.com/riverqueue/river"
@@ -22,15 +27,32 @@ import (
 	"github.com/vscodethemes/backend/internal/colors"
 	"github.com/vscodethemes/backend/internal/db"
 	"github.com/vscodethemes/backend/internal/downloader"
+	"github.com/vscodethemes/backend/internal/imaging"
+	"github.com/vscodethemes/backend/internal/manifest"
 	"github.com/vscodethemes/backend/internal/marketplace"
-	"github.com/vscodethemes/backend/internal/marketplace/qo"
+	"github.com/vscodethemes/backend/internal/objectstore"
+	"github.com/vscodethemes/backend/internal/packagestore"
+	"github.com/vscodethemes/backend/internal/registry"
+	"github.com/vscodethemes/backend/internal/signature"
+	"github.com/vscodethemes/backend/internal/theme"
 	"golang.org/x/sync/errgroup"
 )
 
 type SyncExtensionArgs struct {
 	ExtensionName string `json:"extensionName"`
 	PublisherName string `json:"publisherName"`
+	Registry      string `json:"registry"`
 	Force         bool   `json:"force"`
+	// PreRelease indexes the latest pre-release version instead of the stable version. Its
+	// themes are saved separately and don't replace the stable themes.
+	PreRelease bool `json:"preRelease"`
+}
+
+// SyncExtensionOutput is recorded under "output" in the metadata of jobs that saved an extension.
+type SyncExtensionOutput struct {
+	Version           string `json:"version"`
+	Themes            int    `json:"themes"`
+	TmThemesConverted int    `json:"tmThemesConverted"`
 }
 
 func (SyncExtensionArgs) Kind() string {
@@ -44,15 +66,70 @@ func (SyncExtensionArgs) InsertOpts() river.InsertOpts {
 	}
 }
 
+// maxSignatureSize is far larger than any signature archive.
+const maxSignatureSize = 1 << 20
+
+// DefaultImageWidths are the widths of the thumbnails of previews, which are rendered 800px wide.
+var DefaultImageWidths = []int{200, 400}
+
+// DefaultColorSlots are the colors themes can be searched by, the colors of the parts of the
+// workbench shown in previews.
+var DefaultColorSlots = []string{
+	"editor.background",
+	"editor.foreground",
+	"activityBar.background",
+	"activityBar.foreground",
+	"activityBar.inactiveForeground",
+	"activityBar.border",
+	"activityBar.activeBorder",
+	"activityBar.activeBackground",
+	"activityBarBadge.background",
+	"activityBarBadge.foreground",
+	"editorGroupHeader.tabsBackground",
+	"editorGroupHeader.tabsBorder",
+	"statusBar.background",
+	"statusBar.foreground",
+	"statusBar.border",
+	"tab.activeBackground",
+	"tab.inactiveBackground",
+	"tab.activeForeground",
+	"tab.border",
+	"tab.activeBorder",
+	"tab.activeBorderTop",
+	"titleBar.activeBackground",
+	"titleBar.activeForeground",
+	"titleBar.border",
+}
+
 type SyncExtensionWorker struct {
 	river.WorkerDefaults[SyncExtensionArgs]
-	Marketplace       *marketplace.Client
-	Directory         string
-	DisableCleanup    bool
-	ObjectStoreClient *s3.Client
-	ObjectStoreBucket string
-	CDNBaseUrl        string
-	DBPool            *pgxpool.Pool
+	Registries     registry.Registries
+	Directory      string
+	DisableCleanup bool
+	// ObjectStore stores the images and gives their public URLs.
+	ObjectStore    objectstore.Store
+	DBPool         *pgxpool.Pool
+	MaxPackageSize int64
+	// PackageBufferDir is where downloaded packages are buffered, they're buffered in memory if
+	// it's empty.
+	PackageBufferDir string
+	// PackageStore keeps downloaded packages so they aren't downloaded again, nil to disable.
+	PackageStore *packagestore.Store
+	// ArchivePackages keeps the packages of every synced version instead of only the current ones.
+	ArchivePackages bool
+	// SignatureVerifier verifies package signatures, nil to skip verification.
+	SignatureVerifier *signature.Verifier
+	// RefuseInvalidSignatures cancels the sync of packages with an invalid signature instead of
+	// indexing them with an invalid signature status.
+	RefuseInvalidSignatures bool
+	// Renderer generates images in a long-lived renderer process, nil to run the CLI for each
+	// theme instead.
+	Renderer *cli.Renderer
+	// ColorSlots are the colors saved for search, DefaultColorSlots if empty.
+	ColorSlots []string
+	// ImageWidths are the widths of the raster previews smaller than the rendered png,
+	// DefaultImageWidths if nil.
+	ImageWidths []int
 }
 
 func (w *SyncExtensionWorker) Timeout(*river.Job[SyncExtensionArgs]) time.Duration {
@@ -63,22 +140,47 @@ func (w *SyncExtensionWorker) Work(ctx context.Context, job *river.Job[SyncExten
 	extensionSlug := fmt.Sprintf("%s.%s", job.Args.PublisherName, job.Args.ExtensionName)
 	log.Infof("Syncing extension package: %s", extensionSlug)
 
-	// Add a delay to avoid rate limiting from the martketplace API.
-	time.Sleep(2 * time.Second)
-
-	// Fetch extension from the marketplace API.
-	queryResults, err := w.Marketplace.NewQuery(ctx, qo.WithSlug(extensionSlug))
+	reg, err := w.Registries.Get(job.Args.Registry)
 	if err != nil {
-		return fmt.Errorf("failed to query marketplace: %w", err)
+		return err
+	}
+
+	// Extensions published to more than one registry are only indexed from the first
+	// registry they were synced from.
+	queries := db.New(w.DBPool)
+	savedRegistry, err := queries.GetExtensionRegistry(ctx, db.GetExtensionRegistryParams{
+		ExtensionName: job.Args.ExtensionName,
+		PublisherName: job.Args.PublisherName,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to get extension registry: %w", err)
+	}
+	if err == nil && savedRegistry != reg.Name() {
+		log.Infof("Extension is indexed from %s, skipping", savedRegistry)
+		return nil
 	}
 
-	if len(queryResults) == 0 {
-		return fmt.Errorf("extension not found")
+	// Fetch extension from the registry.
+	extension, err := reg.GetExtension(ctx, job.Args.PublisherName, job.Args.ExtensionName)
+	if errors.Is(err, registry.ErrNotFound) {
+		return cancelMissingExtension(ctx, reg, job.Args.PublisherName, job.Args.ExtensionName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", reg.Name(), err)
 	}
 
-	extension := queryResults[0]
+	// Pick the version to index.
+	version := extension.SelectVersion(job.Args.PreRelease)
+	if version == nil && job.Args.PreRelease {
+		log.Infof("Extension has no pre-release version, skipping")
+		return nil
+	}
+	if version == nil {
+		return river.JobCancel(fmt.Errorf("extension has no stable version"))
+	}
+	log.Infof("Selected version %s (pre-release: %t, platform: %s)", version.Version, version.IsPreRelease(), cmp.Or(version.TargetPlatform, marketplace.TargetPlatformUniversal))
 
-	isUpToDate, err := isExtensionUpToDate(ctx, db.New(w.DBPool), extension)
+	isUpToDate, err := isExtensionUpToDate(ctx, queries, *extension, job.Args.PreRelease)
 	if err != nil {
 		return fmt.Errorf("failed to check if extension is up to date: %w", err)
 	}
@@ -88,18 +190,56 @@ func (w *SyncExtensionWorker) Work(ctx context.Context, job *river.Job[SyncExten
 		return nil
 	}
 
-	upsertExtensionParams, err := convertUpsertExtensionParams(extension)
+	upsertExtensionParams, err := convertUpsertExtensionParams(reg, *extension)
 	if err != nil {
 		return fmt.Errorf("failed to convert upsert extension params: %w", err)
 	}
 
-	// Ensure there's a package URL for the extension.
-	packageUrl := extension.GetPackageURL()
+	// Ensure there's a package URL for the version.
+	packageUrl := reg.GetPackageURL(*version)
 	if packageUrl == "" {
 		return fmt.Errorf("extension package not found")
 	}
 
-	// Create a directory for the job to download the package.
+	// Download the extension package. It's read without extracting it.
+	d := downloader.New(downloader.WithMaxSize(w.MaxPackageSize), downloader.WithTempDir(w.PackageBufferDir))
+
+	packageKey := packagestore.Key{ExtensionID: extension.ExtensionID, Version: version.Version}
+	pkg, err := w.fetchPackage(ctx, queries, d, *extension, packageKey, packageUrl, job.Args.PreRelease)
+	if downloader.IsPermanent(err) {
+		return river.JobCancel(fmt.Errorf("failed to download package: %w", err))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download package: %w", err)
+	}
+	defer pkg.Close()
+
+	var signatureStatus pgtype.Text
+	if w.SignatureVerifier != nil {
+		status, err := w.verifySignature(ctx, reg, *version, pkg)
+		if err != nil {
+			return fmt.Errorf("failed to verify package signature: %w", err)
+		}
+		log.Infof("Package signature: %s", status)
+
+		if status == signature.StatusInvalid && w.RefuseInvalidSignatures {
+			return river.JobCancel(fmt.Errorf("package signature is invalid"))
+		}
+
+		signatureStatus = db.Text((*string)(&status))
+	}
+
+	log.Infof("Reading extension info")
+	info, err := manifest.GetInfo(pkg)
+	if errors.Is(err, manifest.ErrInvalid) || downloader.IsPermanent(err) {
+		return river.JobCancel(fmt.Errorf("failed to get info: %w", err))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get info: %w", err)
+	}
+
+	// The renderer reads themes from disk and writes images to disk, create a directory for the
+	// job with only the files it needs.
 	jobDir := path.Join(w.Directory, "jobs", fmt.Sprintf("%d", job.ID))
 	err = os.MkdirAll(jobDir, os.ModePerm)
 	if err != nil {
@@ -112,30 +252,18 @@ func (w *SyncExtensionWorker) Work(ctx context.Context, job *river.Job[SyncExten
 		}()
 	}
 
-	// Download the extension package.
-	d := downloader.New(jobDir, extensionSlug)
-
-	log.Infof("Downloading package: %s", packageUrl)
-	err = d.Download(ctx, packageUrl)
-	if err != nil {
-		return fmt.Errorf("failed to download package: %w", err)
-	}
-
-	log.Infof("Extracting package: %s", d.PackagePath)
-	err = d.Extract()
-	if err != nil {
-		return fmt.Errorf("failed to extract package: %w", err)
-	}
-
-	extensionPath, err := filepath.Abs(d.ExtractDir)
+	extensionPath, err := filepath.Abs(path.Join(jobDir, extensionSlug))
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path for extension: %w", err)
 	}
 
-	log.Infof("Reading extension info: %s", extensionPath)
-	info, err := cli.GetInfo(ctx, extensionPath)
+	log.Infof("Extracting themes: %s", extensionPath)
+	err = pkg.ExtractThemes(extensionPath)
+	if downloader.IsPermanent(err) {
+		return river.JobCancel(fmt.Errorf("failed to extract package: %w", err))
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get info: %w", err)
+		return fmt.Errorf("failed to extract package: %w", err)
 	}
 
 	imagesPath, err := filepath.Abs(path.Join(jobDir, "images"))
@@ -144,19 +272,52 @@ func (w *SyncExtensionWorker) Work(ctx context.Context, job *river.Job[SyncExten
 	}
 
 	// Generate images for each theme concurrency, up to a max of 10 subroutines.
-	imagesResults := []cli.GenerateImagesResult{}
+	generateImages := cli.GenerateImages
+	if w.Renderer != nil {
+		generateImages = w.Renderer.GenerateImages
+	}
+
+	imagesResults := []themeImagesResult{}
+	var imagesResultsMu sync.Mutex
+	var tmThemesConverted atomic.Int64
 	group, imagesCtx := errgroup.WithContext(ctx)
 	group.SetLimit(10)
 	for _, themeContribute := range info.ThemeContributes {
 		group.Go(func() error {
-			// Skip if theme path is not a json file.
-			if filepath.Ext(themeContribute.Path) != ".json" {
+			if !theme.IsTMTheme(themeContribute.Path) && filepath.Ext(themeContribute.Path) != ".json" {
 				log.Infof("Skipping theme: %s", themeContribute.Path)
 				return nil
 			}
 
+			// Resolve the theme for the colors it defines, the image generator only returns the
+			// colors it uses.
+			resolved, err := theme.Load(pkg, themeContribute)
+			if errors.Is(err, theme.ErrInvalid) {
+				log.Infof("Skipping invalid theme: %s", err)
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load theme %s: %w", themeContribute.Path, err)
+			}
+			if key, ok := missingLegacyColor(resolved); ok {
+				log.Infof("Skipping theme without %s color: %s", key, themeContribute.Path)
+				return nil
+			}
+
+			renderContribute := themeContribute
+			if theme.IsTMTheme(themeContribute.Path) {
+				// The image generator only reads json themes.
+				log.Infof("Converting tmTheme: %s", themeContribute.Path)
+				converted, err := convertTMTheme(extensionPath, themeContribute, resolved)
+				if err != nil {
+					return fmt.Errorf("failed to convert tmTheme %s: %w", themeContribute.Path, err)
+				}
+				renderContribute = converted
+				tmThemesConverted.Add(1)
+			}
+
 			log.Infof("Generating images for theme: %s", themeContribute.Path)
-			result, err := cli.GenerateImages(imagesCtx, extensionPath, themeContribute, imagesPath)
+			result, err := generateImages(imagesCtx, extensionPath, renderContribute, imagesPath)
 			if err != nil {
 				return fmt.Errorf("failed to generate images for %s: %w", themeContribute.Path, err)
 			}
@@ -164,14 +325,22 @@ func (w *SyncExtensionWorker) Work(ctx context.Context, job *river.Job[SyncExten
 			if result != nil {
 				// Override the absolute path with the relative path, which we use later to save to the database.
 				result.Theme.Path = themeContribute.Path
-				imagesResults = append(imagesResults, *result)
+
+				imagesResultsMu.Lock()
+				imagesResults = append(imagesResults, themeImagesResult{GenerateImagesResult: *result, resolved: resolved})
+				imagesResultsMu.Unlock()
 			}
 
 			return nil
 		})
 	}
 
-	if err := group.Wait(); err != nil {
+	// Themes the CLI can't read won't be readable on retry either, unlike crashes and timeouts.
+	err = group.Wait()
+	if cli.IsPermanent(err) {
+		return river.JobCancel(err)
+	}
+	if err != nil {
 		return err
 	}
 
@@ -184,65 +353,113 @@ func (w *SyncExtensionWorker) Work(ctx context.Context, job *river.Job[SyncExten
 	group, uploadCtx := errgroup.WithContext(ctx)
 	group.SetLimit(10)
 
-	// Generate a cache bust ID based on the job ID.
-	cacheBustId := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(job.ID)).Bytes())
+	colorSlots := w.ColorSlots
+	if len(colorSlots) == 0 {
+		colorSlots = DefaultColorSlots
+	}
+
+	imageWidths := w.ImageWidths
+	if imageWidths == nil {
+		imageWidths = DefaultImageWidths
+	}
 
 	slugGenerator := makeThemeSlugGenerator()
 	upsertThemeWithImagesParams := make([]UpsertThemeWithImagesParams, len(imagesResults))
 	for themeIndex, result := range imagesResults {
 		themeSlug := slugGenerator(result.Theme.DisplayName)
 
-		upsertThemeParams, err := convertUpsertThemeParams(themeSlug, result.Theme)
+		// The theme's colors won't change on retry.
+		upsertThemeParams, err := convertUpsertThemeParams(themeSlug, result.Theme, result.resolved)
+		if err != nil {
+			return river.JobCancel(fmt.Errorf("failed to convert upsert theme params: %w", err))
+		}
+		upsertThemeParams.PreRelease = job.Args.PreRelease
+
+		upsertThemeParams.TokenColors, err = json.Marshal(result.resolved.TokenColors)
+		if err != nil {
+			return fmt.Errorf("failed to marshal theme token colors: %w", err)
+		}
+		upsertThemeParams.SemanticTokenColors, err = json.Marshal(result.resolved.SemanticTokenColors)
 		if err != nil {
-			return fmt.Errorf("failed to convert upsert theme params: %w", err)
+			return fmt.Errorf("failed to marshal theme semantic token colors: %w", err)
+		}
+
+		themeColorSlots, err := convertThemeColorSlots(result.resolved, colorSlots)
+		if err != nil {
+			return fmt.Errorf("failed to convert theme color slots: %w", err)
+		}
+
+		tokenColors, err := convertThemeTokenColors(result.resolved, result.Languages)
+		if err != nil {
+			return fmt.Errorf("failed to convert theme token colors: %w", err)
 		}
 
 		upsertThemeWithImagesParams[themeIndex] = UpsertThemeWithImagesParams{
-			Theme:  upsertThemeParams,
-			Images: make([]db.UpsertImageParams, len(result.Languages)),
+			Theme:       upsertThemeParams,
+			ColorSlots:  themeColorSlots,
+			TokenColors: tokenColors,
 		}
 
 		group.Go(func() error {
 			log.Infof("Uploading images for theme: %s", result.Theme.Path)
 
-			for languageIndex, language := range result.Languages {
-				file, err := os.Open(language.SvgPath)
-				if err != nil {
-					return fmt.Errorf("failed to open file: %w", err)
-				}
-				defer file.Close()
-
+			images := []db.UpsertImageParams{}
+			for _, language := range result.Languages {
 				imageType := "preview"
 				imageFormat := "svg"
-				svgFileName := fmt.Sprintf("%s-%s-%s-%s.%s", themeSlug, language.Language.ExtName, imageType, cacheBustId, imageFormat)
-				svgObjectKey := fmt.Sprintf("%s/%s", extensionSlug, svgFileName)
+				svgKeyPrefix := fmt.Sprintf("%s/%s-%s-%s", extensionSlug, themeSlug, language.Language.ExtName, imageType)
 
-				log.Debugf("Uploading SVG image at %s to %s", language.SvgPath, svgObjectKey)
+				log.Debugf("Uploading SVG image at %s", language.SvgPath)
 
-				_, err = w.ObjectStoreClient.PutObject(uploadCtx, &s3.PutObjectInput{
-					Bucket:       aws.String(w.ObjectStoreBucket),
-					Key:          aws.String(svgObjectKey),
-					Body:         file,
-					ContentType:  aws.String("image/svg+xml"),
-					CacheControl: aws.String("public, max-age=31536000"),
-				})
+				svgImage, err := w.uploadImage(uploadCtx, language.SvgPath, svgKeyPrefix, imageFormat, "image/svg+xml")
 				if err != nil {
-					return fmt.Errorf("failed to upload svg file to %s: %w", svgObjectKey, err)
+					return fmt.Errorf("failed to upload svg file %s: %w", language.SvgPath, err)
 				}
 
-				svgImageUrl := fmt.Sprintf("%s/%s", w.CDNBaseUrl, svgObjectKey)
-				log.Debugf("SVG image uploaded: %s", svgImageUrl)
+				log.Debugf("SVG image uploaded: %s", svgImage.url)
 
-				upsertImageParams := db.UpsertImageParams{
+				images = append(images, db.UpsertImageParams{
 					Language: language.Language.ExtName,
 					Type:     imageType,
 					Format:   imageFormat,
-					Url:      svgImageUrl,
+					Url:      svgImage.url,
+					Sha256:   db.Text(&svgImage.sha256),
+				})
+
+				if language.PngPath == "" {
+					continue
 				}
 
-				upsertThemeWithImagesParams[themeIndex].Images[languageIndex] = upsertImageParams
+				// Upload the png and its smaller sizes as png and webp, for clients that can't
+				// display svg.
+				renditions, err := imaging.Renditions(language.PngPath, imageWidths)
+				if err != nil {
+					return fmt.Errorf("failed to create renditions of %s: %w", language.PngPath, err)
+				}
+
+				for _, rendition := range renditions {
+					keyPrefix := fmt.Sprintf("%s/%s-%s-%s-%d", extensionSlug, themeSlug, language.Language.ExtName, imageType, rendition.Width)
+
+					log.Debugf("Uploading %s image at %s", rendition.Format, rendition.Path)
+
+					image, err := w.uploadImage(uploadCtx, rendition.Path, keyPrefix, string(rendition.Format), rendition.Format.ContentType())
+					if err != nil {
+						return fmt.Errorf("failed to upload %s file %s: %w", rendition.Format, rendition.Path, err)
+					}
+
+					images = append(images, db.UpsertImageParams{
+						Language: language.Language.ExtName,
+						Type:     imageType,
+						Format:   string(rendition.Format),
+						Width:    int32(rendition.Width),
+						Url:      image.url,
+						Sha256:   db.Text(&image.sha256),
+					})
+				}
 			}
 
+			upsertThemeWithImagesParams[themeIndex].Images = images
+
 			return nil
 		})
 	}
@@ -251,20 +468,239 @@ func (w *SyncExtensionWorker) Work(ctx context.Context, job *river.Job[SyncExten
 		return err
 	}
 
+	upsertExtensionVersionParams := db.UpsertExtensionVersionParams{
+		Version:         version.Version,
+		PreRelease:      job.Args.PreRelease,
+		TargetPlatform:  db.Text(&version.TargetPlatform),
+		AssetUri:        db.Text(&packageUrl),
+		PackageHash:     db.Text(&d.SHA256),
+		SignatureStatus: signatureStatus,
+	}
+	if version.AssetURI != "" {
+		upsertExtensionVersionParams.AssetUri = db.Text(&version.AssetURI)
+	}
+
 	log.Infof("Saving extension to database")
-	if err = saveExtension(ctx, w.DBPool, upsertExtensionParams, upsertThemeWithImagesParams); err != nil {
+	if err = saveExtension(ctx, w.DBPool, upsertExtensionParams, upsertExtensionVersionParams, upsertThemeWithImagesParams); err != nil {
 		return fmt.Errorf("failed to save extension to database: %w", err)
 	}
-	log.Infof("Extension saved to database")
+	log.Infof("Extension saved to database, %d themes (%d converted from tmTheme)", len(upsertThemeWithImagesParams), tmThemesConverted.Load())
+
+	output, err := json.Marshal(SyncExtensionOutput{
+		Version:           version.Version,
+		Themes:            len(upsertThemeWithImagesParams),
+		TmThemesConverted: int(tmThemesConverted.Load()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal job output: %w", err)
+	}
+
+	if err := queries.SetJobOutput(ctx, db.SetJobOutputParams{ID: job.ID, Output: output}); err != nil {
+		return fmt.Errorf("failed to set job output: %w", err)
+	}
 
 	return nil
 }
 
-func isExtensionUpToDate(ctx context.Context, queries *db.Queries, extension marketplace.ExtensionResult) (bool, error) {
-	savedExtension, err := queries.GetExtension(ctx, db.GetExtensionParams{
+type uploadedImage struct {
+	url    string
+	sha256 string
+}
+
+// uploadImage uploads an image file to the object store under the key prefix followed by the
+// SHA-256 of the file. Images with the same content have the same key, so they're immutable and
+// aren't uploaded again if they already exist.
+func (w *SyncExtensionWorker) uploadImage(ctx context.Context, filePath string, keyPrefix string, ext string, contentType string) (uploadedImage, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return uploadedImage{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	checksum := sha256.New()
+	if _, err := io.Copy(checksum, file); err != nil {
+		return uploadedImage{}, fmt.Errorf("failed to hash file: %w", err)
+	}
+	image := uploadedImage{sha256: hex.EncodeToString(checksum.Sum(nil))}
+
+	objectKey := fmt.Sprintf("%s-%s.%s", keyPrefix, image.sha256, ext)
+	image.url = w.ObjectStore.URL(objectKey)
+
+	exists, err := w.ObjectStore.Exists(ctx, objectKey)
+	if err != nil {
+		return uploadedImage{}, err
+	}
+	if exists {
+		log.Debugf("Image already uploaded to %s", objectKey)
+		return image, nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return uploadedImage{}, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	err = w.ObjectStore.Put(ctx, objectKey, file, objectstore.PutOptions{
+		ContentType:  contentType,
+		CacheControl: "public, max-age=31536000, immutable",
+	})
+	if err != nil {
+		return uploadedImage{}, err
+	}
+
+	return image, nil
+}
+
+// convertTMTheme writes a resolved TextMate theme contribution as a json theme next to it and
+// returns the contribution of the json theme.
+func convertTMTheme(extensionPath string, themeContribute cli.ThemeContribute, t *theme.Theme) (cli.ThemeContribute, error) {
+	data, err := t.MarshalSource()
+	if err != nil {
+		return cli.ThemeContribute{}, fmt.Errorf("failed to marshal theme: %w", err)
+	}
+
+	converted := themeContribute
+	converted.Path = path.Clean(themeContribute.Path) + ".json"
+
+	convertedPath := filepath.Join(extensionPath, "extension", filepath.FromSlash(converted.Path))
+	if err := os.MkdirAll(filepath.Dir(convertedPath), os.ModePerm); err != nil {
+		return cli.ThemeContribute{}, fmt.Errorf("failed to create theme dir: %w", err)
+	}
+	if err := os.WriteFile(convertedPath, data, 0o644); err != nil {
+		return cli.ThemeContribute{}, fmt.Errorf("failed to write theme: %w", err)
+	}
+
+	return converted, nil
+}
+
+// fetchPackage opens the stored package of the version, or downloads it and stores it. The
+// package store is a cache, failing to use it doesn't fail the sync. The package must be closed.
+func (w *SyncExtensionWorker) fetchPackage(ctx context.Context, queries *db.Queries, d *downloader.Downloader, extension marketplace.ExtensionResult, key packagestore.Key, packageUrl string, preRelease bool) (*downloader.Package, error) {
+	if w.PackageStore != nil {
+		pkg, err := w.loadStoredPackage(ctx, d, key)
+		if err != nil {
+			log.Warnf("Failed to load stored package, downloading it instead: %s", err)
+		}
+		if pkg != nil {
+			log.Infof("Loaded stored package, %d bytes, sha256: %s", pkg.Size, pkg.SHA256)
+			return pkg, nil
+		}
+	}
+
+	log.Infof("Downloading package: %s", packageUrl)
+	pkg, err := d.Open(ctx, packageUrl)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Downloaded %d bytes, sha256: %s", pkg.Size, pkg.SHA256)
+
+	if w.PackageStore != nil {
+		key.SHA256 = pkg.SHA256
+		if err := w.storePackage(ctx, queries, pkg, extension, key, preRelease); err != nil {
+			log.Warnf("Failed to store package: %s", err)
+		}
+	}
+
+	return pkg, nil
+}
+
+// loadStoredPackage opens the stored package of the version, or returns nil if there's none. The
+// checksum is checked against the key, an error is returned for a corrupted package.
+func (w *SyncExtensionWorker) loadStoredPackage(ctx context.Context, d *downloader.Downloader, key packagestore.Key) (*downloader.Package, error) {
+	key, err := w.PackageStore.Find(ctx, key.ExtensionID, key.Version)
+	if errors.Is(err, packagestore.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := w.PackageStore.Get(ctx, key)
+	if errors.Is(err, packagestore.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	pkg, err := d.OpenReader(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if pkg.SHA256 != key.SHA256 {
+		pkg.Close()
+		return nil, fmt.Errorf("stored package %s has sha256 %s", key.Path(), pkg.SHA256)
+	}
+
+	return pkg, nil
+}
+
+// storePackage stores the downloaded package. Unless packages are archived, packages of versions
+// that are no longer current are deleted, keeping the synced version of the other release channel.
+func (w *SyncExtensionWorker) storePackage(ctx context.Context, queries *db.Queries, pkg *downloader.Package, extension marketplace.ExtensionResult, key packagestore.Key, preRelease bool) error {
+	if err := w.PackageStore.Put(ctx, key, pkg.NewReader()); err != nil {
+		return err
+	}
+
+	if w.ArchivePackages {
+		return nil
+	}
+
+	keepVersions := []string{key.Version}
+	otherVersion, err := queries.GetExtensionSyncedVersion(ctx, db.GetExtensionSyncedVersionParams{
+		PreRelease:    !preRelease,
+		ExtensionName: extension.ExtensionName,
+		PublisherName: extension.Publisher.PublisherName,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to get synced version: %w", err)
+	}
+	if err == nil {
+		keepVersions = append(keepVersions, otherVersion.Version)
+	}
+
+	return w.PackageStore.Prune(ctx, key.ExtensionID, keepVersions)
+}
+
+// verifySignature verifies the downloaded package against the version's signature archive.
+// Versions without a signature are unsigned, a signature that doesn't verify is invalid.
+func (w *SyncExtensionWorker) verifySignature(ctx context.Context, reg registry.Registry, version marketplace.ExtensionVersionResult, pkg *downloader.Package) (signature.Status, error) {
+	signatureUrl := reg.GetSignatureURL(version)
+	if signatureUrl == "" {
+		return signature.StatusUnsigned, nil
+	}
+
+	log.Infof("Downloading signature: %s", signatureUrl)
+	archive, err := downloader.New(downloader.WithMaxSize(maxSignatureSize)).Fetch(ctx, signatureUrl)
+	var statusErr *downloader.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		return signature.StatusUnsigned, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := w.SignatureVerifier.Verify(archive, pkg.SHA256, pkg.Size); err != nil {
+		log.Warnf("Package signature doesn't verify: %s", err)
+		return signature.StatusInvalid, nil
+	}
+
+	return signature.StatusVerified, nil
+}
+
+// isExtensionUpToDate returns true if the version that would be selected for the extension is
+// the one that was last synced.
+func isExtensionUpToDate(ctx context.Context, queries *db.Queries, extension marketplace.ExtensionResult, preRelease bool) (bool, error) {
+	version := extension.SelectVersion(preRelease)
+	if version == nil {
+		return false, nil
+	}
+
+	syncedVersion, err := queries.GetExtensionSyncedVersion(ctx, db.GetExtensionSyncedVersionParams{
+		PreRelease:    preRelease,
 		ExtensionName: extension.ExtensionName,
 		PublisherName: extension.Publisher.PublisherName,
-		Language:      "go",
 	})
 
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -272,18 +708,18 @@ func isExtensionUpToDate(ctx context.Context, queries *db.Queries, extension mar
 	}
 
 	if err != nil {
-		return false, fmt.Errorf("failed to get extension: %w", err)
+		return false, fmt.Errorf("failed to get synced version: %w", err)
 	}
 
-	publishedAt, err := time.Parse(time.RFC3339, extension.PublishedDate)
-	if err != nil {
-		return false, fmt.Errorf("failed to parse publishedAt: %w", err)
+	// Extensions that reappear after being removed are synced again to restore them.
+	if syncedVersion.RemovedAt.Valid {
+		return false, nil
 	}
 
-	return savedExtension.PublishedAt.Time.Equal(publishedAt), nil
+	return syncedVersion.Version == version.Version, nil
 }
 
-func convertUpsertExtensionParams(extension marketplace.ExtensionResult) (db.UpsertExtensionParams, error) {
+func convertUpsertExtensionParams(reg registry.Registry, extension marketplace.ExtensionResult) (db.UpsertExtensionParams, error) {
 	params := db.UpsertExtensionParams{
 		VscExtensionID:       extension.ExtensionID,
 		Name:                 extension.ExtensionName,
@@ -292,6 +728,7 @@ func convertUpsertExtensionParams(extension marketplace.ExtensionResult) (db.Ups
 		PublisherID:          extension.Publisher.PublisherID,
 		PublisherName:        extension.Publisher.PublisherName,
 		PublisherDisplayName: extension.Publisher.DisplayName,
+		Registry:             reg.Name(),
 	}
 
 	publishedAt, err := time.Parse(time.RFC3339, extension.PublishedDate)
@@ -306,32 +743,29 @@ func convertUpsertExtensionParams(extension marketplace.ExtensionResult) (db.Ups
 	}
 	params.ReleasedAt = db.Timestamp(&releasedAt)
 
-	installs := findStatistic(extension.Stastistics, "install")
-	params.Installs = int32(installs)
+	statistics := reg.GetStatistics(extension)
+
+	params.Installs = int32(statistics.Installs)
 
-	trendingDailyStat := findStatistic(extension.Stastistics, "trendingdaily")
-	trendingDaily, err := db.Numeric(&trendingDailyStat)
+	trendingDaily, err := db.Numeric(&statistics.TrendingDaily)
 	if err != nil {
 		return params, fmt.Errorf("failed to convert trendingDaily to numeric: %w", err)
 	}
 	params.TrendingDaily = trendingDaily
 
-	trendingWeeklyStat := findStatistic(extension.Stastistics, "trendingweekly")
-	trendingWeekly, err := db.Numeric(&trendingWeeklyStat)
+	trendingWeekly, err := db.Numeric(&statistics.TrendingWeekly)
 	if err != nil {
 		return params, fmt.Errorf("failed to convert trendingWeekly to numeric: %w", err)
 	}
 	params.TrendingWeekly = trendingWeekly
 
-	trendingMonthlyStat := findStatistic(extension.Stastistics, "trendingmonthly")
-	trendingMonthly, err := db.Numeric(&trendingMonthlyStat)
+	trendingMonthly, err := db.Numeric(&statistics.TrendingMonthly)
 	if err != nil {
 		return params, fmt.Errorf("failed to convert trendingMonthly to numeric: %w", err)
 	}
 	params.TrendingMonthly = trendingMonthly
 
-	weightedRatingStat := findStatistic(extension.Stastistics, "weightedRating")
-	weightedRating, err := db.Numeric(&weightedRatingStat)
+	weightedRating, err := db.Numeric(&statistics.WeightedRating)
 	if err != nil {
 		return params, fmt.Errorf("failed to convert weightedRating to numeric: %w", err)
 	}
@@ -340,16 +774,6 @@ func convertUpsertExtensionParams(extension marketplace.ExtensionResult) (db.Ups
 	return params, nil
 }
 
-func findStatistic(statistics []marketplace.ExtensionStatisticsResult, name string) float64 {
-	for _, statistic := range statistics {
-		if statistic.StatisticName == name {
-			return statistic.Value
-		}
-	}
-
-	return 0
-}
-
 func makeThemeSlugGenerator() func(string) string {
 	themeSlugCounts := make(map[string]int)
 
@@ -366,188 +790,154 @@ func makeThemeSlugGenerator() func(string) string {
 	}
 }
 
-func convertUpsertThemeParams(themeSlug string, theme cli.Theme) (db.UpsertThemeParams, error) {
-	upsertThemeParams := db.UpsertThemeParams{
-		Path:        theme.Path,
-		DisplayName: theme.DisplayName,
-		Name:        themeSlug,
-	}
-
-	editorBackground, err := colors.HexToLabString(theme.Colors.EditorBackground)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert editorBackground to lab: %w", err)
-	}
-	upsertThemeParams.EditorBackground = editorBackground
-
-	editorForeground, err := colors.HexToLabString(theme.Colors.EditorForeground)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert editorForeground to lab: %w", err)
-	}
-	upsertThemeParams.EditorForeground = editorForeground
-
-	activityBarBackground, err := colors.HexToLabString(theme.Colors.ActivityBarBackground)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert activityBarBackground to lab: %w", err)
-	}
-	upsertThemeParams.ActivityBarBackground = activityBarBackground
-
-	activityBarForeground, err := colors.HexToLabString(theme.Colors.ActivityBarForeground)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert activityBarForeground to lab: %w", err)
-	}
-	upsertThemeParams.ActivityBarForeground = activityBarForeground
-
-	activityBarInActiveForeground, err := colors.HexToLabString(theme.Colors.ActivityBarInActiveForeground)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert activityBarInActiveForeground to lab: %w", err)
-	}
-	upsertThemeParams.ActivityBarInActiveForeground = activityBarInActiveForeground
-
-	if theme.Colors.ActivityBarBorder != nil {
-		activityBarBorder, err := colors.HexToLabString(*theme.Colors.ActivityBarBorder)
-		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert activityBarBorder to lab: %w", err)
-		}
-		upsertThemeParams.ActivityBarBorder = &activityBarBorder
-	}
+// legacyColorColumn is a color column of the themes table and the theme color it's derived from.
+type legacyColorColumn struct {
+	key      string
+	required *string
+	optional **string
+}
 
-	activityBarActiveBorder, err := colors.HexToLabString(theme.Colors.ActivityBarActiveBorder)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert activityBarActiveBorder to lab: %w", err)
+// legacyColorColumns returns the color columns of the theme params. The columns predate the colors
+// JSONB and are still returned by the search API, they're derived from the same resolved colors so
+// they can't drift from it.
+func legacyColorColumns(params *db.UpsertThemeParams) []legacyColorColumn {
+	return []legacyColorColumn{
+		{key: "editor.background", required: &params.EditorBackground},
+		{key: "editor.foreground", required: &params.EditorForeground},
+		{key: "activityBar.background", required: &params.ActivityBarBackground},
+		{key: "activityBar.foreground", required: &params.ActivityBarForeground},
+		{key: "activityBar.inactiveForeground", required: &params.ActivityBarInActiveForeground},
+		{key: "activityBar.border", optional: &params.ActivityBarBorder},
+		{key: "activityBar.activeBorder", required: &params.ActivityBarActiveBorder},
+		{key: "activityBar.activeBackground", optional: &params.ActivityBarActiveBackground},
+		{key: "activityBarBadge.background", required: &params.ActivityBarBadgeBackground},
+		{key: "activityBarBadge.foreground", required: &params.ActivityBarBadgeForeground},
+		{key: "editorGroupHeader.tabsBackground", optional: &params.TabsContainerBackground},
+		{key: "editorGroupHeader.tabsBorder", optional: &params.TabsContainerBorder},
+		{key: "statusBar.background", optional: &params.StatusBarBackground},
+		{key: "statusBar.foreground", required: &params.StatusBarForeground},
+		{key: "statusBar.border", optional: &params.StatusBarBorder},
+		{key: "tab.activeBackground", optional: &params.TabActiveBackground},
+		{key: "tab.inactiveBackground", optional: &params.TabInactiveBackground},
+		{key: "tab.activeForeground", required: &params.TabActiveForeground},
+		{key: "tab.border", required: &params.TabBorder},
+		{key: "tab.activeBorder", optional: &params.TabActiveBorder},
+		{key: "tab.activeBorderTop", optional: &params.TabActiveBorderTop},
+		{key: "titleBar.activeBackground", required: &params.TitleBarActiveBackground},
+		{key: "titleBar.activeForeground", required: &params.TitleBarActiveForeground},
+		{key: "titleBar.border", optional: &params.TitleBarBorder},
 	}
-	upsertThemeParams.ActivityBarActiveBorder = activityBarActiveBorder
+}
 
-	if theme.Colors.ActivityBarActiveBackground != nil {
-		activityBarActiveBackground, err := colors.HexToLabString(*theme.Colors.ActivityBarActiveBackground)
-		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert activityBarActiveBackground to lab: %w", err)
+// missingLegacyColor returns the first required color column the theme has no color for. Themes
+// without one are skipped before their images are generated.
+func missingLegacyColor(t *theme.Theme) (string, bool) {
+	for _, column := range legacyColorColumns(&db.UpsertThemeParams{}) {
+		if _, ok := t.OpaqueColor(column.key); !ok && column.required != nil {
+			return column.key, true
 		}
-		upsertThemeParams.ActivityBarActiveBackground = &activityBarActiveBackground
 	}
+	return "", false
+}
 
-	activityBarBadgeBackground, err := colors.HexToLabString(theme.Colors.ActivityBarBadgeBackground)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert activityBarBadgeBackground to lab: %w", err)
+func convertUpsertThemeParams(themeSlug string, result cli.Theme, t *theme.Theme) (db.UpsertThemeParams, error) {
+	upsertThemeParams := db.UpsertThemeParams{
+		Path:        result.Path,
+		DisplayName: result.DisplayName,
+		Name:        themeSlug,
 	}
-	upsertThemeParams.ActivityBarBadgeBackground = activityBarBadgeBackground
 
-	activityBarBadgeForeground, err := colors.HexToLabString(theme.Colors.ActivityBarBadgeForeground)
+	var err error
+	upsertThemeParams.Colors, err = json.Marshal(t.Colors)
 	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert activityBarBadgeForeground to lab: %w", err)
+		return upsertThemeParams, fmt.Errorf("failed to marshal colors: %w", err)
 	}
-	upsertThemeParams.ActivityBarBadgeForeground = activityBarBadgeForeground
 
-	if theme.Colors.TabsContainerBackground != nil {
-		tabsContainerBackground, err := colors.HexToLabString(*theme.Colors.TabsContainerBackground)
-		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert tabsContainerBackground to lab: %w", err)
+	for _, column := range legacyColorColumns(&upsertThemeParams) {
+		color, ok := t.OpaqueColor(column.key)
+		if !ok {
+			if column.required != nil {
+				return upsertThemeParams, fmt.Errorf("theme has no %s color", column.key)
+			}
+			continue
 		}
-		upsertThemeParams.TabsContainerBackground = &tabsContainerBackground
-	}
 
-	if theme.Colors.TabsContainerBorder != nil {
-		tabsContainerBorder, err := colors.HexToLabString(*theme.Colors.TabsContainerBorder)
+		lab, err := colors.HexToLabString(color)
 		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert tabsContainerBorder to lab: %w", err)
+			return upsertThemeParams, fmt.Errorf("failed to convert %s to lab: %w", column.key, err)
 		}
-		upsertThemeParams.TabsContainerBorder = &tabsContainerBorder
-	}
 
-	if theme.Colors.StatusBarBackground != nil {
-		statusBarBackground, err := colors.HexToLabString(*theme.Colors.StatusBarBackground)
-		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert statusBarBackground to lab: %w", err)
+		if column.required != nil {
+			*column.required = lab
+		} else {
+			*column.optional = &lab
 		}
-		upsertThemeParams.StatusBarBackground = &statusBarBackground
-	}
-
-	statusBarForeground, err := colors.HexToLabString(theme.Colors.StatusBarForeground)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert statusBarForeground to lab: %w", err)
 	}
-	upsertThemeParams.StatusBarForeground = statusBarForeground
 
-	if theme.Colors.StatusBarBorder != nil {
-		statusBarBorder, err := colors.HexToLabString(*theme.Colors.StatusBarBorder)
-		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert statusBarBorder to lab: %w", err)
-		}
-		upsertThemeParams.StatusBarBorder = &statusBarBorder
-	}
+	return upsertThemeParams, nil
+}
 
-	if theme.Colors.TabActiveBackground != nil {
-		tabActiveBackground, err := colors.HexToLabString(*theme.Colors.TabActiveBackground)
-		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert tabActiveBackground to lab: %w", err)
+// convertThemeColorSlots returns the Lab values of the slots the theme has a color for.
+func convertThemeColorSlots(t *theme.Theme, slots []string) ([]db.UpsertThemeColorSlotParams, error) {
+	params := []db.UpsertThemeColorSlotParams{}
+	for _, slot := range slots {
+		color, ok := t.OpaqueColor(slot)
+		if !ok {
+			continue
 		}
-		upsertThemeParams.TabActiveBackground = &tabActiveBackground
-	}
 
-	if theme.Colors.TabInactiveBackground != nil {
-		tabInactiveBackground, err := colors.HexToLabString(*theme.Colors.TabInactiveBackground)
+		lab, err := colors.HexToLabString(color)
 		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert tabInactiveBackground to lab: %w", err)
+			return nil, fmt.Errorf("failed to convert %s to lab: %w", slot, err)
 		}
-		upsertThemeParams.TabInactiveBackground = &tabInactiveBackground
-	}
 
-	tabActiveForeground, err := colors.HexToLabString(theme.Colors.TabActiveForeground)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert tabActiveForeground to lab: %w", err)
+		params = append(params, db.UpsertThemeColorSlotParams{Slot: slot, Color: lab})
 	}
-	upsertThemeParams.TabActiveForeground = tabActiveForeground
 
-	tabBorder, err := colors.HexToLabString(theme.Colors.TabBorder)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert tabBorder to lab: %w", err)
-	}
-	upsertThemeParams.TabBorder = tabBorder
+	return params, nil
+}
 
-	if theme.Colors.TabActiveBorder != nil {
-		tabActiveBorder, err := colors.HexToLabString(*theme.Colors.TabActiveBorder)
-		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert tabActiveBorder to lab: %w", err)
+// convertThemeTokenColors returns the Lab values and font styles of the tokens of each category,
+// as they're styled in the rendered languages.
+func convertThemeTokenColors(t *theme.Theme, languages []cli.LanguageResult) ([]db.UpsertThemeTokenColorParams, error) {
+	params := []db.UpsertThemeTokenColorParams{}
+	for _, category := range theme.TokenCategories {
+		style, ok := t.TokenStyle(category, languages)
+		if !ok || style.Foreground == "" {
+			continue
 		}
-		upsertThemeParams.TabActiveBorder = &tabActiveBorder
-	}
 
-	if theme.Colors.TabActiveBorderTop != nil {
-		tabActiveBorderTop, err := colors.HexToLabString(*theme.Colors.TabActiveBorderTop)
+		lab, err := colors.HexToLabString(style.Foreground)
 		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert tabActiveBorderTop to lab: %w", err)
+			return nil, fmt.Errorf("failed to convert %s to lab: %w", category.Name, err)
 		}
-		upsertThemeParams.TabActiveBorderTop = &tabActiveBorderTop
-	}
 
-	titleBarActiveBackground, err := colors.HexToLabString(theme.Colors.TitleBarActiveBackground)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert titleBarActiveBackground to lab: %w", err)
-	}
-	upsertThemeParams.TitleBarActiveBackground = titleBarActiveBackground
-
-	titleBarActiveForeground, err := colors.HexToLabString(theme.Colors.TitleBarActiveForeground)
-	if err != nil {
-		return upsertThemeParams, fmt.Errorf("failed to convert titleBarActiveForeground to lab: %w", err)
+		params = append(params, db.UpsertThemeTokenColorParams{
+			Category:  category.Name,
+			Color:     lab,
+			FontStyle: style.FontStyle,
+		})
 	}
-	upsertThemeParams.TitleBarActiveForeground = titleBarActiveForeground
 
-	if theme.Colors.TitleBarBorder != nil {
-		titleBarBorder, err := colors.HexToLabString(*theme.Colors.TitleBarBorder)
-		if err != nil {
-			return upsertThemeParams, fmt.Errorf("failed to convert titleBarBorder to lab: %w", err)
-		}
-		upsertThemeParams.TitleBarBorder = &titleBarBorder
-	}
+	return params, nil
+}
 
-	return upsertThemeParams, nil
+// themeImagesResult is the images of a theme with the theme they were generated from.
+type themeImagesResult struct {
+	cli.GenerateImagesResult
+	resolved *theme.Theme
 }
 
 type UpsertThemeWithImagesParams struct {
-	Theme  db.UpsertThemeParams
-	Images []db.UpsertImageParams
+	Theme       db.UpsertThemeParams
+	ColorSlots  []db.UpsertThemeColorSlotParams
+	TokenColors []db.UpsertThemeTokenColorParams
+	Images      []db.UpsertImageParams
 }
 
-func saveExtension(ctx context.Context, dbPool *pgxpool.Pool, extension db.UpsertExtensionParams, themes []UpsertThemeWithImagesParams) error {
+// saveExtension saves the extension with the synced version and the themes and images rendered
+// from it. Themes of previously synced versions are kept.
+func saveExtension(ctx context.Context, dbPool *pgxpool.Pool, extension db.UpsertExtensionParams, version db.UpsertExtensionVersionParams, themes []UpsertThemeWithImagesParams) error {
 	return pgx.BeginFunc(ctx, dbPool, func(tx pgx.Tx) error {
 		queries := db.New(tx)
 
@@ -561,11 +951,19 @@ func saveExtension(ctx context.Context, dbPool *pgxpool.Pool, extension db.Upser
 			return fmt.Errorf("failed to upsert extension: %w", err)
 		}
 
+		// Upsert version.
+		version.ExtensionID = extension.ID
+		extensionVersion, err := queries.UpsertExtensionVersion(ctx, version)
+		if err != nil {
+			return fmt.Errorf("failed to upsert extension version: %w", err)
+		}
+
 		// Upsert themes and images.
 		upsertedThemeIds := []int64{}
 		for _, themeWithImages := range themes {
-			// Set extension ID for each theme.
+			// Set extension and version ID for each theme.
 			themeWithImages.Theme.ExtensionID = extension.ID
+			themeWithImages.Theme.ExtensionVersionID = extensionVersion.ID
 
 			// Upsert theme.
 			theme, err := queries.UpsertTheme(ctx, themeWithImages.Theme)
@@ -575,27 +973,95 @@ func saveExtension(ctx context.Context, dbPool *pgxpool.Pool, extension db.Upser
 
 			upsertedThemeIds = append(upsertedThemeIds, theme.ID)
 
+			// Upsert color slots, and delete the slots it no longer has a color for.
+			slots := []string{}
+			for _, colorSlot := range themeWithImages.ColorSlots {
+				colorSlot.ThemeID = theme.ID
+				if err := queries.UpsertThemeColorSlot(ctx, colorSlot); err != nil {
+					return fmt.Errorf("failed to upsert theme color slot: %w", err)
+				}
+				slots = append(slots, colorSlot.Slot)
+			}
+
+			err = queries.DeleteThemeColorSlotsNotIn(ctx, db.DeleteThemeColorSlotsNotInParams{
+				ThemeID: theme.ID,
+				Slots:   slots,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete old theme color slots: %w", err)
+			}
+
+			// Upsert token colors, and delete the categories it no longer has a color for.
+			categories := []string{}
+			for _, tokenColor := range themeWithImages.TokenColors {
+				tokenColor.ThemeID = theme.ID
+				if err := queries.UpsertThemeTokenColor(ctx, tokenColor); err != nil {
+					return fmt.Errorf("failed to upsert theme token color: %w", err)
+				}
+				categories = append(categories, tokenColor.Category)
+			}
+
+			err = queries.DeleteThemeTokenColorsNotIn(ctx, db.DeleteThemeTokenColorsNotInParams{
+				ThemeID:    theme.ID,
+				Categories: categories,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete old theme token colors: %w", err)
+			}
+
 			// Upsert images.
+			upsertedImageIds := []int64{}
 			for _, image := range themeWithImages.Images {
-				// Set theme ID for each image.
+				// Set theme and version ID for each image.
 				image.ThemeID = theme.ID
+				image.ExtensionVersionID = extensionVersion.ID
 
 				// Upsert image.
-				if _, err := queries.UpsertImage(ctx, image); err != nil {
+				upsertedImage, err := queries.UpsertImage(ctx, image)
+				if err != nil {
 					return fmt.Errorf("failed to upsert image: %w", err)
 				}
+
+				upsertedImageIds = append(upsertedImageIds, upsertedImage.ID)
+			}
+
+			// Delete images in sizes that are no longer generated.
+			err = queries.DeleteThemeImagesNotIn(ctx, db.DeleteThemeImagesNotInParams{
+				ThemeID:  theme.ID,
+				ImageIds: upsertedImageIds,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to delete old images: %w", err)
 			}
 		}
 
-		// Delete old themes and images.
+		// Delete themes and images the version no longer has, in case it was synced before.
 		err = queries.DeleteExtensionThemesNotIn(ctx, db.DeleteExtensionThemesNotInParams{
-			ExtensionID: extension.ID,
-			ThemeIds:    upsertedThemeIds,
+			ExtensionVersionID: extensionVersion.ID,
+			ThemeIds:           upsertedThemeIds,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to delete old themes: %w", err)
 		}
 
+		// Make the version the current one.
+		if version.PreRelease {
+			err = queries.SetExtensionPreReleaseVersion(ctx, db.SetExtensionPreReleaseVersionParams{
+				ID:                  extension.ID,
+				PreReleaseVersion:   db.Text(&extensionVersion.Version),
+				PreReleaseVersionID: db.Int8(&extensionVersion.ID),
+			})
+		} else {
+			err = queries.SetExtensionVersion(ctx, db.SetExtensionVersionParams{
+				ID:        extension.ID,
+				Version:   extensionVersion.Version,
+				VersionID: db.Int8(&extensionVersion.ID),
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to set current version: %w", err)
+		}
+
 		return nil
 	})
 }
@@ -0,0 +1,192 @@
+package workers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/vscodethemes/backend/internal/cli"
+	"github.com/vscodethemes/backend/internal/colors"
+	"github.com/vscodethemes/backend/internal/db"
+	"github.com/vscodethemes/backend/internal/objectstore"
+	"github.com/vscodethemes/backend/internal/theme"
+)
+
+func loadTheme(t *testing.T, source string) *theme.Theme {
+	t.Helper()
+
+	fsys := fstest.MapFS{"extension/theme.json": &fstest.MapFile{Data: []byte(source)}}
+	resolved, err := theme.Load(fsys, cli.ThemeContribute{Path: "./theme.json", UITheme: "vs-dark"})
+	if err != nil {
+		t.Fatalf("failed to load theme: %v", err)
+	}
+	return resolved
+}
+
+func lab(t *testing.T, hex string) string {
+	t.Helper()
+
+	lab, err := colors.HexToLabString(hex)
+	if err != nil {
+		t.Fatalf("failed to convert %s to lab: %v", hex, err)
+	}
+	return lab
+}
+
+func TestConvertUpsertThemeParamsInvalidColor(t *testing.T) {
+	resolved := loadTheme(t, `{
+		"name": "Theme",
+		"colors": { "tab.border": "", "statusBar.foreground": "transparent" }
+	}`)
+
+	if key, ok := missingLegacyColor(resolved); ok {
+		t.Fatalf("expected invalid colors to fall back to their default, %s is missing", key)
+	}
+
+	params, err := convertUpsertThemeParams("theme", cli.Theme{Path: "./theme.json"}, resolved)
+	if err != nil {
+		t.Fatalf("failed to convert theme: %v", err)
+	}
+	if want := lab(t, "#252526"); params.TabBorder != want {
+		t.Errorf("expected the default tab border %s, got %s", want, params.TabBorder)
+	}
+	if want := lab(t, "#FFFFFF"); params.StatusBarForeground != want {
+		t.Errorf("expected the default status bar foreground %s, got %s", want, params.StatusBarForeground)
+	}
+}
+
+func TestMissingLegacyColor(t *testing.T) {
+	resolved := loadTheme(t, `{ "name": "Theme" }`)
+	resolved.Colors["tab.border"] = "transparent"
+
+	key, ok := missingLegacyColor(resolved)
+	if !ok || key != "tab.border" {
+		t.Fatalf("expected tab.border to be missing, got %q (%t)", key, ok)
+	}
+
+	if _, err := convertUpsertThemeParams("theme", cli.Theme{}, resolved); err == nil {
+		t.Errorf("expected a theme without a tab border to fail to convert")
+	}
+}
+
+func TestLegacyColorColumns(t *testing.T) {
+	var params db.UpsertThemeParams
+	keys := map[string]bool{}
+	fields := map[any]string{}
+	for _, column := range legacyColorColumns(&params) {
+		if keys[column.key] {
+			t.Errorf("%s has more than one column", column.key)
+		}
+		keys[column.key] = true
+
+		if (column.required == nil) == (column.optional == nil) {
+			t.Errorf("expected %s to be either required or optional", column.key)
+			continue
+		}
+		var field any = column.required
+		if column.optional != nil {
+			field = column.optional
+		}
+		if key, ok := fields[field]; ok {
+			t.Errorf("%s and %s have the same column", key, column.key)
+		}
+		fields[field] = column.key
+	}
+}
+
+func TestConvertUpsertThemeParams(t *testing.T) {
+	resolved := loadTheme(t, `{
+		"name": "Theme",
+		"colors": {
+			"editor.background": "#000000",
+			"tab.border": "#ffffff80",
+			"tab.activeBorderTop": "#ff0000"
+		}
+	}`)
+
+	params, err := convertUpsertThemeParams("theme-slug", cli.Theme{Path: "./theme.json", DisplayName: "Theme"}, resolved)
+	if err != nil {
+		t.Fatalf("failed to convert theme: %v", err)
+	}
+
+	if params.Name != "theme-slug" || params.Path != "./theme.json" || params.DisplayName != "Theme" {
+		t.Errorf("unexpected name %q, path %q or display name %q", params.Name, params.Path, params.DisplayName)
+	}
+
+	var storedColors map[string]string
+	if err := json.Unmarshal(params.Colors, &storedColors); err != nil {
+		t.Fatalf("failed to unmarshal colors: %v", err)
+	}
+	if len(storedColors) != len(resolved.Colors) || storedColors["tab.border"] != "#ffffff80" {
+		t.Errorf("expected the resolved colors to be stored as they are, got %v", storedColors)
+	}
+
+	// Columns are opaque, blended over the editor background.
+	if want := lab(t, "#808080"); params.TabBorder != want {
+		t.Errorf("expected tab border %s, got %s", want, params.TabBorder)
+	}
+	if want := lab(t, "#ff0000"); params.TabActiveBorderTop == nil || *params.TabActiveBorderTop != want {
+		t.Errorf("expected tab active border top %s, got %v", want, params.TabActiveBorderTop)
+	}
+	// Optional colors without a default in dark themes aren't set.
+	if params.TitleBarBorder != nil {
+		t.Errorf("expected no title bar border, got %s", *params.TitleBarBorder)
+	}
+
+	for _, column := range legacyColorColumns(&params) {
+		if column.required != nil && *column.required == "" {
+			t.Errorf("expected %s to be set", column.key)
+		}
+	}
+}
+
+func TestUploadImageKey(t *testing.T) {
+	dir := t.TempDir()
+	store := objectstore.NewLocalStore(filepath.Join(dir, "objects"), "http://localhost/static")
+	w := &SyncExtensionWorker{ObjectStore: store}
+
+	writeImage := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write image: %v", err)
+		}
+		return path
+	}
+
+	ctx := context.Background()
+	first, err := w.uploadImage(ctx, writeImage("a.svg", "<svg/>"), "images/theme", "svg", "image/svg+xml")
+	if err != nil {
+		t.Fatalf("failed to upload image: %v", err)
+	}
+
+	digest := sha256.Sum256([]byte("<svg/>"))
+	key := "images/theme-" + hex.EncodeToString(digest[:]) + ".svg"
+	if first.sha256 != hex.EncodeToString(digest[:]) || first.url != store.URL(key) {
+		t.Errorf("expected image at %s, got %s (%s)", store.URL(key), first.url, first.sha256)
+	}
+	if exists, err := store.Exists(ctx, key); err != nil || !exists {
+		t.Errorf("expected %s to be uploaded: %v", key, err)
+	}
+
+	// The key only depends on the content.
+	same, err := w.uploadImage(ctx, writeImage("b.svg", "<svg/>"), "images/theme", "svg", "image/svg+xml")
+	if err != nil {
+		t.Fatalf("failed to upload image: %v", err)
+	}
+	if same != first {
+		t.Errorf("expected the same image for the same content, got %+v and %+v", first, same)
+	}
+
+	other, err := w.uploadImage(ctx, writeImage("c.svg", "<svg></svg>"), "images/theme", "svg", "image/svg+xml")
+	if err != nil {
+		t.Fatalf("failed to upload image: %v", err)
+	}
+	if other.url == first.url {
+		t.Errorf("expected a different key for different content, got %s", other.url)
+	}
+}
@@ -12,6 +12,9 @@ import (
 	"github.com/vscodethemes/backend/internal/db"
 )
 
+// Number of extensions updated by each BatchUpdateExtensionStats job.
+const updateStatsBatchSize = 200
+
 type UpdateAllExtensionsStatsArgs struct{}
 
 func (UpdateAllExtensionsStatsArgs) Kind() string {
@@ -49,18 +52,27 @@ func (w *UpdateAllExtensionsStatsWorker) Work(ctx context.Context, job *river.Jo
 		return fmt.Errorf("failed to get all extensions: %w", err)
 	}
 
-	// Update the stats for each extension.
-	batch := []river.InsertManyParams{}
+	// Group the extensions by registry so that each batch can be fetched in a single lookup.
+	slugsByRegistry := map[string][]string{}
 	for _, extension := range extensions {
-		batch = append(batch, river.InsertManyParams{
-			Args: UpdateExtensionStatsArgs{
-				PublisherName: extension.PublisherName,
-				ExtensionName: extension.Name,
-			},
-			InsertOpts: &river.InsertOpts{
-				Queue: UpdateExtenstionStatsQueue,
-			},
-		})
+		slug := fmt.Sprintf("%s.%s", extension.PublisherName, extension.Name)
+		slugsByRegistry[extension.Registry] = append(slugsByRegistry[extension.Registry], slug)
+	}
+
+	// Update the stats for each batch of extensions.
+	batch := []river.InsertManyParams{}
+	for registryName, slugs := range slugsByRegistry {
+		for start := 0; start < len(slugs); start += updateStatsBatchSize {
+			batch = append(batch, river.InsertManyParams{
+				Args: BatchUpdateExtensionStatsArgs{
+					Registry:   registryName,
+					Extensions: slugs[start:min(start+updateStatsBatchSize, len(slugs))],
+				},
+				InsertOpts: &river.InsertOpts{
+					Queue: UpdateExtenstionStatsQueue,
+				},
+			})
+		}
 	}
 
 	if len(batch) > 0 {
@@ -69,7 +81,7 @@ func (w *UpdateAllExtensionsStatsWorker) Work(ctx context.Context, job *river.Jo
 		}
 	}
 
-	log.Infof("Updating %d extensions in batch", len(batch))
+	log.Infof("Updating %d extensions in %d batches", len(extensions), len(batch))
 
 	return nil
 }
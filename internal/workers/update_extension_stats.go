@@ -2,6 +2,7 @@ package workers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,13 +10,13 @@ import (
 	"github.com/labstack/gommon/log"
 	"github.com/riverqueue/river"
 	"github.com/vscodethemes/backend/internal/db"
-	"github.com/vscodethemes/backend/internal/marketplace"
-	"github.com/vscodethemes/backend/internal/marketplace/qo"
+	"github.com/vscodethemes/backend/internal/registry"
 )
 
 type UpdateExtensionStatsArgs struct {
 	ExtensionName string `json:"extensionName"`
 	PublisherName string `json:"publisherName"`
+	Registry      string `json:"registry"`
 }
 
 func (UpdateExtensionStatsArgs) Kind() string {
@@ -31,8 +32,8 @@ func (UpdateExtensionStatsArgs) InsertOpts() river.InsertOpts {
 
 type UpdateExtensionStatsWorker struct {
 	river.WorkerDefaults[UpdateExtensionStatsArgs]
-	Marketplace *marketplace.Client
-	DBPool      *pgxpool.Pool
+	Registries registry.Registries
+	DBPool     *pgxpool.Pool
 }
 
 func (w *UpdateExtensionStatsWorker) Timeout(*river.Job[UpdateExtensionStatsArgs]) time.Duration {
@@ -43,22 +44,21 @@ func (w *UpdateExtensionStatsWorker) Work(ctx context.Context, job *river.Job[Up
 	extensionSlug := fmt.Sprintf("%s.%s", job.Args.PublisherName, job.Args.ExtensionName)
 	log.Infof("Updating extension stats: %s", extensionSlug)
 
-	// Add a delay to avoid rate limiting from the martketplace API.
-	time.Sleep(2 * time.Second)
-
-	// Fetch extension from the marketplace API.
-	queryResults, err := w.Marketplace.NewQuery(ctx, qo.WithSlug(extensionSlug))
+	reg, err := w.Registries.Get(job.Args.Registry)
 	if err != nil {
-		return fmt.Errorf("failed to query marketplace: %w", err)
+		return err
 	}
 
-	if len(queryResults) == 0 {
-		return fmt.Errorf("extension not found")
+	// Fetch extension from the registry.
+	extension, err := reg.GetExtension(ctx, job.Args.PublisherName, job.Args.ExtensionName)
+	if errors.Is(err, registry.ErrNotFound) {
+		return cancelMissingExtension(ctx, reg, job.Args.PublisherName, job.Args.ExtensionName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", reg.Name(), err)
 	}
 
-	extension := queryResults[0]
-
-	upsertExtensionParams, err := convertUpsertExtensionParams(extension)
+	upsertExtensionParams, err := convertUpsertExtensionParams(reg, *extension)
 	if err != nil {
 		return fmt.Errorf("failed to convert upsert extension params: %w", err)
 	}
@@ -6,40 +6,73 @@ import (
 	"math"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/riverqueue/river"
 	"github.com/riverqueue/river/rivertype"
+	"github.com/vscodethemes/backend/internal/cli"
 	"github.com/vscodethemes/backend/internal/marketplace"
 	"github.com/vscodethemes/backend/internal/marketplace/qo"
+	"github.com/vscodethemes/backend/internal/objectstore"
+	"github.com/vscodethemes/backend/internal/openvsx"
+	"github.com/vscodethemes/backend/internal/packagestore"
+	"github.com/vscodethemes/backend/internal/ratelimit"
+	"github.com/vscodethemes/backend/internal/registry"
+	"github.com/vscodethemes/backend/internal/signature"
 )
 
 // Workers
 
 type RegisterWorkersConfig struct {
-	Registry          *river.Workers
-	Directory         string
-	DisableCleanup    bool
-	ObjectStoreClient *s3.Client
-	ObjectStoreBucket string
-	CDNBaseUrl        string
-	DBPool            *pgxpool.Pool
+	Registry                *river.Workers
+	Directory               string
+	DisableCleanup          bool
+	ObjectStore             objectstore.Store
+	DBPool                  *pgxpool.Pool
+	MarketplaceRateLimit    float64
+	MarketplaceRateBurst    int
+	MaxPackageSize          int64
+	PackageBufferDir        string
+	PackageStore            *packagestore.Store
+	ArchivePackages         bool
+	SignatureVerifier       *signature.Verifier
+	RefuseInvalidSignatures bool
+	Renderer                *cli.Renderer
+	ColorSlots              []string
+	ImageWidths             []int
 }
 
 func RegisterWorkers(cfg RegisterWorkersConfig) error {
+	// All worker processes draw from the same marketplace rate limit.
+	marketplaceLimiter, err := ratelimit.NewPostgresLimiter(cfg.DBPool, registry.Marketplace, cfg.MarketplaceRateLimit, cfg.MarketplaceRateBurst)
+	if err != nil {
+		return fmt.Errorf("failed to create marketplace rate limiter: %w", err)
+	}
+
+	registries := registry.NewRegistries(
+		registry.NewMarketplace(marketplace.NewClient(marketplace.WithLimiter(marketplaceLimiter))),
+		registry.NewOpenVSX(openvsx.NewClient()),
+	)
+
 	river.AddWorker(cfg.Registry, &ScanExtensionsWorker{
-		Marketplace: marketplace.NewClient(),
-		DBPool:      cfg.DBPool,
+		Registries: registries,
+		DBPool:     cfg.DBPool,
 	})
 
 	river.AddWorker(cfg.Registry, &SyncExtensionWorker{
-		Marketplace:       marketplace.NewClient(),
-		Directory:         cfg.Directory,
-		DisableCleanup:    cfg.DisableCleanup,
-		ObjectStoreClient: cfg.ObjectStoreClient,
-		ObjectStoreBucket: cfg.ObjectStoreBucket,
-		CDNBaseUrl:        cfg.CDNBaseUrl,
-		DBPool:            cfg.DBPool,
+		Registries:              registries,
+		Directory:               cfg.Directory,
+		DisableCleanup:          cfg.DisableCleanup,
+		ObjectStore:             cfg.ObjectStore,
+		DBPool:                  cfg.DBPool,
+		MaxPackageSize:          cfg.MaxPackageSize,
+		PackageBufferDir:        cfg.PackageBufferDir,
+		PackageStore:            cfg.PackageStore,
+		ArchivePackages:         cfg.ArchivePackages,
+		SignatureVerifier:       cfg.SignatureVerifier,
+		RefuseInvalidSignatures: cfg.RefuseInvalidSignatures,
+		Renderer:                cfg.Renderer,
+		ColorSlots:              cfg.ColorSlots,
+		ImageWidths:             cfg.ImageWidths,
 	})
 
 	river.AddWorker(cfg.Registry, &UpdateAllExtensionsStatsWorker{
@@ -47,8 +80,18 @@ func RegisterWorkers(cfg RegisterWorkersConfig) error {
 	})
 
 	river.AddWorker(cfg.Registry, &UpdateExtensionStatsWorker{
-		Marketplace: marketplace.NewClient(),
-		DBPool:      cfg.DBPool,
+		Registries: registries,
+		DBPool:     cfg.DBPool,
+	})
+
+	river.AddWorker(cfg.Registry, &BatchUpdateExtensionStatsWorker{
+		Registries: registries,
+		DBPool:     cfg.DBPool,
+	})
+
+	river.AddWorker(cfg.Registry, &ReconcileExtensionWorker{
+		Registries: registries,
+		DBPool:     cfg.DBPool,
 	})
 
 	return nil
@@ -56,7 +99,7 @@ func RegisterWorkers(cfg RegisterWorkersConfig) error {
 
 // Periodic Jobs
 
-func PeriodicJobs(maxExtensions int) []*river.PeriodicJob {
+func PeriodicJobs(maxExtensions int, includePreReleases bool) []*river.PeriodicJob {
 	// Scan all extensions if maxExtensions is 0.
 	if maxExtensions == 0 {
 		maxExtensions = math.MaxInt
@@ -74,6 +117,7 @@ func PeriodicJobs(maxExtensions int) []*river.PeriodicJob {
 					Priority:                 ScanPriorityLow,
 					BatchSize:                50,
 					StopAtEqualPublishedDate: true,
+					IncludePreReleases:       includePreReleases,
 				}, nil
 			},
 			&river.PeriodicJobOpts{RunOnStart: false},
@@ -97,6 +141,7 @@ const (
 	SyncExtensionHighPriorityQueue = "sync-extension-high-priority"
 	SyncExtensionLowPriorityQueue  = "sync-extension-low-priority"
 	UpdateExtenstionStatsQueue     = "update-extension-stats"
+	ReconcileExtensionQueue        = "reconcile-extension"
 )
 
 func QueueConfig() map[string]river.QueueConfig {
@@ -106,6 +151,7 @@ func QueueConfig() map[string]river.QueueConfig {
 		SyncExtensionLowPriorityQueue:  {MaxWorkers: 1},
 		ScanExtensionsQueue:            {MaxWorkers: 1},
 		UpdateExtenstionStatsQueue:     {MaxWorkers: 1},
+		ReconcileExtensionQueue:        {MaxWorkers: 1},
 	}
 }
 
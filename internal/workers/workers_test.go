@@ -0,0 +1,404 @@
+package workers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/riverqueue/river"
+	"github.com/riverqueue/river/riverdriver/riverpgxv5"
+	"github.com/vscodethemes/backend/internal/cli"
+	"github.com/vscodethemes/backend/internal/db"
+	"github.com/vscodethemes/backend/internal/downloader"
+	"github.com/vscodethemes/backend/internal/marketplace"
+	"github.com/vscodethemes/backend/internal/marketplace/marketplacetest"
+	"github.com/vscodethemes/backend/internal/marketplace/qo"
+	"github.com/vscodethemes/backend/internal/objectstore"
+	"github.com/vscodethemes/backend/internal/registry"
+	"github.com/vscodethemes/backend/internal/workers"
+	"golang.org/x/time/rate"
+)
+
+// fakeRendererEnv makes the test binary run as a renderer process, so that the sync worker can be
+// run without the Node CLI.
+const fakeRendererEnv = "WORKERS_TEST_FAKE_RENDERER"
+
+const darkTheme = `{
+	"name": "Test Dark",
+	"type": "dark",
+	"colors": {
+		"editor.background": "#1e1e1e",
+		"editor.foreground": "#d4d4d4"
+	},
+	"tokenColors": [
+		{ "scope": "keyword.control", "settings": { "foreground": "#ff0000" } }
+	]
+}`
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeRendererEnv) == "1" {
+		if err := runFakeRenderer(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestScanAndSyncExtensions(t *testing.T) {
+	ctx := context.Background()
+	dbPool := newTestDatabase(t)
+
+	server := marketplacetest.NewServer()
+	defer server.Close()
+
+	vsix, err := marketplacetest.ThemeVSIX("publisher", "theme", map[string]string{"./themes/dark.json": darkTheme})
+	if err != nil {
+		t.Fatalf("failed to build package: %v", err)
+	}
+	server.AddExtension(marketplacetest.NewExtension("publisher", "theme"), vsix)
+
+	// The scan's first query is throttled, the scan has to wait and retry it.
+	server.ThrottleNext(1, time.Second)
+
+	registries := registry.NewRegistries(registry.NewMarketplace(marketplace.NewClient(
+		marketplace.WithBaseUrl(server.URL),
+		marketplace.WithRateLimit(rate.Inf, 1),
+		marketplace.WithRetries(3, 10*time.Millisecond, 100*time.Millisecond),
+	)))
+
+	objects := objectstore.NewLocalStore(t.TempDir(), "http://localhost:8080/static/")
+	renderer := newFakeRenderer(t)
+
+	riverWorkers := river.NewWorkers()
+	river.AddWorker(riverWorkers, &workers.ScanExtensionsWorker{
+		Registries: registries,
+		DBPool:     dbPool,
+	})
+	river.AddWorker(riverWorkers, &workers.SyncExtensionWorker{
+		Registries:     registries,
+		Directory:      t.TempDir(),
+		ObjectStore:    objects,
+		DBPool:         dbPool,
+		MaxPackageSize: downloader.DefaultMaxSize,
+		Renderer:       renderer,
+		ImageWidths:    []int{200},
+	})
+
+	riverClient, err := river.NewClient(riverpgxv5.New(dbPool), &river.Config{
+		Queues:       workers.QueueConfig(),
+		Workers:      riverWorkers,
+		ErrorHandler: &workers.ErrorHandler{},
+		TestOnly:     true,
+	})
+	if err != nil {
+		t.Fatalf("failed to create river client: %v", err)
+	}
+
+	events, cancelSubscription := riverClient.Subscribe(river.EventKindJobCompleted, river.EventKindJobFailed, river.EventKindJobCancelled)
+	defer cancelSubscription()
+
+	if err := riverClient.Start(ctx); err != nil {
+		t.Fatalf("failed to start river client: %v", err)
+	}
+	defer riverClient.Stop(ctx)
+
+	start := time.Now()
+	_, err = riverClient.Insert(ctx, workers.ScanExtensionsArgs{
+		MaxExtensions: 10,
+		SortBy:        qo.SortByLastUpdated,
+		SortDirection: qo.DirectionDesc,
+		Priority:      workers.ScanPriorityHigh,
+		Registry:      registry.Marketplace,
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to insert scan job: %v", err)
+	}
+
+	scanned := waitForJob(t, events, workers.ScanExtensionsArgs{}.Kind())
+	if elapsed := scanned.Sub(start); elapsed < time.Second {
+		t.Errorf("expected the scan to wait for Retry-After, it completed after %s", elapsed)
+	}
+	waitForJob(t, events, workers.SyncExtensionArgs{}.Kind())
+
+	queries := db.New(dbPool)
+
+	synced, err := queries.GetExtensionSyncedVersion(ctx, db.GetExtensionSyncedVersionParams{
+		ExtensionName: "theme",
+		PublisherName: "publisher",
+	})
+	if err != nil {
+		t.Fatalf("failed to get synced version: %v", err)
+	}
+	if synced.Version != "1.0.0" {
+		t.Errorf("expected version 1.0.0 to be synced, got %s", synced.Version)
+	}
+
+	themes, err := queries.ListExtensionVersionThemes(ctx, db.ListExtensionVersionThemesParams{
+		Language:      "js",
+		ExtensionName: "theme",
+		PublisherName: "publisher",
+		Version:       "1.0.0",
+	})
+	if err != nil {
+		t.Fatalf("failed to list themes: %v", err)
+	}
+	if len(themes) != 1 {
+		t.Fatalf("expected 1 theme, got %d", len(themes))
+	}
+
+	var srcset []map[string]any
+	if err := json.Unmarshal(themes[0].Srcset, &srcset); err != nil {
+		t.Fatalf("failed to unmarshal srcset: %v", err)
+	}
+	if len(srcset) == 0 {
+		t.Errorf("expected the theme to have raster previews")
+	}
+
+	keys, err := objects.List(ctx, "publisher.theme/")
+	if err != nil {
+		t.Fatalf("failed to list images: %v", err)
+	}
+	if !slices.ContainsFunc(keys, func(key string) bool { return strings.HasSuffix(key, ".svg") }) {
+		t.Errorf("expected the svg preview to be uploaded, got %v", keys)
+	}
+
+	rows, err := dbPool.Query(ctx, `SELECT category FROM theme_token_colors`)
+	if err != nil {
+		t.Fatalf("failed to query token colors: %v", err)
+	}
+	categories, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		t.Fatalf("failed to read token colors: %v", err)
+	}
+	if !slices.Contains(categories, "keyword") {
+		t.Errorf("expected a keyword token color, got %v", categories)
+	}
+
+	var output workers.SyncExtensionOutput
+	err = dbPool.QueryRow(ctx, `SELECT metadata->'output' FROM river_job WHERE kind = $1`, workers.SyncExtensionArgs{}.Kind()).Scan(&output)
+	if err != nil {
+		t.Fatalf("failed to get job output: %v", err)
+	}
+	if output.Version != "1.0.0" || output.Themes != 1 {
+		t.Errorf("unexpected job output: %+v", output)
+	}
+}
+
+// newTestDatabase creates a database with the schema for the test, from the server at
+// TEST_DATABASE_URL. The test is skipped if it isn't set.
+func newTestDatabase(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	admin, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		t.Fatalf("failed to connect to database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close(ctx) })
+
+	name := fmt.Sprintf("workers_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE DATABASE "+name); err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec(ctx, "DROP DATABASE "+name+" WITH (FORCE)"); err != nil {
+			t.Errorf("failed to drop database: %v", err)
+		}
+	})
+
+	config, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		t.Fatalf("failed to parse database url: %v", err)
+	}
+	config.ConnConfig.Database = name
+
+	schema, err := os.ReadFile(filepath.Join("..", "db", "schema.sql"))
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+
+	// The schema has many statements, which are only allowed without arguments.
+	conn, err := pgx.ConnectConfig(ctx, config.ConnConfig.Copy())
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	_, err = conn.Exec(ctx, string(schema))
+	conn.Close(ctx)
+	if err != nil {
+		t.Fatalf("failed to apply schema: %v", err)
+	}
+
+	dbPool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("failed to create pool: %v", err)
+	}
+	t.Cleanup(dbPool.Close)
+
+	return dbPool
+}
+
+// waitForJob waits for a job of the kind to complete and returns when it did. The test fails if
+// any job fails or is cancelled first.
+func waitForJob(t *testing.T, events <-chan *river.Event, kind string) time.Time {
+	t.Helper()
+
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Kind != river.EventKindJobCompleted {
+				t.Fatalf("%s job %s: %v", event.Job.Kind, event.Kind, event.Job.Errors)
+			}
+			if event.Job.Kind == kind {
+				return time.Now()
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %s job", kind)
+		}
+	}
+}
+
+func newFakeRenderer(t *testing.T) *cli.Renderer {
+	t.Helper()
+
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to get test executable: %v", err)
+	}
+	t.Setenv(fakeRendererEnv, "1")
+
+	renderer := cli.NewRenderer(cli.WithCommand(executable))
+	renderer.Dir = t.TempDir()
+	if err := renderer.Start(); err != nil {
+		t.Fatalf("failed to start renderer: %v", err)
+	}
+	t.Cleanup(func() { renderer.Close() })
+
+	return renderer
+}
+
+type fakeRendererRequest struct {
+	ID     int64           `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type generateImagesParams struct {
+	Dir             string              `json:"dir"`
+	ThemeContribute cli.ThemeContribute `json:"themeContribute"`
+	Output          string              `json:"output"`
+}
+
+// runFakeRenderer answers renderer requests like the Node CLI, with a single JavaScript preview
+// per theme whose only token is a keyword.
+func runFakeRenderer(stdin io.Reader, stdout io.Writer) error {
+	encoder := json.NewEncoder(stdout)
+	scanner := bufio.NewScanner(stdin)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+
+	for scanner.Scan() {
+		var request fakeRendererRequest
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			return fmt.Errorf("failed to unmarshal request: %w", err)
+		}
+
+		var result any
+		var err error
+		switch request.Method {
+		case "ping":
+			result = "pong"
+		case "generateImages":
+			var params generateImagesParams
+			if err = json.Unmarshal(request.Params, &params); err == nil {
+				result, err = fakeGenerateImages(params)
+			}
+		default:
+			err = fmt.Errorf("unknown method: %s", request.Method)
+		}
+
+		response := map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": result}
+		if err != nil {
+			response = map[string]any{"jsonrpc": "2.0", "id": request.ID, "error": map[string]any{
+				"code":    -32603,
+				"message": err.Error(),
+			}}
+		}
+		if err := encoder.Encode(response); err != nil {
+			return fmt.Errorf("failed to write response: %w", err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func fakeGenerateImages(params generateImagesParams) (*cli.GenerateImagesResult, error) {
+	if err := os.MkdirAll(params.Output, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create output dir: %w", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(params.ThemeContribute.Path), filepath.Ext(params.ThemeContribute.Path))
+	svgPath := filepath.Join(params.Output, name+"-js.svg")
+	pngPath := filepath.Join(params.Output, name+"-js.png")
+
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="400" height="225"></svg>`
+	if err := os.WriteFile(svgPath, []byte(svg), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write svg: %w", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 400, 225))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 0x1e, G: 0x1e, B: 0x1e, A: 0xff}}, image.Point{}, draw.Src)
+	pngFile, err := os.Create(pngPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create png: %w", err)
+	}
+	defer pngFile.Close()
+	if err := png.Encode(pngFile, img); err != nil {
+		return nil, fmt.Errorf("failed to write png: %w", err)
+	}
+
+	displayName := params.ThemeContribute.Path
+	if params.ThemeContribute.Label != nil {
+		displayName = *params.ThemeContribute.Label
+	}
+
+	keywordColor := "#ff0000"
+	return &cli.GenerateImagesResult{
+		Theme: cli.Theme{
+			Path:        params.ThemeContribute.Path,
+			DisplayName: displayName,
+			Type:        "dark",
+		},
+		Languages: []cli.LanguageResult{
+			{
+				Language: cli.Language{Name: "JavaScript", ExtName: "js"},
+				Tokens: [][]cli.Token{{
+					{Text: "if", Style: cli.Style{Color: &keywordColor}, Scopes: []string{"source.js", "keyword.control.conditional.js"}},
+				}},
+				SvgPath: svgPath,
+				PngPath: pngPath,
+			},
+		},
+	}, nil
+}